@@ -1,15 +1,23 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -20,6 +28,7 @@ import (
 	"github.com/UDL-TF/TourneyController/internal/config"
 	"github.com/UDL-TF/TourneyController/internal/controller"
 	"github.com/UDL-TF/TourneyController/internal/database"
+	"github.com/UDL-TF/TourneyController/internal/ports"
 )
 
 func main() {
@@ -41,14 +50,36 @@ func main() {
 		kubeconfig = filepath.Join(home, ".kube", "config")
 	}
 
+	var namespace string
+	var force, allOrphans, once, teardown bool
+
 	flag.StringVar(&kubeconfig, "kubeconfig", kubeconfig, "Path to the kubeconfig file. If running in-cluster, leave empty")
+	flag.StringVar(&namespace, "namespace", "", "Namespace for tournament servers. Overrides the NAMESPACE env var")
+	flag.BoolVar(&force, "force", false, "Skip the confirmation prompt for bulk deletes")
+	flag.BoolVar(&allOrphans, "all-orphans", false, "Delete every server not backed by an active match")
+	flag.BoolVar(&teardown, "teardown", false, "Drain by deleting each server outright instead of moving its pods")
+	flag.BoolVar(&once, "once", false, "Reconcile a single time, print a JSON summary and exit with a status reflecting it")
 	flag.Parse()
 
 	switch command {
 	case "run":
-		runController(kubeconfig)
+		runController(kubeconfig, namespace, once)
 	case "delete":
-		runDeleteCommand()
+		runDeleteCommand(kubeconfig, namespace, force, allOrphans)
+	case "drain":
+		runDrainCommand(kubeconfig, namespace, force, teardown)
+	case "diff":
+		runDiffCommand(kubeconfig, namespace)
+	case "reconcile":
+		runReconcileCommand(kubeconfig, namespace)
+	case "ports":
+		runPortsCommand(kubeconfig, namespace)
+	case "validate":
+		runValidateCommand(kubeconfig, namespace)
+	case "config":
+		fmt.Printf("%+v\n", loadAppConfig(namespace).Redacted())
+	case "version":
+		fmt.Println(versionString())
 	default:
 		fmt.Printf("Unknown command: %s\n\n", command)
 		printUsage()
@@ -59,18 +90,33 @@ func main() {
 func printUsage() {
 	fmt.Println("Usage:")
 	fmt.Println("  controller run                        - Start the tournament controller")
+	fmt.Println("  controller run --once                 - Reconcile once, print a JSON summary and exit")
+	fmt.Println("                                          0 (all ok), 2 (partial failure) or 3 (total failure)")
 	fmt.Println("  controller delete <match_id> <round_id> - Delete a tournament server")
+	fmt.Println("  controller delete [--force] <match_id>  - Delete every server for a match")
+	fmt.Println("  controller delete [--force] --all-orphans - Delete servers not backed by an active match")
+	fmt.Println("  controller drain [--force] [--teardown] <node> - Move servers off a cordoned node, telling")
+	fmt.Println("                                          their teams; --teardown deletes them instead")
+	fmt.Println("  controller diff <match_id> <round_id>   - Show what a reconcile would change")
+	fmt.Println("  controller reconcile <match_id>         - Reconcile one match now and print the outcome")
+	fmt.Println("  controller ports                      - Report port range usage")
+	fmt.Println("  controller validate                   - Run pre-flight checks without reconciling")
+	fmt.Println("  controller config                     - Print the resolved configuration (secrets redacted)")
+	fmt.Println("  controller version                    - Print the version, commit, build date and Go version")
 	fmt.Println("")
 	fmt.Println("Examples:")
 	fmt.Println("  controller run")
 	fmt.Println("  controller delete 123 456")
+	fmt.Println("  controller delete --force 123")
+	fmt.Println("  controller drain node-a")
+	fmt.Println("  controller diff 123 456")
+	fmt.Println("  controller reconcile 123")
 }
 
-func runController(kubeconfig string) {
-	appCfg, err := config.Load()
-	if err != nil {
-		klog.Fatalf("failed to load controller config: %v", err)
-	}
+func runController(kubeconfig, namespace string, once bool) {
+	klog.Infof("starting tournament controller %s", versionString())
+	appCfg := loadAppConfig(namespace)
+	klog.Infof("effective config: %+v", appCfg.Redacted())
 
 	restCfg, err := loadConfig(kubeconfig)
 	if err != nil {
@@ -88,25 +134,256 @@ func runController(kubeconfig string) {
 	}
 	defer repo.Close()
 
-	renderer, err := chart.NewRenderer(restCfg, appCfg.Chart.Path, appCfg.Chart.ValuesFile, appCfg.Namespace)
+	if err := repo.CheckSchema(context.Background(), appCfg); err != nil {
+		klog.Fatalf("incompatible database: %v", err)
+	}
+
+	renderers, err := newRenderers(restCfg, appCfg)
 	if err != nil {
 		klog.Fatalf("failed to initialize chart renderer: %v", err)
 	}
 
-	ctrl := controller.New(appCfg, repo, clientset, renderer)
+	ctrl := controller.New(appCfg, repo, clientset, renderers, controller.NewTokenProvider(appCfg))
+	if err := ctrl.ValidateChart(); err != nil {
+		klog.Fatalf("chart is incompatible: %v", err)
+	}
 
 	ctx, cancel := signalContext()
 	defer cancel()
 
+	if err := ctrl.EnsureNamespace(ctx); err != nil {
+		klog.Fatalf("namespace check failed: %v", err)
+	}
+
+	if once {
+		summary := ctrl.RunOnce(ctx)
+		out, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			klog.Fatalf("failed to encode summary: %v", err)
+		}
+		fmt.Println(string(out))
+		// os.Exit skips the deferred cleanup.
+		cancel()
+		repo.Close()
+		klog.Flush()
+		os.Exit(summary.ExitCode())
+	}
+
+	go togglePauseOnSignal(ctx, ctrl)
+	if appCfg.HTTP.Addr != "" {
+		go serveHTTP(ctx, appCfg.HTTP, ctrl.Handler())
+	}
+
 	if err := ctrl.Run(ctx); err != nil && err != context.Canceled {
 		klog.Fatalf("controller exited with error: %v", err)
 	}
 }
 
-func runDeleteCommand() {
+func runDeleteCommand(kubeconfig, namespace string, force, allOrphans bool) {
 	args := flag.Args()
-	if len(args) != 2 {
-		fmt.Println("Error: delete command requires exactly 2 arguments: <match_id> <round_id>")
+	if !allOrphans && len(args) == 2 {
+		matchID, roundID := parseMatchRoundArgs("delete")
+
+		ctrl, cleanup := setupController(kubeconfig, namespace)
+		defer cleanup()
+
+		// Delete the server
+		ctx := context.Background()
+		if err := ctrl.DeleteServer(ctx, matchID, roundID); err != nil {
+			klog.Fatalf("failed to delete server: %v", err)
+		}
+
+		fmt.Printf("Successfully deleted tournament server for match %d round %d\n", matchID, roundID)
+		return
+	}
+
+	if (allOrphans && len(args) != 0) || (!allOrphans && len(args) != 1) {
+		fmt.Println("Error: delete requires <match_id> <round_id>, <match_id>, or --all-orphans")
+		fmt.Println("")
+		printUsage()
+		os.Exit(1)
+	}
+
+	ctrl, cleanup := setupController(kubeconfig, namespace)
+	defer cleanup()
+
+	ctx := context.Background()
+	var refs []controller.ServerRef
+	if allOrphans {
+		found, err := ctrl.OrphanedServers(ctx)
+		if err != nil {
+			klog.Fatalf("failed to find orphaned servers: %v", err)
+		}
+		refs = found
+	} else {
+		matchID, err := strconv.Atoi(args[0])
+		if err != nil {
+			klog.Fatalf("Invalid match_id '%s': must be a number", args[0])
+		}
+		found, err := ctrl.MatchServers(ctx, matchID)
+		if err != nil {
+			klog.Fatalf("failed to find servers for match %d: %v", matchID, err)
+		}
+		refs = found
+	}
+
+	if len(refs) == 0 {
+		fmt.Println("No servers to delete")
+		return
+	}
+
+	fmt.Println("The following servers will be deleted:")
+	for _, ref := range refs {
+		fmt.Printf("  match %d round %d\n", ref.MatchID, ref.RoundID)
+	}
+	if !force && !confirm("Proceed?") {
+		fmt.Println("Aborted")
+		return
+	}
+
+	if err := ctrl.DeleteServers(ctx, refs); err != nil {
+		klog.Fatalf("failed to delete servers: %v", err)
+	}
+	fmt.Printf("Successfully deleted %d tournament servers\n", len(refs))
+}
+
+// serveHTTP runs the controller's HTTP endpoints until ctx is cancelled.
+func serveHTTP(ctx context.Context, cfg config.HTTPConfig, handler http.Handler) {
+	server := &http.Server{Addr: cfg.Addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			klog.Errorf("http server disabled: read client CA: %v", err)
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			klog.Errorf("http server disabled: no certificates in %s", cfg.ClientCAFile)
+			return
+		}
+		// Certificates are optional at the handshake so unauthenticated read-only
+		// endpoints and token callers still connect; handlers check the result.
+		server.TLSConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven, MinVersion: tls.VersionTLS12}
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	var err error
+	if cfg.TLSCertFile != "" {
+		klog.Infof("serving HTTPS on %s", cfg.Addr)
+		err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		klog.Infof("serving HTTP on %s", cfg.Addr)
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		klog.Errorf("http server failed: %v", err)
+	}
+}
+
+// togglePauseOnSignal flips maintenance mode each time SIGUSR1 arrives.
+func togglePauseOnSignal(ctx context.Context, ctrl *controller.Controller) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			paused := !ctrl.Paused()
+			ctrl.SetPaused(paused)
+			klog.Infof("received SIGUSR1, paused=%t", paused)
+		}
+	}
+}
+
+// confirm asks a yes/no question on stdin, defaulting to no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func runDiffCommand(kubeconfig, namespace string) {
+	matchID, roundID := parseMatchRoundArgs("diff")
+
+	ctrl, cleanup := setupController(kubeconfig, namespace)
+	defer cleanup()
+
+	diff, err := ctrl.DiffServer(context.Background(), matchID, roundID)
+	if err != nil {
+		klog.Fatalf("failed to diff server: %v", err)
+	}
+
+	if diff == "" {
+		fmt.Printf("No changes for match %d round %d\n", matchID, roundID)
+		return
+	}
+	fmt.Print(diff)
+}
+
+// runDrainCommand moves every managed server off a cordoned node ahead of
+// maintenance. The running controller re-advertises them once they are up.
+func runDrainCommand(kubeconfig, namespace string, force, teardown bool) {
+	args := flag.Args()
+	if len(args) != 1 {
+		fmt.Println("Error: drain command requires exactly 1 argument: <node>")
+		fmt.Println("")
+		printUsage()
+		os.Exit(1)
+	}
+	nodeName := args[0]
+
+	ctrl, cleanup := setupController(kubeconfig, namespace)
+	defer cleanup()
+
+	ctx := context.Background()
+	refs, err := ctrl.NodeServers(ctx, nodeName)
+	if err != nil {
+		cleanup()
+		klog.Fatalf("failed to find servers on %s: %v", nodeName, err)
+	}
+	if len(refs) == 0 {
+		fmt.Printf("No servers on %s\n", nodeName)
+		return
+	}
+
+	action := "moved to other nodes"
+	if teardown {
+		action = "torn down"
+	}
+	fmt.Printf("The following servers on %s will be %s:\n", nodeName, action)
+	for _, ref := range refs {
+		fmt.Printf("  match %d round %d\n", ref.MatchID, ref.RoundID)
+	}
+	if !force && !confirm("Proceed?") {
+		fmt.Println("Aborted")
+		return
+	}
+
+	if err := ctrl.DrainNode(ctx, nodeName, refs, teardown); err != nil {
+		cleanup()
+		klog.Fatalf("failed to drain %s: %v", nodeName, err)
+	}
+	fmt.Printf("Drained %d servers from %s\n", len(refs), nodeName)
+}
+
+// runReconcileCommand reconciles a single match once and prints the decision for
+// each of its rounds, as the debug endpoint would report it.
+func runReconcileCommand(kubeconfig, namespace string) {
+	args := flag.Args()
+	if len(args) != 1 {
+		fmt.Println("Error: reconcile command requires exactly 1 argument: <match_id>")
 		fmt.Println("")
 		printUsage()
 		os.Exit(1)
@@ -117,27 +394,151 @@ func runDeleteCommand() {
 		klog.Fatalf("Invalid match_id '%s': must be a number", args[0])
 	}
 
-	roundID, err := strconv.Atoi(args[1])
+	ctrl, cleanup := setupController(kubeconfig, namespace)
+	defer cleanup()
+
+	status, reconcileErr := ctrl.ReconcileMatch(context.Background(), matchID)
+	out, err := json.MarshalIndent(status, "", "  ")
 	if err != nil {
-		klog.Fatalf("Invalid round_id '%s': must be a number", args[1])
+		klog.Fatalf("failed to encode result: %v", err)
 	}
+	fmt.Println(string(out))
 
-	// Load configuration
-	appCfg, err := config.Load()
+	if reconcileErr != nil {
+		cleanup()
+		klog.Fatalf("failed to reconcile match %d: %v", matchID, reconcileErr)
+	}
+}
+
+func runPortsCommand(kubeconfig, namespace string) {
+	appCfg := loadAppConfig(namespace)
+
+	restCfg, err := loadConfig(kubeconfig)
 	if err != nil {
-		klog.Fatalf("failed to load controller config: %v", err)
+		klog.Fatalf("failed to load Kubernetes configuration: %v", err)
 	}
 
-	// Get kubeconfig from global flag
-	var kubeconfig string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = filepath.Join(home, ".kube", "config")
+	clientset, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		klog.Fatalf("failed to create Kubernetes clientset: %v", err)
 	}
-	flag.Visit(func(f *flag.Flag) {
-		if f.Name == "kubeconfig" {
-			kubeconfig = f.Value.String()
+
+	allocator := ports.NewAllocator(appCfg.Ports)
+	report, err := allocator.Report(context.Background(),
+		clientset.CoreV1().Services(appCfg.Namespace),
+		clientset.CoreV1().Secrets(appCfg.Namespace))
+	if err != nil {
+		klog.Fatalf("failed to scan port usage: %v", err)
+	}
+
+	fmt.Printf("Port allocation mode: %s\n", appCfg.Ports.Mode)
+	for _, usage := range report {
+		fmt.Printf("\n%s %d-%d: %d used, %d free of %d\n",
+			usage.Name, usage.Range.Start, usage.Range.End, len(usage.Used), usage.Free(), usage.Range.Size())
+		for _, used := range usage.Used {
+			fmt.Printf("  %d  %-7s  %s\n", used.Port, used.ProtocolList(), used.Owner)
 		}
-	})
+	}
+}
+
+// runValidateCommand runs the pre-flight checks an operator would otherwise only
+// discover through failed reconciles, printing one PASS/FAIL line per check and
+// exiting non-zero if any failed.
+func runValidateCommand(kubeconfig, namespace string) {
+	appCfg, err := config.Load()
+	if !reportCheck("configuration and port ranges", err) {
+		os.Exit(1)
+	}
+	if ns := strings.TrimSpace(namespace); ns != "" {
+		appCfg.Namespace = ns
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ok := true
+	repo, err := database.New(appCfg.Database)
+	ok = reportCheck("database connection", err) && ok
+	if repo != nil {
+		defer repo.Close()
+		ok = reportCheck("database schema", repo.CheckSchema(ctx, appCfg)) && ok
+	}
+
+	restCfg, err := loadConfig(kubeconfig)
+	if !reportCheck("kubernetes configuration", err) {
+		os.Exit(1)
+	}
+	clientset, err := kubernetes.NewForConfig(restCfg)
+	if !reportCheck("kubernetes client", err) {
+		os.Exit(1)
+	}
+
+	_, err = clientset.CoreV1().Namespaces().Get(ctx, appCfg.Namespace, metav1.GetOptions{})
+	ok = reportCheck(fmt.Sprintf("namespace %q exists", appCfg.Namespace), err) && ok
+
+	renderers, err := newRenderers(restCfg, appCfg)
+	if reportCheck("charts load", err) {
+		// A nil *database.Repository would be a non-nil Repository, so only a
+		// connected one is passed on.
+		var ctrlRepo controller.Repository
+		if repo != nil {
+			ctrlRepo = repo
+		}
+		ctrl := controller.New(appCfg, ctrlRepo, clientset, renderers, controller.NewTokenProvider(appCfg))
+		ok = reportCheck("chart renders the required kinds", ctrl.ValidateChart()) && ok
+	} else {
+		ok = false
+	}
+
+	if !ok {
+		fmt.Println("\nValidation failed")
+		if repo != nil {
+			_ = repo.Close()
+		}
+		os.Exit(1)
+	}
+	fmt.Println("\nAll checks passed")
+}
+
+// reportCheck prints the outcome of one validate check and reports whether it passed.
+func reportCheck(name string, err error) bool {
+	if err != nil {
+		fmt.Printf("FAIL  %s: %v\n", name, err)
+		return false
+	}
+	fmt.Printf("PASS  %s\n", name)
+	return true
+}
+
+// parseMatchRoundArgs reads the <match_id> <round_id> positional arguments shared by
+// the one-shot commands, exiting with usage on malformed input.
+func parseMatchRoundArgs(command string) (int, int) {
+	args := flag.Args()
+	if len(args) != 2 {
+		fmt.Printf("Error: %s command requires exactly 2 arguments: <match_id> <round_id>\n", command)
+		fmt.Println("")
+		printUsage()
+		os.Exit(1)
+	}
+
+	matchID, err := strconv.Atoi(args[0])
+	if err != nil {
+		klog.Fatalf("Invalid match_id '%s': must be a number", args[0])
+	}
+
+	roundID, err := strconv.Atoi(args[1])
+	if err != nil {
+		klog.Fatalf("Invalid round_id '%s': must be a number", args[1])
+	}
+
+	return matchID, roundID
+}
+
+// setupController wires the controller for one-shot commands. The returned cleanup
+// closes the database connection.
+func setupController(kubeconfig, namespace string) (*controller.Controller, func()) {
+	// Load configuration
+	appCfg := loadAppConfig(namespace)
 
 	// Set up Kubernetes client
 	restCfg, err := loadConfig(kubeconfig)
@@ -155,24 +556,43 @@ func runDeleteCommand() {
 	if err != nil {
 		klog.Fatalf("failed to connect to postgres: %v", err)
 	}
-	defer repo.Close()
 
 	// Set up chart renderer
-	renderer, err := chart.NewRenderer(restCfg, appCfg.Chart.Path, appCfg.Chart.ValuesFile, appCfg.Namespace)
+	renderers, err := newRenderers(restCfg, appCfg)
 	if err != nil {
+		_ = repo.Close()
 		klog.Fatalf("failed to initialize chart renderer: %v", err)
 	}
 
-	// Create controller
-	ctrl := controller.New(appCfg, repo, clientset, renderer)
+	return controller.New(appCfg, repo, clientset, renderers, controller.NewTokenProvider(appCfg)), func() { _ = repo.Close() }
+}
 
-	// Delete the server
-	ctx := context.Background()
-	if err := ctrl.DeleteServer(ctx, matchID, roundID); err != nil {
-		klog.Fatalf("failed to delete server: %v", err)
+// newRenderers loads the default chart and each game mode's chart.
+func newRenderers(restCfg *rest.Config, appCfg *config.Config) (map[string]controller.Renderer, error) {
+	renderer, err := chart.NewRenderer(restCfg, appCfg.Chart.Path, appCfg.Chart.ValuesFile, appCfg.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("chart %s: %w", appCfg.Chart.Path, err)
 	}
+	renderers := map[string]controller.Renderer{"": renderer}
+	for mode, source := range appCfg.Chart.Modes {
+		if renderers[mode], err = chart.NewRenderer(restCfg, source.Path, source.ValuesFile, appCfg.Namespace); err != nil {
+			return nil, fmt.Errorf("chart %s for game mode %s: %w", source.Path, mode, err)
+		}
+	}
+	return renderers, nil
+}
 
-	fmt.Printf("Successfully deleted tournament server for match %d round %d\n", matchID, roundID)
+// loadAppConfig loads the controller config, letting a non-empty --namespace flag
+// take precedence over the NAMESPACE env var.
+func loadAppConfig(namespace string) *config.Config {
+	appCfg, err := config.Load()
+	if err != nil {
+		klog.Fatalf("failed to load controller config: %v", err)
+	}
+	if ns := strings.TrimSpace(namespace); ns != "" {
+		appCfg.Namespace = ns
+	}
+	return appCfg
 }
 
 func loadConfig(kubeconfig string) (*rest.Config, error) {
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set with -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=...".
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = "unknown"
+)
+
+// buildCommit returns the injected commit, falling back to the VCS revision Go
+// embeds in binaries built from a checkout.
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+func versionString() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s)", version, buildCommit(), buildDate, runtime.Version())
+}
@@ -0,0 +1,175 @@
+package a2s
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Request and response headers from the Source server query protocol.
+const (
+	headerInfo      = 'T'
+	headerInfoReply = 'I'
+	headerChallenge = 'A'
+)
+
+// singlePacket prefixes every unsplit request and response.
+var singlePacket = []byte{0xFF, 0xFF, 0xFF, 0xFF}
+
+// maxPacketSize is the largest unsplit response a Source server sends.
+const maxPacketSize = 1400
+
+// Info is the subset of an A2S_INFO reply the controller cares about.
+type Info struct {
+	Name       string
+	Map        string
+	Folder     string
+	Game       string
+	AppID      uint16
+	Players    int
+	MaxPlayers int
+	Bots       int
+}
+
+// QueryInfo sends A2S_INFO to addr and parses the reply. The timeout bounds the
+// whole exchange, including a challenge round-trip.
+func QueryInfo(ctx context.Context, addr string, timeout time.Duration) (*Info, error) {
+	conn, err := dial(ctx, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	request := append(append([]byte{}, singlePacket...), headerInfo)
+	request = append(request, []byte("Source Engine Query\x00")...)
+	reply, err := exchange(conn, request)
+	if err != nil {
+		return nil, err
+	}
+	return parseInfo(reply)
+}
+
+func dial(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// exchange sends request and returns the reply payload after the packet header,
+// answering one S2C_CHALLENGE by resending with the challenge appended.
+func exchange(conn net.Conn, request []byte) ([]byte, error) {
+	reply, err := roundTrip(conn, request)
+	if err != nil {
+		return nil, err
+	}
+	if reply[0] == headerChallenge {
+		if len(reply) < 5 {
+			return nil, errors.New("short challenge reply")
+		}
+		reply, err = roundTrip(conn, append(request, reply[1:5]...))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reply, nil
+}
+
+func roundTrip(conn net.Conn, request []byte) ([]byte, error) {
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("send query: %w", err)
+	}
+	buf := make([]byte, maxPacketSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("read reply: %w", err)
+	}
+	if n < 5 || !bytes.Equal(buf[:4], singlePacket) {
+		return nil, errors.New("unexpected reply framing")
+	}
+	return buf[4:n], nil
+}
+
+func parseInfo(reply []byte) (*Info, error) {
+	if len(reply) < 2 || reply[0] != headerInfoReply {
+		return nil, errors.New("not an A2S_INFO reply")
+	}
+	r := reader{buf: reply[2:]} // skip header and protocol version
+	info := &Info{
+		Name:   r.string(),
+		Map:    r.string(),
+		Folder: r.string(),
+		Game:   r.string(),
+	}
+	info.AppID = r.uint16()
+	info.Players = int(r.byte())
+	info.MaxPlayers = int(r.byte())
+	info.Bots = int(r.byte())
+	if r.err != nil {
+		return nil, fmt.Errorf("parse A2S_INFO: %w", r.err)
+	}
+	return info, nil
+}
+
+// reader decodes the little-endian, NUL-terminated fields of a reply, recording
+// the first error instead of returning one per call.
+type reader struct {
+	buf []byte
+	err error
+}
+
+var errShort = errors.New("reply truncated")
+
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.buf, 0)
+	if i < 0 {
+		r.err = errShort
+		return ""
+	}
+	s := string(r.buf[:i])
+	r.buf = r.buf[i+1:]
+	return s
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 1 {
+		r.err = errShort
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *reader) uint16() uint16 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 2 {
+		r.err = errShort
+		return 0
+	}
+	v := binary.LittleEndian.Uint16(r.buf)
+	r.buf = r.buf[2:]
+	return v
+}
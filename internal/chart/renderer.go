@@ -3,9 +3,13 @@ package chart
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
 
 	"helm.sh/helm/v3/pkg/chart"
@@ -19,6 +23,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/discovery"
@@ -26,8 +31,29 @@ import (
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
+	"k8s.io/klog/v2"
 )
 
+// defaultFieldManager identifies the controller as the owner of applied fields
+// unless SetFieldManager names it otherwise.
+const defaultFieldManager = "tourney-controller"
+
+// releaseLabel is stamped on every applied object so Delete can find them by selector.
+const releaseLabel = "udl.tf/release"
+
+// managedKinds are always swept on Delete, even when the current chart render no
+// longer produces them. Delete sorts them into Helm's uninstall order.
+var managedKinds = []schema.GroupVersionKind{
+	{Group: "apps", Version: "v1", Kind: "Deployment"},
+	{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+	{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"},
+	{Version: "v1", Kind: "Service"},
+	{Version: "v1", Kind: "ConfigMap"},
+	{Version: "v1", Kind: "Secret"},
+	{Version: "v1", Kind: "PersistentVolumeClaim"},
+	{Version: "v1", Kind: "ServiceAccount"},
+}
+
 // Renderer materializes Helm manifests and applies them via the dynamic client.
 type Renderer struct {
 	chart     *chart.Chart
@@ -35,6 +61,27 @@ type Renderer struct {
 	namespace string
 	dynamic   dynamic.Interface
 	mapper    meta.ResettableRESTMapper
+
+	commonLabels      map[string]string
+	commonAnnotations map[string]string
+
+	fieldManager   string
+	forceConflicts bool
+}
+
+// SetCommonMetadata adds labels and annotations to every object Apply creates.
+// Keys the chart already sets are left alone.
+func (r *Renderer) SetCommonMetadata(labels, annotations map[string]string) {
+	r.commonLabels = labels
+	r.commonAnnotations = annotations
+}
+
+// SetFieldManager sets the field manager Apply writes as, and whether it takes
+// over fields another manager owns. Without force a conflicting object is left
+// as it is and the conflict logged.
+func (r *Renderer) SetFieldManager(name string, force bool) {
+	r.fieldManager = name
+	r.forceConflicts = force
 }
 
 // NewRenderer loads the chart, initializes Kubernetes helpers, and prepares for reconciliation.
@@ -77,6 +124,9 @@ func NewRenderer(restCfg *rest.Config, chartPath, valuesFile, namespace string)
 		namespace: namespace,
 		dynamic:   dyn,
 		mapper:    mapper,
+
+		fieldManager:   defaultFieldManager,
+		forceConflicts: true,
 	}, nil
 }
 
@@ -114,37 +164,244 @@ func loadChartFromOCI(ref string) (*chart.Chart, error) {
 	return ch, nil
 }
 
-// Apply renders the chart with overrides and upserts every resource.
-func (r *Renderer) Apply(ctx context.Context, releaseName string, overrides chartutil.Values) error {
+// Apply renders the chart with overrides and upserts every resource. A non-nil
+// owner is set as each object's owner reference so deleting it garbage-collects
+// the release.
+func (r *Renderer) Apply(ctx context.Context, releaseName string, overrides chartutil.Values, owner *metav1.OwnerReference) error {
 	objects, err := r.renderObjects(releaseName, overrides)
 	if err != nil {
 		return err
 	}
+	sortByKind(objects, releaseutil.InstallOrder)
 
 	for _, obj := range objects {
-		if err := r.applyObject(ctx, obj.DeepCopy()); err != nil {
+		desired := r.desiredObject(obj, releaseName)
+		if owner != nil {
+			desired.SetOwnerReferences([]metav1.OwnerReference{*owner})
+		}
+		if err := r.applyObject(ctx, desired); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// Delete renders the chart and removes each resource.
+// Delete removes every object labelled with the release name, so objects are
+// still found when the chart or values have drifted since they were applied.
+// The chart is rendered to discover kinds beyond managedKinds and, for objects
+// applied before the release label existed, to delete the rendered objects by
+// name afterwards.
 func (r *Renderer) Delete(ctx context.Context, releaseName string, overrides chartutil.Values) error {
+	kinds := append([]schema.GroupVersionKind{}, managedKinds...)
 	objects, err := r.renderObjects(releaseName, overrides)
 	if err != nil {
-		return err
+		klog.Warningf("render for %s failed, deleting labelled objects of the default kinds only: %v", releaseName, err)
 	}
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		if !containsKind(kinds, gvk) {
+			kinds = append(kinds, gvk)
+		}
+	}
+	sort.SliceStable(kinds, func(i, j int) bool {
+		return kindRank(releaseutil.UninstallOrder, kinds[i].Kind) < kindRank(releaseutil.UninstallOrder, kinds[j].Kind)
+	})
 
-	for i := len(objects) - 1; i >= 0; i-- {
-		if err := r.deleteObject(ctx, objects[i]); err != nil {
+	selector := fmt.Sprintf("%s=%s", releaseLabel, releaseName)
+	for _, gvk := range kinds {
+		mapping, err := r.restMapping(gvk)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				continue
+			}
 			return err
 		}
+
+		var resource dynamic.ResourceInterface = r.dynamic.Resource(mapping.Resource)
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			resource = r.dynamic.Resource(mapping.Resource).Namespace(r.namespace)
+		}
+
+		list, err := resource.List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return fmt.Errorf("list %s for %s: %w", gvk.Kind, releaseName, err)
+		}
+		for i := range list.Items {
+			if err := r.deleteObject(ctx, &list.Items[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Unlabelled leftovers; deleteObject ignores those already gone.
+	sortByKind(objects, releaseutil.UninstallOrder)
+	for _, obj := range objects {
+		if err := r.deleteObject(ctx, obj); err != nil {
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Diff renders the chart and reports, per object, the fields an apply would
+// change. Each object is applied as a server-side dry run and the result compared
+// with the live object, so defaults and normalized values such as quantities
+// match on both sides and do not show up as changes.
+func (r *Renderer) Diff(ctx context.Context, releaseName string, overrides chartutil.Values) (string, error) {
+	objects, err := r.renderObjects(releaseName, overrides)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for _, obj := range objects {
+		desired := r.desiredObject(obj, releaseName)
+
+		mapping, err := r.restMapping(desired.GroupVersionKind())
+		if err != nil {
+			return "", err
+		}
+		resource, err := r.resourceInterface(mapping, desired)
+		if err != nil {
+			return "", err
+		}
+
+		id := fmt.Sprintf("%s/%s", desired.GetKind(), desired.GetName())
+		live, err := resource.Get(ctx, desired.GetName(), metav1.GetOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				fmt.Fprintf(&out, "+ %s (will be created)\n", id)
+				continue
+			}
+			return "", fmt.Errorf("get %s: %w", id, err)
+		}
+
+		// The owner is not an input of the diff; keep whatever the live object has.
+		desired.SetOwnerReferences(live.GetOwnerReferences())
+		applied, err := resource.Apply(ctx, desired.GetName(), desired, metav1.ApplyOptions{
+			FieldManager: r.fieldManager,
+			Force:        r.forceConflicts,
+			DryRun:       []string{metav1.DryRunAll},
+		})
+		if err != nil {
+			if k8serrors.IsConflict(err) {
+				fmt.Fprintf(&out, "! %s (conflicts with another field manager: %v)\n", id, err)
+				continue
+			}
+			return "", fmt.Errorf("dry-run apply %s: %w", id, err)
+		}
+
+		var changes []string
+		diffFields("", withoutWriteMetadata(applied.Object), withoutWriteMetadata(live.Object), &changes)
+		if len(changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&out, "~ %s\n", id)
+		for _, change := range changes {
+			fmt.Fprintf(&out, "    %s\n", change)
+		}
+	}
+	return out.String(), nil
+}
+
+// desiredObject is a rendered object as Apply writes it, without its owner.
+func (r *Renderer) desiredObject(obj *unstructured.Unstructured, releaseName string) *unstructured.Unstructured {
+	desired := obj.DeepCopy()
+	desired.SetLabels(mergeMissing(desired.GetLabels(), r.commonLabels))
+	desired.SetAnnotations(mergeMissing(desired.GetAnnotations(), r.commonAnnotations))
+	labelForRelease(desired, releaseName)
+	return desired
+}
+
+// withoutWriteMetadata drops the metadata every write changes, leaving the fields an apply
+// decides.
+func withoutWriteMetadata(object map[string]interface{}) map[string]interface{} {
+	out := runtime.DeepCopyJSON(object)
+	for _, field := range []string{"managedFields", "resourceVersion", "generation"} {
+		unstructured.RemoveNestedField(out, "metadata", field)
+	}
+	return out
+}
+
+// diffFields walks desired and live and records every leaf that is missing from
+// either or differs between them.
+func diffFields(path string, desired, live interface{}, changes *[]string) {
+	switch want := desired.(type) {
+	case map[string]interface{}:
+		have, ok := live.(map[string]interface{})
+		if !ok {
+			*changes = append(*changes, fmt.Sprintf("%s: %v -> %v", displayPath(path), live, desired))
+			return
+		}
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		for key := range have {
+			if _, ok := want[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			diffFields(path+"."+key, want[key], have[key], changes)
+		}
+	case []interface{}:
+		have, ok := live.([]interface{})
+		if !ok || len(have) != len(want) {
+			*changes = append(*changes, fmt.Sprintf("%s: %v -> %v", displayPath(path), live, desired))
+			return
+		}
+		for i := range want {
+			diffFields(fmt.Sprintf("%s[%d]", path, i), want[i], have[i], changes)
+		}
+	default:
+		if fmt.Sprintf("%v", desired) != fmt.Sprintf("%v", live) {
+			*changes = append(*changes, fmt.Sprintf("%s: %v -> %v", displayPath(path), live, desired))
+		}
+	}
+}
+
+func displayPath(path string) string {
+	return strings.TrimPrefix(path, ".")
+}
+
+// Validate renders the chart offline with sample values and fails if any of the
+// required kinds is missing, so an incompatible chart is caught at startup rather
+// than by half-configured servers.
+func (r *Renderer) Validate(releaseName string, sample chartutil.Values, requiredKinds ...string) error {
+	objects, err := r.renderObjects(releaseName, sample)
+	if err != nil {
+		return fmt.Errorf("render chart with sample values: %w", err)
+	}
+
+	rendered := map[string]bool{}
+	for _, obj := range objects {
+		rendered[obj.GetKind()] = true
+	}
+	var missing []string
+	for _, kind := range requiredKinds {
+		if !rendered[kind] {
+			missing = append(missing, kind)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("chart %s-%s does not render required kinds: %s",
+			r.chart.Name(), r.chart.Metadata.Version, strings.Join(missing, ", "))
 	}
 	return nil
 }
 
 func (r *Renderer) renderObjects(releaseName string, overrides chartutil.Values) ([]*unstructured.Unstructured, error) {
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	klog.V(2).Infof("rendering %s with overrides for %s", releaseName, strings.Join(keys, ", "))
 	values := r.mergeValues(overrides)
 
 	releaseOpts := chartutil.ReleaseOptions{
@@ -163,17 +420,31 @@ func (r *Renderer) renderObjects(releaseName string, overrides chartutil.Values)
 	eng := engine.Engine{}
 	manifests, err := eng.Render(r.chart, renderVals)
 	if err != nil {
-		return nil, fmt.Errorf("render helm chart: %w", err)
+		return nil, renderError(err, values)
 	}
 
-	var objects []*unstructured.Unstructured
-	for name, manifest := range manifests {
+	// Walk templates by name and documents in file order so the result is stable
+	// across renders; map iteration alone would shuffle it.
+	names := make([]string, 0, len(manifests))
+	for name := range manifests {
 		if strings.HasSuffix(name, "NOTES.txt") {
 			continue
 		}
-		split := releaseutil.SplitManifests(manifest)
-		for _, fragment := range split {
-			dec := yaml.NewYAMLOrJSONDecoder(strings.NewReader(fragment), 4096)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var objects []*unstructured.Unstructured
+	for _, name := range names {
+		split := releaseutil.SplitManifests(manifests[name])
+		keys := make([]string, 0, len(split))
+		for key := range split {
+			keys = append(keys, key)
+		}
+		sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+		for _, key := range keys {
+			dec := yaml.NewYAMLOrJSONDecoder(strings.NewReader(split[key]), 4096)
 			for {
 				raw := map[string]interface{}{}
 				if err := dec.Decode(&raw); err != nil {
@@ -185,20 +456,11 @@ func (r *Renderer) renderObjects(releaseName string, overrides chartutil.Values)
 				if len(raw) == 0 {
 					continue
 				}
-				obj := &unstructured.Unstructured{Object: raw}
-				if obj.GetKind() == "" {
-					continue
-				}
-				if obj.GetKind() == "List" {
-					items, _, _ := unstructured.NestedSlice(obj.Object, "items")
-					for _, item := range items {
-						if typed, ok := item.(map[string]interface{}); ok {
-							objects = append(objects, &unstructured.Unstructured{Object: typed})
-						}
-					}
-					continue
+				flattened, err := flattenObject(raw)
+				if err != nil {
+					return nil, fmt.Errorf("manifest %s: %w", name, err)
 				}
-				objects = append(objects, obj)
+				objects = append(objects, flattened...)
 			}
 		}
 	}
@@ -206,6 +468,137 @@ func (r *Renderer) renderObjects(releaseName string, overrides chartutil.Values)
 	return objects, nil
 }
 
+// maxValuesDump caps the values included in a render error.
+const maxValuesDump = 4096
+
+// ErrRender is wrapped by every error caused by the chart failing to render.
+var ErrRender = errors.New("render helm chart")
+
+var failedTemplatePattern = regexp.MustCompile(`template: ([^:\s]+):`)
+
+// renderError adds the failing template and a redacted dump of the merged values
+// to a Helm render error, which on its own rarely says which value was at fault.
+func renderError(err error, values chartutil.Values) error {
+	template := "unknown"
+	if m := failedTemplatePattern.FindStringSubmatch(err.Error()); m != nil {
+		template = m[1]
+	}
+	dump, marshalErr := json.Marshal(redactValues(map[string]interface{}(values)))
+	if marshalErr != nil {
+		dump = []byte(fmt.Sprintf("<unprintable: %v>", marshalErr))
+	}
+	if len(dump) > maxValuesDump {
+		dump = append(dump[:maxValuesDump], "..."...)
+	}
+	return fmt.Errorf("%w (template %s): %w; values: %s", ErrRender, template, err, dump)
+}
+
+// sensitiveValuePattern matches keys and env names holding secrets; pw covers SRCDS_PW.
+var sensitiveValuePattern = regexp.MustCompile(`(?i)pass|pw|token|secret|rcon|apikey`)
+
+// redactValues copies vals with secrets masked: values under sensitive keys, and
+// the value of env-style {name, value} entries with a sensitive name.
+func redactValues(val interface{}) interface{} {
+	switch typed := val.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(typed))
+		name, _ := typed["name"].(string)
+		for k, v := range typed {
+			if _, isString := v.(string); isString && (sensitiveValuePattern.MatchString(k) || (k == "value" && sensitiveValuePattern.MatchString(name))) {
+				out[k] = "****"
+				continue
+			}
+			out[k] = redactValues(v)
+		}
+		return out
+	case chartutil.Values:
+		return redactValues(map[string]interface{}(typed))
+	case []interface{}:
+		out := make([]interface{}, len(typed))
+		for i, v := range typed {
+			out[i] = redactValues(v)
+		}
+		return out
+	case []map[string]interface{}:
+		out := make([]interface{}, len(typed))
+		for i, v := range typed {
+			out[i] = redactValues(v)
+		}
+		return out
+	default:
+		return typed
+	}
+}
+
+// sortByKind orders objects the way Helm installs or uninstalls them, so that e.g.
+// ServiceAccounts and ConfigMaps exist before the Deployment that references them.
+// Objects of the same kind keep their rendered order.
+func sortByKind(objects []*unstructured.Unstructured, order releaseutil.KindSortOrder) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		return kindRank(order, objects[i].GetKind()) < kindRank(order, objects[j].GetKind())
+	})
+}
+
+// kindRank returns the kind's position in order; unknown kinds go last.
+func kindRank(order releaseutil.KindSortOrder, kind string) int {
+	for i, k := range order {
+		if k == kind {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// flattenObject turns a decoded document into objects, expanding List kinds
+// (including nested lists) in item order. Documents carrying data without a kind
+// or apiVersion are rejected instead of being silently dropped.
+func flattenObject(raw map[string]interface{}) ([]*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{Object: raw}
+	if obj.GetKind() == "" {
+		return nil, fmt.Errorf("object without kind: %s", describeObject(raw))
+	}
+	if obj.GetAPIVersion() == "" {
+		return nil, fmt.Errorf("%s without apiVersion: %s", obj.GetKind(), describeObject(raw))
+	}
+	if !obj.IsList() {
+		return []*unstructured.Unstructured{obj}, nil
+	}
+
+	items, _, err := unstructured.NestedSlice(raw, "items")
+	if err != nil {
+		return nil, fmt.Errorf("read %s items: %w", obj.GetKind(), err)
+	}
+	var objects []*unstructured.Unstructured
+	for i, item := range items {
+		typed, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s item %d is not an object", obj.GetKind(), i)
+		}
+		if len(typed) == 0 {
+			continue
+		}
+		nested, err := flattenObject(typed)
+		if err != nil {
+			return nil, fmt.Errorf("%s item %d: %w", obj.GetKind(), i, err)
+		}
+		objects = append(objects, nested...)
+	}
+	return objects, nil
+}
+
+// describeObject names an object for error messages, falling back to its keys.
+func describeObject(raw map[string]interface{}) string {
+	if name, found, _ := unstructured.NestedString(raw, "metadata", "name"); found && name != "" {
+		return fmt.Sprintf("name %q", name)
+	}
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("fields %s", strings.Join(keys, ", "))
+}
+
 func (r *Renderer) applyObject(ctx context.Context, obj *unstructured.Unstructured) error {
 	mapping, err := r.restMapping(obj.GroupVersionKind())
 	if err != nil {
@@ -217,17 +610,20 @@ func (r *Renderer) applyObject(ctx context.Context, obj *unstructured.Unstructur
 		return err
 	}
 
-	existing, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
-	if err != nil {
-		if k8serrors.IsNotFound(err) {
-			_, createErr := resource.Create(ctx, obj, metav1.CreateOptions{})
-			return createErr
-		}
-		return err
+	// Server-side apply lets the API server merge our fields with those owned by
+	// other managers instead of overwriting the whole object. It compares the
+	// result after defaulting and normalization, and an apply that changes nothing
+	// is not written, so unchanged objects keep their resourceVersion and never
+	// roll out.
+	_, err = resource.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{
+		FieldManager: r.fieldManager,
+		Force:        r.forceConflicts,
+	})
+	if err != nil && !r.forceConflicts && k8serrors.IsConflict(err) {
+		// The API server's message lists each conflicting field and its manager.
+		klog.Warningf("not taking over fields of %s %s owned by another manager: %v", obj.GetKind(), obj.GetName(), err)
+		return nil
 	}
-
-	obj.SetResourceVersion(existing.GetResourceVersion())
-	_, err = resource.Update(ctx, obj, metav1.UpdateOptions{})
 	return err
 }
 
@@ -272,6 +668,40 @@ func (r *Renderer) restMapping(gvk schema.GroupVersionKind) (*meta.RESTMapping,
 	return mapping, nil
 }
 
+func labelForRelease(obj *unstructured.Unstructured, releaseName string) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[releaseLabel] = releaseName
+	obj.SetLabels(labels)
+}
+
+// mergeMissing copies entries of extra that are not already in base.
+func mergeMissing(base, extra map[string]string) map[string]string {
+	if len(extra) == 0 {
+		return base
+	}
+	if base == nil {
+		base = map[string]string{}
+	}
+	for k, v := range extra {
+		if _, ok := base[k]; !ok {
+			base[k] = v
+		}
+	}
+	return base
+}
+
+func containsKind(kinds []schema.GroupVersionKind, gvk schema.GroupVersionKind) bool {
+	for _, kind := range kinds {
+		if kind == gvk {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Renderer) mergeValues(overrides chartutil.Values) chartutil.Values {
 	merged := cloneValues(r.chart.Values)
 	merged = chartutil.CoalesceTables(cloneValues(r.baseVals), merged)
@@ -1,50 +1,319 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
+	"unicode"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
 )
 
 // Config captures every tunable knob for the controller runtime.
 type Config struct {
-	Namespace     string
-	PollInterval  time.Duration
-	Chart         ChartConfig
-	Database      DatabaseConfig
-	Ports         PortsConfig
-	SRCDS         SRCDSConfig
-	Steam         SteamConfig
-	Match         MatchConfig
-	Networking    NetworkingConfig
-	Notifications NotificationConfig
+	Namespace         string
+	CreateNamespace   bool              // Create Namespace at startup instead of failing when it is missing
+	CommonLabels      map[string]string // Added to every object the controller creates
+	CommonAnnotations map[string]string // Added to every object the controller creates
+	PollInterval      time.Duration
+	FailureExit       int // Exit after this many consecutive fully failed reconciles; 0 never exits
+	HTTP              HTTPConfig
+	Chart             ChartConfig
+	Release           ReleaseConfig
+	Database          DatabaseConfig
+	Ports             PortsConfig
+	SRCDS             SRCDSConfig
+	Steam             SteamConfig
+	Match             MatchConfig
+	Networking        NetworkingConfig
+	Notifications     NotificationConfig
+	Artifacts         ArtifactsConfig
+}
+
+// Redacted returns a copy of the config that is safe to log, with the database
+// password, static token, SourceTV password, Steam API key and admin token masked down to their last characters.
+func (c Config) Redacted() Config {
+	c.Database.Password = redact(c.Database.Password)
+	c.Database.ReplicaDSN = redact(c.Database.ReplicaDSN)
+	c.SRCDS.StaticToken = redact(c.SRCDS.StaticToken)
+	c.SRCDS.UnsafeSecretSeed = redact(c.SRCDS.UnsafeSecretSeed)
+	c.SRCDS.TVPassword = redact(c.SRCDS.TVPassword)
+	c.Steam.APIKey = redact(c.Steam.APIKey)
+	c.HTTP.AdminToken = redact(c.HTTP.AdminToken)
+	return c
+}
+
+// redact masks a secret, keeping the last four characters of long values so
+// operators can tell which one is in use.
+func redact(secret string) string {
+	switch {
+	case secret == "":
+		return ""
+	case len(secret) <= 8:
+		return "****"
+	default:
+		return "****" + secret[len(secret)-4:]
+	}
+}
+
+// HTTPConfig controls the controller's HTTP endpoints and who may call them.
+// Mutating endpoints require a caller authenticated with AdminToken or a client
+// certificate signed by ClientCAFile, and are disabled when neither is set.
+type HTTPConfig struct {
+	Addr         string // Listen address; empty disables the HTTP server
+	AdminToken   string // Accepted as "Authorization: Bearer <token>"
+	TLSCertFile  string // Serve HTTPS with this certificate and TLSKeyFile
+	TLSKeyFile   string
+	ClientCAFile string // Client certificates signed by these CAs are authenticated (mTLS)
+	AuthRead     bool   // Also require authentication for read-only endpoints except /readyz
+}
+
+// AuthEnabled reports whether any authentication method is configured.
+func (h HTTPConfig) AuthEnabled() bool {
+	return h.AdminToken != "" || h.ClientCAFile != ""
+}
+
+func loadHTTPConfig() (HTTPConfig, error) {
+	adminToken, err := getSecret("ADMIN_TOKEN")
+	if err != nil {
+		return HTTPConfig{}, err
+	}
+	authRead, err := getEnvBool("HTTP_AUTH_READ", false)
+	if err != nil {
+		return HTTPConfig{}, fmt.Errorf("invalid HTTP_AUTH_READ: %w", err)
+	}
+	cfg := HTTPConfig{
+		Addr:         getEnv("HTTP_ADDR", ""),
+		AdminToken:   adminToken,
+		TLSCertFile:  getEnv("HTTP_TLS_CERT_FILE", ""),
+		TLSKeyFile:   getEnv("HTTP_TLS_KEY_FILE", ""),
+		ClientCAFile: getEnv("HTTP_CLIENT_CA_FILE", ""),
+		AuthRead:     authRead,
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return HTTPConfig{}, errors.New("HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE must be set together")
+	}
+	if cfg.ClientCAFile != "" && cfg.TLSCertFile == "" {
+		return HTTPConfig{}, errors.New("HTTP_CLIENT_CA_FILE needs HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE")
+	}
+	if cfg.AuthRead && !cfg.AuthEnabled() {
+		return HTTPConfig{}, errors.New("HTTP_AUTH_READ needs ADMIN_TOKEN or HTTP_CLIENT_CA_FILE")
+	}
+	return cfg, nil
 }
 
 // ChartConfig controls how we render TF2Chart.
 type ChartConfig struct {
 	Path       string
 	ValuesFile string
+	// Modes holds the charts of game modes that need their own, keyed by game
+	// mode: a lowercased division or league name, the division winning. Other
+	// matches use Path.
+	Modes map[string]ChartSource
+	// FieldManager names the controller in server-side apply; give each
+	// controller instance its own.
+	FieldManager string
+	// ForceConflicts takes over fields another manager owns; without it the
+	// conflicting object is left alone and the conflict logged.
+	ForceConflicts bool
+}
+
+// ChartSource is a chart and the values file rendered with it.
+type ChartSource struct {
+	Path       string
+	ValuesFile string
+}
+
+// Mode returns the game mode whose chart serves a division, or "" for the
+// default chart.
+func (c ChartConfig) Mode(division, league string) string {
+	for _, name := range []string{division, league} {
+		if _, ok := c.Modes[strings.ToLower(name)]; ok {
+			return strings.ToLower(name)
+		}
+	}
+	return ""
+}
+
+// ReleaseConfig names the Helm release, state secret and labels of each round's server.
+type ReleaseConfig struct {
+	NameTemplate string // Supports {prefix}, {match_id} and {round_id}
+	Prefix       string
+}
+
+// MaxReleaseNameLength is Helm's release name limit. It leaves room for the
+// "-settings" state secret suffix and chart-added suffixes within the 63
+// characters Kubernetes allows in names and label values.
+const MaxReleaseNameLength = 53
+
+// releaseHashLength is how many hex characters of the full name's hash replace
+// the tail of an over-long release name.
+const releaseHashLength = 8
+
+// Name renders the release name for a match round. Names longer than
+// MaxReleaseNameLength are cut short and suffixed with a hash of the full name,
+// so they stay unique per round and stable across reconciles.
+func (r ReleaseConfig) Name(matchID, roundID int) string {
+	name := strings.NewReplacer(
+		"{prefix}", r.Prefix,
+		"{match_id}", strconv.Itoa(matchID),
+		"{round_id}", strconv.Itoa(roundID),
+	).Replace(r.NameTemplate)
+	if len(name) <= MaxReleaseNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	head := strings.TrimRight(name[:MaxReleaseNameLength-releaseHashLength-1], "-")
+	return head + "-" + hex.EncodeToString(sum[:])[:releaseHashLength]
+}
+
+// Pattern matches untruncated names produced by Name, capturing the IDs in the "match" and
+// "round" groups.
+func (r ReleaseConfig) Pattern() *regexp.Regexp {
+	quoted := regexp.QuoteMeta(strings.ReplaceAll(r.NameTemplate, "{prefix}", r.Prefix))
+	quoted = strings.NewReplacer(
+		`\{match_id\}`, `(?P<match>\d+)`,
+		`\{round_id\}`, `(?P<round>\d+)`,
+	).Replace(quoted)
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// dns1123Label matches a valid Kubernetes object name segment.
+var dns1123Label = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// mapNamePattern matches the map names passed to SRCDS_STARTMAP and RCON changelevel.
+var mapNamePattern = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+// ValidMapName reports whether name is a plain map name, safe to hand to srcds.
+func ValidMapName(name string) bool {
+	return mapNamePattern.MatchString(name)
+}
+
+// validate requires each ID placeholder exactly once and checks that rendered names
+// are DNS-1123 labels that parse back to the IDs they were built from.
+func (r ReleaseConfig) validate() error {
+	if r.Prefix != "" && !dns1123Label.MatchString(r.Prefix) {
+		return fmt.Errorf("prefix %q must be lowercase alphanumerics and '-'", r.Prefix)
+	}
+	for _, m := range placeholderPattern.FindAllStringSubmatch(r.NameTemplate, -1) {
+		if m[1] != "prefix" && m[1] != "match_id" && m[1] != "round_id" {
+			return fmt.Errorf("unknown placeholder {%s}, expected one of prefix, match_id, round_id", m[1])
+		}
+	}
+	for _, required := range []string{"{match_id}", "{round_id}"} {
+		if strings.Count(r.NameTemplate, required) != 1 {
+			return fmt.Errorf("template must contain %s exactly once", required)
+		}
+	}
+
+	sample := r.Name(12, 345)
+	if !dns1123Label.MatchString(sample) {
+		return fmt.Errorf("template renders %q, which is not a DNS-1123 name", sample)
+	}
+	pattern := r.Pattern()
+	m := pattern.FindStringSubmatch(sample)
+	if m == nil || m[pattern.SubexpIndex("match")] != "12" || m[pattern.SubexpIndex("round")] != "345" {
+		return fmt.Errorf("template renders %q, from which the match and round IDs cannot be read back", sample)
+	}
+	return nil
 }
 
 // DatabaseConfig feeds sql.Open and connection pool tuning.
 type DatabaseConfig struct {
-	Host            string
-	Port            string
-	User            string
-	Password        string
-	Name            string
-	SSLMode         string
-	MaxOpenConns    int
-	MaxIdleConns    int
-	ConnMaxLifetime time.Duration
+	Host               string
+	Port               string
+	User               string
+	Password           string
+	Name               string
+	SSLMode            string
+	SSLCert            string // Client certificate path for mutual TLS
+	SSLKey             string // Client key path for mutual TLS
+	SSLRootCert        string // CA bundle used to verify the server
+	ReplicaDSN         string // Optional read replica for site-owned tables; empty reads from the primary
+	MaxOpenConns       int
+	MaxIdleConns       int
+	ConnMaxLifetime    time.Duration
+	LookupCacheTTL     time.Duration // How long division, league and map rows are cached; 0 disables
+	FailureThreshold   int           // Consecutive failed reconciles before polling backs off; 0 disables
+	MaxBackoff         time.Duration // Cap on the backed-off poll interval
+	Schema             SchemaMapping // Table and column renames for forks of the site schema
+	SlowQueryThreshold time.Duration // Queries slower than this are logged; 0 disables
+}
+
+// SchemaMapping renames the site tables and columns the repository queries, for
+// deployments whose schema differs from upstream. Names missing from the
+// mapping keep their upstream spelling.
+type SchemaMapping struct {
+	Tables  map[string]string            `json:"tables"`
+	Columns map[string]map[string]string `json:"columns"` // Keyed by upstream table name
+}
+
+// Table returns the deployed name of the upstream table.
+func (m SchemaMapping) Table(table string) string {
+	if name, ok := m.Tables[table]; ok {
+		return name
+	}
+	return table
+}
+
+// Column returns the deployed name of column on the upstream table.
+func (m SchemaMapping) Column(table, column string) string {
+	if name, ok := m.Columns[table][column]; ok {
+		return name
+	}
+	return column
+}
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (m SchemaMapping) validate() error {
+	for table, name := range m.Tables {
+		if !sqlIdentifier.MatchString(name) {
+			return fmt.Errorf("table %s: %q is not a valid identifier", table, name)
+		}
+	}
+	for table, columns := range m.Columns {
+		for column, name := range columns {
+			if !sqlIdentifier.MatchString(name) {
+				return fmt.Errorf("column %s.%s: %q is not a valid identifier", table, column, name)
+			}
+		}
+	}
+	return nil
+}
+
+// loadSchemaMapping reads a YAML SchemaMapping from path; an empty path keeps
+// the upstream names.
+func loadSchemaMapping(path string) (SchemaMapping, error) {
+	var mapping SchemaMapping
+	if path == "" {
+		return mapping, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return mapping, err
+	}
+	if err := yaml.UnmarshalStrict(data, &mapping); err != nil {
+		return mapping, err
+	}
+	return mapping, mapping.validate()
 }
 
 // DSN returns a lib/pq compatible connection string.
 func (d DatabaseConfig) DSN() string {
-	return fmt.Sprintf(
+	dsn := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		d.Host,
 		d.Port,
@@ -53,22 +322,92 @@ func (d DatabaseConfig) DSN() string {
 		d.Name,
 		d.SSLMode,
 	)
+	if d.SSLCert != "" {
+		dsn += " sslcert=" + d.SSLCert
+	}
+	if d.SSLKey != "" {
+		dsn += " sslkey=" + d.SSLKey
+	}
+	if d.SSLRootCert != "" {
+		dsn += " sslrootcert=" + d.SSLRootCert
+	}
+	return dsn
 }
 
 // PortsConfig defines the discrete ranges used for each TF2 server port.
 type PortsConfig struct {
+	Mode     PortAllocationMode
 	Game     PortRange
 	SourceTV PortRange
 	Client   PortRange
 	Steam    PortRange
 }
 
+// PortAllocationMode selects how ports are picked for a new server.
+type PortAllocationMode string
+
+const (
+	// PortAllocationRanges picks each port type independently from its own range.
+	PortAllocationRanges PortAllocationMode = "ranges"
+	// PortAllocationContiguous reserves a block of ContiguousBlockSize ports from the
+	// game range: game, game+1 (client), game+2 (steam), game+3 (sourcetv).
+	PortAllocationContiguous PortAllocationMode = "contiguous"
+)
+
+// ContiguousBlockSize is the number of ports reserved per server in contiguous mode.
+const ContiguousBlockSize = 4
+
+// minRecommendedPorts is the range size below which we warn about running out of
+// ports during a busy event.
+const minRecommendedPorts = 16
+
+// Validate rejects configurations where two port types share any port.
+func (p PortsConfig) Validate() error {
+	named := p.Ranges()
+	for i := range named {
+		for j := i + 1; j < len(named); j++ {
+			if named[i].Range.Overlaps(named[j].Range) {
+				return fmt.Errorf("port ranges %s (%d-%d) and %s (%d-%d) overlap",
+					named[i].Name, named[i].Range.Start, named[i].Range.End,
+					named[j].Name, named[j].Range.Start, named[j].Range.End)
+			}
+		}
+	}
+	return nil
+}
+
+// NamedPortRange pairs a port type with its configured range.
+type NamedPortRange struct {
+	Name  string
+	Range PortRange
+}
+
+// Ranges lists every configured range in allocation order.
+func (p PortsConfig) Ranges() []NamedPortRange {
+	return []NamedPortRange{
+		{Name: "game", Range: p.Game},
+		{Name: "sourcetv", Range: p.SourceTV},
+		{Name: "client", Range: p.Client},
+		{Name: "steam", Range: p.Steam},
+	}
+}
+
 // PortRange represents an inclusive start/end block.
 type PortRange struct {
 	Start int
 	End   int
 }
 
+// Size returns the number of ports in the range.
+func (r PortRange) Size() int {
+	return r.End - r.Start + 1
+}
+
+// Overlaps reports whether the two ranges share at least one port.
+func (r PortRange) Overlaps(other PortRange) bool {
+	return r.Start <= other.End && other.Start <= r.End
+}
+
 // Validate ensures the range is well-formed.
 func (r PortRange) Validate() error {
 	if r.Start <= 0 || r.End <= 0 {
@@ -84,9 +423,221 @@ func (r PortRange) Validate() error {
 type SRCDSConfig struct {
 	TickRate           int
 	MaxPlayersOverride int
+	LeagueSettings     map[int]LeagueSettings // Keyed by league ID, overrides TickRate and MaxPlayersOverride
 	StaticToken        string
 	PasswordLength     int
 	RCONLength         int
+	PasswordAlphabet   string        // Characters server passwords are drawn from
+	RCONAlphabet       string        // Characters RCON passwords are drawn from
+	UnsafeSecretSeed   string        // Derive passwords from this seed instead of crypto/rand; tests only
+	StickyPasswords    bool          // Derive each round's password from a stored key so it survives state loss
+	ServerConfig       string        // text/template for a per-round config file; empty disables it
+	ServerConfigPath   string        // Where the rendered file is mounted in the server container
+	RCONRotation       time.Duration // Rotate running servers' RCON passwords this often; 0 disables
+	Resources          ResourceConfig
+	DivisionResources  map[string]ResourceConfig // Keyed by lowercased division name
+	NodeSelector       map[string]string
+	Affinity           map[string]interface{}       // Raw pod affinity block passed to the chart
+	Tolerations        []interface{}                // Raw pod tolerations passed to the chart
+	HostnameTemplate   string                       // See HostnamePlaceholders for the supported fields
+	ExtraEnv           map[string]string            // Additional container env; controller-set keys win
+	DivisionExtraEnv   map[string]map[string]string // Keyed by lowercased division name, merged over ExtraEnv
+	Image              ImageConfig
+	DivisionImages     map[string]ImageConfig // Keyed by lowercased division name
+	Strategy           DeploymentStrategy
+	DivisionStrategies map[string]DeploymentStrategy // Keyed by lowercased division name
+	WorkloadKind       WorkloadKind
+	TVDelay            int    // SourceTV broadcast delay in seconds
+	TVPassword         string // Shared SourceTV password; empty generates one per round
+	TVTitle            string // SourceTV broadcast title; empty leaves the server default
+}
+
+// LeagueSettings overrides server settings for one league's matches. Zero
+// fields fall back to the global setting.
+type LeagueSettings struct {
+	TickRate   int
+	MaxPlayers int
+}
+
+// WorkloadKind is the controller that runs each game server pod.
+type WorkloadKind string
+
+const (
+	// WorkloadDeployment runs servers as Deployments.
+	WorkloadDeployment WorkloadKind = "Deployment"
+	// WorkloadStatefulSet runs servers as single-replica StatefulSets, giving the
+	// pod a stable name and volume claims across restarts.
+	WorkloadStatefulSet WorkloadKind = "StatefulSet"
+)
+
+// DeploymentStrategy is the Kubernetes Deployment update strategy for game servers.
+type DeploymentStrategy string
+
+const (
+	// StrategyRecreate stops the old pod before starting the new one, so an update
+	// disconnects players but never runs two servers on the same ports.
+	StrategyRecreate DeploymentStrategy = "Recreate"
+	// StrategyRollingUpdate starts the new pod first; only safe when the new pod
+	// can be scheduled alongside the old one.
+	StrategyRollingUpdate DeploymentStrategy = "RollingUpdate"
+)
+
+func parseDeploymentStrategy(raw string) (DeploymentStrategy, error) {
+	for _, strategy := range []DeploymentStrategy{StrategyRecreate, StrategyRollingUpdate} {
+		if strings.EqualFold(strings.TrimSpace(raw), string(strategy)) {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported deployment strategy %q", raw)
+}
+
+// AlphabetPresets are the named character sets SRCDS_PASSWORD_ALPHABET and
+// SRCDS_RCON_ALPHABET accept besides a literal alphabet.
+var AlphabetPresets = map[string]string{
+	// alphanumeric is the default.
+	"alphanumeric": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	// unambiguous drops characters that are easily confused when read aloud or
+	// typed from a screenshot: 0/O/o, 1/l/I.
+	"unambiguous": "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789",
+	// symbols adds punctuation that survives server.cfg and console quoting.
+	"symbols": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.+!@#%^*=",
+}
+
+// minAlphabetSize keeps literal alphabets from producing guessable passwords.
+const minAlphabetSize = 10
+
+// maxTeardownCountdown bounds how long a finished server may keep counting down
+// when no TEARDOWN_GRACE covers the countdown.
+const maxTeardownCountdown = 10 * time.Minute
+
+// parseAlphabet resolves a preset name or a literal alphabet. Literal alphabets
+// must have unique characters, which keeps generation unbiased, and may not
+// contain whitespace, quotes, semicolons or backslashes, which break the srcds
+// command line and config files.
+func parseAlphabet(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if preset, ok := AlphabetPresets[strings.ToLower(raw)]; ok {
+		return preset, nil
+	}
+	seen := map[rune]bool{}
+	for _, r := range raw {
+		if r > unicode.MaxASCII || unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(`"';\`, r) {
+			return "", fmt.Errorf("character %q is not allowed", r)
+		}
+		if seen[r] {
+			return "", fmt.Errorf("character %q appears more than once", r)
+		}
+		seen[r] = true
+	}
+	if len(seen) < minAlphabetSize {
+		return "", fmt.Errorf("%q is neither a preset nor an alphabet of at least %d characters", raw, minAlphabetSize)
+	}
+	return raw, nil
+}
+
+// ParseServerConfigTemplate parses SRCDS_SERVER_CONFIG_TEMPLATE_FILE contents.
+// References to unknown fields fail when the template is executed.
+func ParseServerConfigTemplate(text string) (*template.Template, error) {
+	return template.New("server-config").Option("missingkey=error").Parse(text)
+}
+
+// HostnamePlaceholders lists the fields SRCDS_HOSTNAME_TEMPLATE may reference as {name}.
+var HostnamePlaceholders = []string{"match_id", "round_id", "division", "map", "league"}
+
+var placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// validateHostnameTemplate rejects templates referencing unknown placeholders.
+func validateHostnameTemplate(template string) error {
+	for _, m := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		known := false
+		for _, name := range HostnamePlaceholders {
+			if m[1] == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown placeholder {%s}, expected one of %s", m[1], strings.Join(HostnamePlaceholders, ", "))
+		}
+	}
+	return nil
+}
+
+// ImageConfig overrides the SRCDS container image; empty fields keep the chart default.
+type ImageConfig struct {
+	Repository string
+	Tag        string
+}
+
+// IsZero reports whether no override is configured.
+func (i ImageConfig) IsZero() bool {
+	return i == ImageConfig{}
+}
+
+// Merge returns i with every field set in override taking precedence.
+func (i ImageConfig) Merge(override ImageConfig) ImageConfig {
+	out := i
+	if override.Repository != "" {
+		out.Repository = override.Repository
+	}
+	if override.Tag != "" {
+		out.Tag = override.Tag
+	}
+	return out
+}
+
+// ResourceConfig holds Kubernetes quantity strings for the SRCDS container.
+// Empty fields are left to the chart defaults.
+type ResourceConfig struct {
+	CPURequest    string
+	MemoryRequest string
+	CPULimit      string
+	MemoryLimit   string
+}
+
+// quantityPattern accepts the decimal and binary suffixes Kubernetes uses for CPU and memory.
+var quantityPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(m|k|M|G|T|P|E|Ki|Mi|Gi|Ti|Pi|Ei)?$`)
+
+// IsZero reports whether no requests or limits are configured.
+func (r ResourceConfig) IsZero() bool {
+	return r == ResourceConfig{}
+}
+
+// Merge returns r with every field set in override taking precedence.
+func (r ResourceConfig) Merge(override ResourceConfig) ResourceConfig {
+	out := r
+	if override.CPURequest != "" {
+		out.CPURequest = override.CPURequest
+	}
+	if override.MemoryRequest != "" {
+		out.MemoryRequest = override.MemoryRequest
+	}
+	if override.CPULimit != "" {
+		out.CPULimit = override.CPULimit
+	}
+	if override.MemoryLimit != "" {
+		out.MemoryLimit = override.MemoryLimit
+	}
+	return out
+}
+
+// Validate ensures every configured value parses as a Kubernetes quantity.
+func (r ResourceConfig) Validate() error {
+	fields := []struct{ name, value string }{
+		{"cpu request", r.CPURequest},
+		{"memory request", r.MemoryRequest},
+		{"cpu limit", r.CPULimit},
+		{"memory limit", r.MemoryLimit},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		if !quantityPattern.MatchString(field.value) {
+			return fmt.Errorf("invalid %s %q: expected a Kubernetes quantity such as 500m or 2Gi", field.name, field.value)
+		}
+	}
+	return nil
 }
 
 // SteamConfig configures Steam Web API integration for automatic token generation.
@@ -96,6 +647,8 @@ type SteamConfig struct {
 	EnableAutoTokens   bool
 	EnableTokenCleanup bool
 	TokenMemoTemplate  string
+	BaseURL            string        // Steam Web API root; empty uses the public API
+	TokenCheckInterval time.Duration // How often running servers' tokens are checked for expiry; 0 disables
 }
 
 // MatchConfig configures which matches should be reconciled.
@@ -104,13 +657,144 @@ type MatchConfig struct {
 	CompletedStatuses []int // Match statuses that indicate completion (should tear down servers)
 	DefaultMap        string
 	DivisionFilters   []string
+	IDAllowlist       []int // When non-empty, only these match IDs are reconciled
+	Order             MatchOrder
+	BatchLimit        int                 // Maximum matches fetched per tick; 0 means no limit
+	MaxServers        int                 // Maximum servers running at once; 0 means no limit
+	Priority          []PriorityCriterion // Order matches compete for MaxServers in, most significant first
+	DivisionPriority  map[string]int      // Keyed by lowercased division name; higher goes first, unlisted is 0
+	Lookahead         time.Duration       // Only fetch matches scheduled before now plus this; 0 fetches all
+	PrewarmLead       time.Duration       // Create a match's next server this long before its start, ready or not; 0 disables
+	RequireBothReady  bool                // Both teams must ready up before a server is created; otherwise one is enough
+	IdleTimeout       time.Duration       // Tear down servers with no human players for this long; 0 disables
+	TeardownGrace     time.Duration       // Keep a round's server this long after its outcome is recorded
+	RetainSecrets     time.Duration       // Keep a copy of each torn-down server's state secret this long; 0 disables
+	StripRetained     bool                // Drop passwords and tokens from retained state secrets
+	TeardownCountdown time.Duration       // Warn players over RCON this long before deleting a server; 0 disables
+	PlayerCounts      bool                // Query running servers over A2S and store live player counts
+	MapOverrides      bool                // Honor league_match_rounds.map_override ahead of the round's map_id
+	ValuesOverrides   bool                // Merge league_matches.values_override JSON over each round's chart values
+	SourceTVDetails   bool                // Store each server's SourceTV password and connect string in match details
+	ServerStatus      bool                // Store whether each server is running or unreachable in match details
+	RecordErrors      bool                // Store each match's last reconcile error in league_matches.reconcile_error
+	MapDrift          MapDriftPolicy
+	LimitStrategy     LimitStrategy
+	LeagueLimits      map[string]LimitStrategy // Keyed by lowercased league name, overrides LimitStrategy
+	Paused            bool                     // Start without creating new servers; toggled at runtime with SIGUSR1
 }
 
+// LimitStrategy selects how a match's win_limit is turned into gameplay limits.
+type LimitStrategy string
+
+const (
+	// LimitWinLimit passes win_limit through as WIN_LIMIT (first to X round wins).
+	LimitWinLimit LimitStrategy = "win-limit"
+	// LimitBestOf treats win_limit as a best-of-X round count: WIN_LIMIT is a majority
+	// of X and MAX_ROUNDS is X.
+	LimitBestOf LimitStrategy = "best-of"
+	// LimitRoundLimit plays exactly win_limit rounds: MAX_ROUNDS is win_limit and
+	// WIN_LIMIT is 0 so no score ends the match early.
+	LimitRoundLimit LimitStrategy = "round-limit"
+)
+
+// PriorityCriterion is one key matches are sorted by when MAX_CONCURRENT_SERVERS
+// forces a choice of which get servers.
+type PriorityCriterion string
+
+const (
+	// PriorityColumn puts higher league_matches.priority values first; NULL is 0.
+	PriorityColumn PriorityCriterion = "priority"
+	// PriorityDivision puts divisions with higher MATCH_DIVISION_PRIORITIES first.
+	PriorityDivision PriorityCriterion = "division"
+	// PriorityScheduled puts earlier league_matches.scheduled_at first; unscheduled last.
+	PriorityScheduled PriorityCriterion = "scheduled"
+)
+
+func parsePriority(raw string) ([]PriorityCriterion, error) {
+	var criteria []PriorityCriterion
+	for _, item := range parseStringSlice(raw) {
+		criterion := PriorityCriterion(strings.ToLower(item))
+		switch criterion {
+		case PriorityColumn, PriorityDivision, PriorityScheduled:
+			criteria = append(criteria, criterion)
+		default:
+			return nil, fmt.Errorf("unsupported criterion %q (want priority, division or scheduled)", item)
+		}
+	}
+	return criteria, nil
+}
+
+// PrioritizesBy reports whether matches are ordered by criterion, which only
+// happens while MAX_CONCURRENT_SERVERS caps the servers.
+func (m MatchConfig) PrioritizesBy(criterion PriorityCriterion) bool {
+	return m.MaxServers > 0 && slices.Contains(m.Priority, criterion)
+}
+
+// MapDriftPolicy selects what happens when a running server is on a different map
+// than the one the controller asked for, e.g. after a manual changelevel.
+type MapDriftPolicy string
+
+const (
+	// MapDriftOff does not query the running map.
+	MapDriftOff MapDriftPolicy = "off"
+	// MapDriftRecord stores the running map in matches_server_details.actual_map.
+	MapDriftRecord MapDriftPolicy = "record"
+	// MapDriftEnforce records the running map and changes level back to the desired one.
+	MapDriftEnforce MapDriftPolicy = "enforce"
+)
+
+func parseMapDriftPolicy(raw string) (MapDriftPolicy, error) {
+	policy := MapDriftPolicy(strings.ToLower(strings.TrimSpace(raw)))
+	switch policy {
+	case MapDriftOff, MapDriftRecord, MapDriftEnforce:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unsupported map drift policy %q", raw)
+}
+
+func parseLimitStrategy(raw string) (LimitStrategy, error) {
+	strategy := LimitStrategy(strings.ToLower(strings.TrimSpace(raw)))
+	switch strategy {
+	case LimitWinLimit, LimitBestOf, LimitRoundLimit:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unsupported limit strategy %q", raw)
+}
+
+// MatchOrder selects the order in which matches are fetched and reconciled.
+type MatchOrder string
+
+const (
+	// MatchOrderNone leaves ordering to the database.
+	MatchOrderNone MatchOrder = "none"
+	// MatchOrderID processes the oldest matches (lowest ID) first.
+	MatchOrderID MatchOrder = "id"
+	// MatchOrderScheduled processes matches by scheduled time, earliest first.
+	MatchOrderScheduled MatchOrder = "scheduled"
+)
+
 // NetworkingConfig controls Kubernetes networking knobs.
 type NetworkingConfig struct {
 	HostNetwork           bool
 	NodeIPPreference      NodeIPPreference
-	ExternalTrafficPolicy string
+	NodeIPFamily          NodeIPFamily
+	NodeIPOverride        string            // Advertised verbatim instead of discovering a node IP
+	NodeIPMap             map[string]string // Per-node advertised IPs, keyed by node name
+	ExternalTrafficPolicy string            // Cluster or Local; no effect on traffic with HostNetwork
+	ServiceAnnotations    map[string]string // Added to every server Service, e.g. for MetalLB or cloud LBs
+	DualStack             bool              // Request IPv4 and IPv6 Services and advertise the node's IPv6 address too
+	ProbeEnabled          bool              // Confirm new servers answer A2S_INFO at the advertised address
+	ProbeTimeout          time.Duration     // Bounds each A2S query
+}
+
+// parseTrafficPolicy accepts the Service externalTrafficPolicy values in any case.
+func parseTrafficPolicy(raw string) (string, error) {
+	for _, policy := range []string{"Cluster", "Local"} {
+		if strings.EqualFold(strings.TrimSpace(raw), policy) {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported external traffic policy %q (want Cluster or Local)", raw)
 }
 
 // NodeIPPreference indicates whether we should prefer external or internal IPs.
@@ -123,10 +807,33 @@ const (
 	NodeIPInternalOnly NodeIPPreference = "internal-only"
 )
 
+// NodeIPFamily selects which address family node IP discovery accepts.
+type NodeIPFamily string
+
+const (
+	// NodeIPFamilyIPv4 only accepts IPv4 node addresses.
+	NodeIPFamilyIPv4 NodeIPFamily = "ipv4"
+	// NodeIPFamilyIPv6 only accepts IPv6 node addresses.
+	NodeIPFamilyIPv6 NodeIPFamily = "ipv6"
+	// NodeIPFamilyDual accepts either family, preferring IPv4.
+	NodeIPFamilyDual NodeIPFamily = "dual"
+)
+
 // NotificationConfig controls optional user-facing alerts.
 type NotificationConfig struct {
 	Enabled    bool
 	LinkFormat string
+	Cooldown   time.Duration // Merge a user's notifications within this window into one; 0 disables
+}
+
+// ArtifactsConfig controls what happens to a server's demos and logs on teardown.
+type ArtifactsConfig struct {
+	// HostPathTemplate locates a release's writable tf/demos and tf/logs on its node;
+	// %s is replaced by the release name. Empty disables artifact tracking.
+	HostPathTemplate string
+	// ArchiveImage, when set, runs as a Job on the server's node with the artifact
+	// directory mounted at /artifacts so it can upload them before they are orphaned.
+	ArchiveImage string
 }
 
 // Load parses environment variables into a strongly typed Config.
@@ -141,19 +848,116 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid POLL_INTERVAL: %w", err)
 	}
 	cfg.PollInterval = interval
+	httpCfg, err := loadHTTPConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.HTTP = httpCfg
 
+	failureExit, err := getEnvInt("RECONCILE_FAILURE_EXIT_THRESHOLD", 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RECONCILE_FAILURE_EXIT_THRESHOLD: %w", err)
+	}
+	if failureExit < 0 {
+		return nil, errors.New("RECONCILE_FAILURE_EXIT_THRESHOLD must not be negative")
+	}
+	cfg.FailureExit = failureExit
+
+	createNamespace, err := getEnvBool("CREATE_NAMESPACE", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CREATE_NAMESPACE: %w", err)
+	}
+	cfg.CreateNamespace = createNamespace
+
+	cfg.CommonLabels, err = parseKeyValueMap(getEnv("COMMON_LABELS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid COMMON_LABELS: %w", err)
+	}
+	cfg.CommonAnnotations, err = parseKeyValueMap(getEnv("COMMON_ANNOTATIONS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid COMMON_ANNOTATIONS: %w", err)
+	}
+
+	forceConflicts, err := getEnvBool("APPLY_FORCE_CONFLICTS", true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPLY_FORCE_CONFLICTS: %w", err)
+	}
 	cfg.Chart = ChartConfig{
-		Path:       getEnv("CHART_PATH", "oci://ghcr.io/udl-tf/charts/tf2chart"),
-		ValuesFile: getEnv("CHART_VALUES_FILE", "./helm/values.yaml"),
+		Path:           getEnv("CHART_PATH", "oci://ghcr.io/udl-tf/charts/tf2chart"),
+		ValuesFile:     getEnv("CHART_VALUES_FILE", "./helm/values.yaml"),
+		FieldManager:   getEnv("APPLY_FIELD_MANAGER", "tourney-controller"),
+		ForceConflicts: forceConflicts,
+	}
+	modeCharts, err := parseDivisionKeyValues(getEnv("GAME_MODE_CHARTS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid GAME_MODE_CHARTS: %w", err)
+	}
+	cfg.Chart.Modes = make(map[string]ChartSource, len(modeCharts))
+	for mode, values := range modeCharts {
+		source := ChartSource{ValuesFile: cfg.Chart.ValuesFile}
+		for key, value := range values {
+			switch strings.ToLower(key) {
+			case "path":
+				source.Path = value
+			case "values":
+				source.ValuesFile = value
+			default:
+				return nil, fmt.Errorf("invalid GAME_MODE_CHARTS: unknown key %q for game mode %q", key, mode)
+			}
+		}
+		if source.Path == "" {
+			return nil, fmt.Errorf("invalid GAME_MODE_CHARTS: game mode %q has no path", mode)
+		}
+		cfg.Chart.Modes[mode] = source
+	}
+
+	cfg.Release = ReleaseConfig{
+		NameTemplate: getEnv("RELEASE_NAME_TEMPLATE", "{prefix}-{match_id}-r{round_id}"),
+		Prefix:       getEnv("RELEASE_NAME_PREFIX", "udl"),
+	}
+	if err := cfg.Release.validate(); err != nil {
+		return nil, fmt.Errorf("invalid RELEASE_NAME_TEMPLATE: %w", err)
+	}
+
+	dbPassword, err := getSecret("DB_PASSWORD")
+	if err != nil {
+		return nil, err
+	}
+
+	replicaDSN, err := getSecret("DB_REPLICA_DSN")
+	if err != nil {
+		return nil, err
 	}
 
 	db := DatabaseConfig{
-		Host:     getEnv("DB_HOST", "postgres"),
-		Port:     getEnv("DB_PORT", "5432"),
-		User:     getEnv("DB_USER", "postgres"),
-		Password: os.Getenv("DB_PASSWORD"),
-		Name:     getEnv("DB_NAME", "udl"),
-		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+		Host:       getEnv("DB_HOST", "postgres"),
+		Port:       getEnv("DB_PORT", "5432"),
+		User:       getEnv("DB_USER", "postgres"),
+		Password:   dbPassword,
+		Name:       getEnv("DB_NAME", "udl"),
+		SSLMode:    getEnv("DB_SSLMODE", "disable"),
+		ReplicaDSN: replicaDSN,
+	}
+
+	for _, tlsFile := range []struct {
+		env  string
+		dest *string
+	}{
+		{"DB_SSLCERT", &db.SSLCert},
+		{"DB_SSLKEY", &db.SSLKey},
+		{"DB_SSLROOTCERT", &db.SSLRootCert},
+	} {
+		path := getEnv(tlsFile.env, "")
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", tlsFile.env, err)
+		}
+		*tlsFile.dest = path
+	}
+	if (db.SSLCert == "") != (db.SSLKey == "") {
+		return nil, fmt.Errorf("DB_SSLCERT and DB_SSLKEY must be set together")
 	}
 
 	maxOpen, err := getEnvInt("DB_MAX_OPEN_CONNS", 10)
@@ -176,6 +980,35 @@ func Load() (*Config, error) {
 		}
 		db.ConnMaxLifetime = lifetime
 	}
+	cacheTTL, err := time.ParseDuration(getEnv("DB_LOOKUP_CACHE_TTL", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_LOOKUP_CACHE_TTL: %w", err)
+	}
+	db.LookupCacheTTL = cacheTTL
+
+	failureThreshold, err := getEnvInt("DB_FAILURE_THRESHOLD", 3)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_FAILURE_THRESHOLD: %w", err)
+	}
+	db.FailureThreshold = failureThreshold
+
+	maxBackoff, err := time.ParseDuration(getEnv("DB_MAX_BACKOFF", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_MAX_BACKOFF: %w", err)
+	}
+	db.MaxBackoff = maxBackoff
+
+	schema, err := loadSchemaMapping(getEnv("DB_SCHEMA_MAPPING_FILE", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_SCHEMA_MAPPING_FILE: %w", err)
+	}
+	db.Schema = schema
+
+	slowQuery, err := time.ParseDuration(getEnv("DB_SLOW_QUERY_THRESHOLD", "500ms"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_SLOW_QUERY_THRESHOLD: %w", err)
+	}
+	db.SlowQueryThreshold = slowQuery
 	cfg.Database = db
 
 	ports, err := loadPortConfig()
@@ -194,6 +1027,34 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid SRCDS_MAX_PLAYERS_OVERRIDE: %w", err)
 	}
 
+	leagueSettingsRaw, err := parseDivisionKeyValues(getEnv("SRCDS_LEAGUE_SETTINGS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_LEAGUE_SETTINGS: %w", err)
+	}
+	leagueSettings := make(map[int]LeagueSettings, len(leagueSettingsRaw))
+	for league, values := range leagueSettingsRaw {
+		leagueID, err := strconv.Atoi(league)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SRCDS_LEAGUE_SETTINGS: league %q is not a league ID", league)
+		}
+		var settings LeagueSettings
+		for key, raw := range values {
+			value, err := strconv.Atoi(raw)
+			if err != nil || value <= 0 {
+				return nil, fmt.Errorf("invalid SRCDS_LEAGUE_SETTINGS: %s for league %d must be a positive integer, got %q", key, leagueID, raw)
+			}
+			switch strings.ToLower(key) {
+			case "tickrate":
+				settings.TickRate = value
+			case "maxplayers":
+				settings.MaxPlayers = value
+			default:
+				return nil, fmt.Errorf("invalid SRCDS_LEAGUE_SETTINGS: unknown key %q for league %d", key, leagueID)
+			}
+		}
+		leagueSettings[leagueID] = settings
+	}
+
 	passwordLength, err := getEnvInt("SRCDS_PASSWORD_LENGTH", 10)
 	if err != nil {
 		return nil, fmt.Errorf("invalid SRCDS_PASSWORD_LENGTH: %w", err)
@@ -210,12 +1071,201 @@ func Load() (*Config, error) {
 		return nil, errors.New("SRCDS_RCON_LENGTH must be at least 12")
 	}
 
+	passwordAlphabet, err := parseAlphabet(getEnv("SRCDS_PASSWORD_ALPHABET", "alphanumeric"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_PASSWORD_ALPHABET: %w", err)
+	}
+
+	rconAlphabet, err := parseAlphabet(getEnv("SRCDS_RCON_ALPHABET", "alphanumeric"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_RCON_ALPHABET: %w", err)
+	}
+
+	// Deliberately not exposed in the Helm chart: anyone who knows the seed can
+	// compute every server's passwords.
+	unsafeSecretSeed := getEnv("UNSAFE_DETERMINISTIC_SECRETS_SEED", "")
+	if unsafeSecretSeed != "" {
+		klog.Warning("UNSAFE_DETERMINISTIC_SECRETS_SEED is set: server and RCON passwords are predictable, never use this in production")
+	}
+
+	stickyPasswords, err := getEnvBool("SRCDS_STICKY_PASSWORDS", true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_STICKY_PASSWORDS: %w", err)
+	}
+
+	rconRotation, err := time.ParseDuration(getEnv("RCON_ROTATION_INTERVAL", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RCON_ROTATION_INTERVAL: %w", err)
+	}
+
+	resources := ResourceConfig{
+		CPURequest:    getEnv("SRCDS_CPU_REQUEST", ""),
+		MemoryRequest: getEnv("SRCDS_MEM_REQUEST", ""),
+		CPULimit:      getEnv("SRCDS_CPU_LIMIT", ""),
+		MemoryLimit:   getEnv("SRCDS_MEM_LIMIT", ""),
+	}
+	if err := resources.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid SRCDS resources: %w", err)
+	}
+
+	divisionResources, err := parseDivisionResources(getEnv("SRCDS_DIVISION_RESOURCES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_DIVISION_RESOURCES: %w", err)
+	}
+
+	nodeSelector, err := parseKeyValueMap(getEnv("SRCDS_NODE_SELECTOR", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_NODE_SELECTOR: %w", err)
+	}
+
+	var affinity map[string]interface{}
+	if raw := getEnv("SRCDS_AFFINITY", ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &affinity); err != nil {
+			return nil, fmt.Errorf("invalid SRCDS_AFFINITY: %w", err)
+		}
+	}
+
+	var tolerations []interface{}
+	if raw := getEnv("SRCDS_TOLERATIONS", ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &tolerations); err != nil {
+			return nil, fmt.Errorf("invalid SRCDS_TOLERATIONS: %w", err)
+		}
+		for i, toleration := range tolerations {
+			if _, ok := toleration.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("invalid SRCDS_TOLERATIONS: entry %d is not an object", i)
+			}
+		}
+	}
+
+	var serverConfig string
+	if path := getEnv("SRCDS_SERVER_CONFIG_TEMPLATE_FILE", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SRCDS_SERVER_CONFIG_TEMPLATE_FILE: %w", err)
+		}
+		if _, err := ParseServerConfigTemplate(string(data)); err != nil {
+			return nil, fmt.Errorf("invalid SRCDS_SERVER_CONFIG_TEMPLATE_FILE: %w", err)
+		}
+		serverConfig = string(data)
+	}
+	serverConfigPath := getEnv("SRCDS_SERVER_CONFIG_PATH", "/tf/tf/cfg/server.cfg")
+	if !strings.HasPrefix(serverConfigPath, "/") {
+		return nil, fmt.Errorf("SRCDS_SERVER_CONFIG_PATH must be absolute, got %q", serverConfigPath)
+	}
+
+	hostnameTemplate := getEnv("SRCDS_HOSTNAME_TEMPLATE", "UDL.TF | {match_id} | Round #{round_id}")
+	if err := validateHostnameTemplate(hostnameTemplate); err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_HOSTNAME_TEMPLATE: %w", err)
+	}
+
+	extraEnv, err := parseKeyValueMap(getEnv("SRCDS_EXTRA_ENV", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_EXTRA_ENV: %w", err)
+	}
+
+	divisionExtraEnv, err := parseDivisionKeyValues(getEnv("SRCDS_DIVISION_EXTRA_ENV", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_DIVISION_EXTRA_ENV: %w", err)
+	}
+
+	divisionImageValues, err := parseDivisionKeyValues(getEnv("SRCDS_DIVISION_IMAGES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_DIVISION_IMAGES: %w", err)
+	}
+	divisionImages := make(map[string]ImageConfig, len(divisionImageValues))
+	for division, values := range divisionImageValues {
+		var image ImageConfig
+		for key, value := range values {
+			switch strings.ToLower(key) {
+			case "image":
+				image.Repository = value
+			case "tag":
+				image.Tag = value
+			default:
+				return nil, fmt.Errorf("invalid SRCDS_DIVISION_IMAGES: unknown key %q for division %q", key, division)
+			}
+		}
+		divisionImages[division] = image
+	}
+
+	strategy, err := parseDeploymentStrategy(getEnv("SRCDS_DEPLOYMENT_STRATEGY", string(StrategyRecreate)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_DEPLOYMENT_STRATEGY: %w", err)
+	}
+
+	divisionStrategiesRaw, err := parseKeyValueMap(getEnv("SRCDS_DIVISION_DEPLOYMENT_STRATEGIES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_DIVISION_DEPLOYMENT_STRATEGIES: %w", err)
+	}
+	divisionStrategies := make(map[string]DeploymentStrategy, len(divisionStrategiesRaw))
+	for division, raw := range divisionStrategiesRaw {
+		divisionStrategy, err := parseDeploymentStrategy(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SRCDS_DIVISION_DEPLOYMENT_STRATEGIES for %q: %w", division, err)
+		}
+		divisionStrategies[strings.ToLower(division)] = divisionStrategy
+	}
+
+	workloadKind := WorkloadKind(getEnv("SRCDS_WORKLOAD_KIND", string(WorkloadDeployment)))
+	switch {
+	case strings.EqualFold(string(workloadKind), string(WorkloadDeployment)):
+		workloadKind = WorkloadDeployment
+	case strings.EqualFold(string(workloadKind), string(WorkloadStatefulSet)):
+		workloadKind = WorkloadStatefulSet
+	default:
+		return nil, fmt.Errorf("unsupported SRCDS_WORKLOAD_KIND: %s", workloadKind)
+	}
+
+	staticToken, err := getSecret("SRCDS_STATIC_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+
+	tvDelay, err := getEnvInt("SRCDS_TV_DELAY", 90)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRCDS_TV_DELAY: %w", err)
+	}
+	if tvDelay < 0 {
+		return nil, fmt.Errorf("SRCDS_TV_DELAY must not be negative, got %d", tvDelay)
+	}
+	tvPassword, err := getSecret("SRCDS_TV_PASSWORD")
+	if err != nil {
+		return nil, err
+	}
+
 	cfg.SRCDS = SRCDSConfig{
 		TickRate:           tickRate,
 		MaxPlayersOverride: maxPlayersOverride,
-		StaticToken:        os.Getenv("SRCDS_STATIC_TOKEN"),
+		LeagueSettings:     leagueSettings,
+		StaticToken:        staticToken,
 		PasswordLength:     passwordLength,
 		RCONLength:         rconLength,
+		PasswordAlphabet:   passwordAlphabet,
+		RCONAlphabet:       rconAlphabet,
+		UnsafeSecretSeed:   unsafeSecretSeed,
+		StickyPasswords:    stickyPasswords,
+		ServerConfig:       serverConfig,
+		ServerConfigPath:   serverConfigPath,
+		RCONRotation:       rconRotation,
+		Resources:          resources,
+		DivisionResources:  divisionResources,
+		NodeSelector:       nodeSelector,
+		Affinity:           affinity,
+		Tolerations:        tolerations,
+		HostnameTemplate:   hostnameTemplate,
+		ExtraEnv:           extraEnv,
+		DivisionExtraEnv:   divisionExtraEnv,
+		Image: ImageConfig{
+			Repository: getEnv("SRCDS_IMAGE", ""),
+			Tag:        getEnv("SRCDS_IMAGE_TAG", ""),
+		},
+		DivisionImages:     divisionImages,
+		Strategy:           strategy,
+		DivisionStrategies: divisionStrategies,
+		WorkloadKind:       workloadKind,
+		TVDelay:            tvDelay,
+		TVPassword:         tvPassword,
+		TVTitle:            getEnv("SRCDS_TV_TITLE", ""),
 	}
 
 	steamAppID, err := getEnvInt("STEAM_APP_ID", 440)
@@ -233,12 +1283,31 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid STEAM_TOKEN_CLEANUP: %w", err)
 	}
 
+	steamTokenCheck, err := time.ParseDuration(getEnv("STEAM_TOKEN_CHECK_INTERVAL", "10m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid STEAM_TOKEN_CHECK_INTERVAL: %w", err)
+	}
+
+	steamBaseURL := getEnv("STEAM_API_BASE_URL", "")
+	if steamBaseURL != "" {
+		if u, err := url.Parse(steamBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid STEAM_API_BASE_URL: expected an absolute URL, got %q", steamBaseURL)
+		}
+	}
+
+	steamAPIKey, err := getSecret("STEAM_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+
 	cfg.Steam = SteamConfig{
-		APIKey:             os.Getenv("STEAM_API_KEY"),
+		APIKey:             steamAPIKey,
 		AppID:              steamAppID,
 		EnableAutoTokens:   steamAutoTokens,
 		EnableTokenCleanup: steamTokenCleanup,
 		TokenMemoTemplate:  getEnv("STEAM_TOKEN_MEMO_TEMPLATE", "UDL Match %d Round %d"),
+		BaseURL:            steamBaseURL,
+		TokenCheckInterval: steamTokenCheck,
 	}
 
 	statuses, err := parseIntSlice(getEnv("MATCH_STATUSES", "0"))
@@ -259,11 +1328,196 @@ func Load() (*Config, error) {
 		divisionFilters[i] = strings.ToLower(divisionFilters[i])
 	}
 
+	idAllowlist, err := parseIntSlice(getEnv("MATCH_ID_ALLOWLIST", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_ID_ALLOWLIST: %w", err)
+	}
+
+	matchOrder := MatchOrder(strings.ToLower(getEnv("MATCH_ORDER", string(MatchOrderID))))
+	if matchOrder != MatchOrderNone && matchOrder != MatchOrderID && matchOrder != MatchOrderScheduled {
+		return nil, fmt.Errorf("unsupported MATCH_ORDER: %s", matchOrder)
+	}
+
+	batchLimit, err := getEnvInt("MATCH_BATCH_LIMIT", 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_BATCH_LIMIT: %w", err)
+	}
+	if batchLimit < 0 {
+		return nil, errors.New("MATCH_BATCH_LIMIT must not be negative")
+	}
+
+	maxServers, err := getEnvInt("MAX_CONCURRENT_SERVERS", 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_CONCURRENT_SERVERS: %w", err)
+	}
+	if maxServers < 0 {
+		return nil, errors.New("MAX_CONCURRENT_SERVERS must not be negative")
+	}
+
+	priority, err := parsePriority(getEnv("MATCH_PRIORITY", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_PRIORITY: %w", err)
+	}
+	divisionPriorityRaw, err := parseKeyValueMap(getEnv("MATCH_DIVISION_PRIORITIES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_DIVISION_PRIORITIES: %w", err)
+	}
+	divisionPriority := make(map[string]int, len(divisionPriorityRaw))
+	for division, raw := range divisionPriorityRaw {
+		weight, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MATCH_DIVISION_PRIORITIES for %q: %w", division, err)
+		}
+		divisionPriority[strings.ToLower(division)] = weight
+	}
+
+	lookahead, err := time.ParseDuration(getEnv("MATCH_LOOKAHEAD", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_LOOKAHEAD: %w", err)
+	}
+	if lookahead < 0 {
+		return nil, errors.New("MATCH_LOOKAHEAD must not be negative")
+	}
+
+	prewarmLead, err := time.ParseDuration(getEnv("PREWARM_LEAD", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PREWARM_LEAD: %w", err)
+	}
+	if prewarmLead < 0 {
+		return nil, errors.New("PREWARM_LEAD must not be negative")
+	}
+
+	requireBothReady, err := getEnvBool("REQUIRE_BOTH_READY", true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REQUIRE_BOTH_READY: %w", err)
+	}
+
+	idleTimeout, err := time.ParseDuration(getEnv("IDLE_TIMEOUT", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IDLE_TIMEOUT: %w", err)
+	}
+
+	teardownGrace, err := time.ParseDuration(getEnv("TEARDOWN_GRACE", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TEARDOWN_GRACE: %w", err)
+	}
+
+	retainSecrets, err := time.ParseDuration(getEnv("RETAIN_SECRETS_AFTER_TEARDOWN", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RETAIN_SECRETS_AFTER_TEARDOWN: %w", err)
+	}
+	if retainSecrets < 0 {
+		return nil, errors.New("RETAIN_SECRETS_AFTER_TEARDOWN must not be negative")
+	}
+	stripRetainedPasswords, err := getEnvBool("RETAIN_SECRETS_STRIP_PASSWORDS", true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RETAIN_SECRETS_STRIP_PASSWORDS: %w", err)
+	}
+
+	teardownCountdown, err := time.ParseDuration(getEnv("TEARDOWN_COUNTDOWN", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TEARDOWN_COUNTDOWN: %w", err)
+	}
+	if teardownCountdown < 0 {
+		return nil, errors.New("TEARDOWN_COUNTDOWN must not be negative")
+	}
+	if teardownGrace > 0 && teardownCountdown > teardownGrace {
+		return nil, fmt.Errorf("TEARDOWN_COUNTDOWN %v must not exceed TEARDOWN_GRACE %v", teardownCountdown, teardownGrace)
+	}
+	if teardownCountdown > maxTeardownCountdown {
+		return nil, fmt.Errorf("TEARDOWN_COUNTDOWN %v must not exceed %v", teardownCountdown, maxTeardownCountdown)
+	}
+
+	playerCounts, err := getEnvBool("PLAYER_COUNTS_ENABLED", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PLAYER_COUNTS_ENABLED: %w", err)
+	}
+
+	mapOverrides, err := getEnvBool("MATCH_MAP_OVERRIDES", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_MAP_OVERRIDES: %w", err)
+	}
+
+	valuesOverrides, err := getEnvBool("MATCH_VALUES_OVERRIDES", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_VALUES_OVERRIDES: %w", err)
+	}
+
+	sourceTVDetails, err := getEnvBool("MATCH_SOURCETV_DETAILS", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_SOURCETV_DETAILS: %w", err)
+	}
+
+	serverStatus, err := getEnvBool("MATCH_SERVER_STATUS", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_SERVER_STATUS: %w", err)
+	}
+
+	recordErrors, err := getEnvBool("MATCH_RECONCILE_ERRORS", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_RECONCILE_ERRORS: %w", err)
+	}
+
+	limitStrategy, err := parseLimitStrategy(getEnv("MATCH_LIMIT_STRATEGY", string(LimitWinLimit)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_LIMIT_STRATEGY: %w", err)
+	}
+
+	mapDrift, err := parseMapDriftPolicy(getEnv("MAP_DRIFT_POLICY", string(MapDriftOff)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAP_DRIFT_POLICY: %w", err)
+	}
+
+	leagueLimitsRaw, err := parseKeyValueMap(getEnv("MATCH_LEAGUE_LIMIT_STRATEGIES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MATCH_LEAGUE_LIMIT_STRATEGIES: %w", err)
+	}
+	leagueLimits := make(map[string]LimitStrategy, len(leagueLimitsRaw))
+	for league, raw := range leagueLimitsRaw {
+		strategy, err := parseLimitStrategy(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MATCH_LEAGUE_LIMIT_STRATEGIES for %q: %w", league, err)
+		}
+		leagueLimits[strings.ToLower(league)] = strategy
+	}
+
+	paused, err := getEnvBool("PAUSED", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PAUSED: %w", err)
+	}
+
 	cfg.Match = MatchConfig{
 		TargetStatuses:    statuses,
 		CompletedStatuses: completedStatuses,
 		DefaultMap:        getEnv("DEFAULT_MAP", "tfdb_octagon_odb_a1"),
 		DivisionFilters:   divisionFilters,
+		IDAllowlist:       idAllowlist,
+		Order:             matchOrder,
+		BatchLimit:        batchLimit,
+		MaxServers:        maxServers,
+		Priority:          priority,
+		DivisionPriority:  divisionPriority,
+		Lookahead:         lookahead,
+		PrewarmLead:       prewarmLead,
+		RequireBothReady:  requireBothReady,
+		IdleTimeout:       idleTimeout,
+		TeardownGrace:     teardownGrace,
+		RetainSecrets:     retainSecrets,
+		StripRetained:     stripRetainedPasswords,
+		TeardownCountdown: teardownCountdown,
+		PlayerCounts:      playerCounts,
+		MapOverrides:      mapOverrides,
+		ValuesOverrides:   valuesOverrides,
+		SourceTVDetails:   sourceTVDetails,
+		ServerStatus:      serverStatus,
+		RecordErrors:      recordErrors,
+		MapDrift:          mapDrift,
+		LimitStrategy:     limitStrategy,
+		LeagueLimits:      leagueLimits,
+		Paused:            paused,
+	}
+	if !ValidMapName(cfg.Match.DefaultMap) {
+		return nil, fmt.Errorf("invalid DEFAULT_MAP %q: map names may only contain letters, digits, '_' and '-'", cfg.Match.DefaultMap)
 	}
 
 	hostNetwork, err := getEnvBool("HOST_NETWORK", false)
@@ -276,12 +1530,81 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("unsupported NODE_IP_PREFERENCE: %s", nodePref)
 	}
 
-	externalPolicy := getEnv("SERVICE_EXTERNAL_TRAFFIC_POLICY", "Cluster")
+	nodeFamily := NodeIPFamily(strings.ToLower(getEnv("NODE_IP_FAMILY", string(NodeIPFamilyIPv4))))
+	if nodeFamily != NodeIPFamilyIPv4 && nodeFamily != NodeIPFamilyIPv6 && nodeFamily != NodeIPFamilyDual {
+		return nil, fmt.Errorf("unsupported NODE_IP_FAMILY: %s", nodeFamily)
+	}
+
+	// Both are published to players as the server address, so a typo must not
+	// get past startup.
+	nodeIPOverride := getEnv("NODE_IP_OVERRIDE", "")
+	if nodeIPOverride != "" && net.ParseIP(nodeIPOverride) == nil {
+		return nil, fmt.Errorf("invalid NODE_IP_OVERRIDE: %q is not an IP address", nodeIPOverride)
+	}
+	nodeIPMap, err := parseKeyValueMap(getEnv("NODE_IP_MAP", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NODE_IP_MAP: %w", err)
+	}
+	for node, addr := range nodeIPMap {
+		if net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("invalid NODE_IP_MAP: %q for node %s is not an IP address", addr, node)
+		}
+	}
+
+	externalPolicy, err := parseTrafficPolicy(getEnv("SERVICE_EXTERNAL_TRAFFIC_POLICY", "Cluster"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SERVICE_EXTERNAL_TRAFFIC_POLICY: %w", err)
+	}
+	if hostNetwork && externalPolicy == "Local" {
+		klog.Warning("SERVICE_EXTERNAL_TRAFFIC_POLICY=Local has no effect with HOST_NETWORK: players reach the pod directly and source IPs are already preserved")
+	}
+	// A host-network pod binds its ports on the node, so a rolling update's new
+	// pod could never start next to the old one and the rollout would hang.
+	if hostNetwork {
+		if cfg.SRCDS.Strategy == StrategyRollingUpdate {
+			return nil, errors.New("SRCDS_DEPLOYMENT_STRATEGY=RollingUpdate cannot be used with HOST_NETWORK, use Recreate")
+		}
+		for division, strategy := range cfg.SRCDS.DivisionStrategies {
+			if strategy == StrategyRollingUpdate {
+				return nil, fmt.Errorf("SRCDS_DIVISION_DEPLOYMENT_STRATEGIES sets RollingUpdate for %q, which cannot be used with HOST_NETWORK, use Recreate", division)
+			}
+		}
+	}
+
+	serviceAnnotations, err := parseKeyValueMap(getEnv("SERVICE_ANNOTATIONS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SERVICE_ANNOTATIONS: %w", err)
+	}
+
+	dualStack, err := getEnvBool("SERVICE_DUAL_STACK", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SERVICE_DUAL_STACK: %w", err)
+	}
+	if dualStack && nodeFamily == NodeIPFamilyIPv6 {
+		return nil, fmt.Errorf("SERVICE_DUAL_STACK needs NODE_IP_FAMILY ipv4 or dual: the IPv4 address is advertised first and the IPv6 one alongside it")
+	}
+
+	probeEnabled, err := getEnvBool("A2S_PROBE_ENABLED", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid A2S_PROBE_ENABLED: %w", err)
+	}
+
+	probeTimeout, err := time.ParseDuration(getEnv("A2S_PROBE_TIMEOUT", "2s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid A2S_PROBE_TIMEOUT: %w", err)
+	}
 
 	cfg.Networking = NetworkingConfig{
 		HostNetwork:           hostNetwork,
 		NodeIPPreference:      nodePref,
+		NodeIPFamily:          nodeFamily,
+		NodeIPOverride:        nodeIPOverride,
+		NodeIPMap:             nodeIPMap,
 		ExternalTrafficPolicy: externalPolicy,
+		ServiceAnnotations:    serviceAnnotations,
+		DualStack:             dualStack,
+		ProbeEnabled:          probeEnabled,
+		ProbeTimeout:          probeTimeout,
 	}
 
 	notifyEnabled, err := getEnvBool("NOTIFICATIONS_ENABLED", true)
@@ -289,9 +1612,30 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid NOTIFICATIONS_ENABLED: %w", err)
 	}
 
+	notifyCooldown, err := time.ParseDuration(getEnv("NOTIFICATIONS_COOLDOWN", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NOTIFICATIONS_COOLDOWN: %w", err)
+	}
+
 	cfg.Notifications = NotificationConfig{
 		Enabled:    notifyEnabled,
 		LinkFormat: getEnv("NOTIFICATIONS_LINK_FORMAT", "/matches/%d"),
+		Cooldown:   notifyCooldown,
+	}
+
+	cfg.Artifacts = ArtifactsConfig{
+		HostPathTemplate: getEnv("ARTIFACTS_HOST_PATH_TEMPLATE", ""),
+		ArchiveImage:     getEnv("ARTIFACTS_ARCHIVE_IMAGE", ""),
+	}
+	if cfg.Artifacts.ArchiveImage != "" && cfg.Artifacts.HostPathTemplate == "" {
+		return nil, errors.New("ARTIFACTS_ARCHIVE_IMAGE requires ARTIFACTS_HOST_PATH_TEMPLATE")
+	}
+	if template := cfg.Artifacts.HostPathTemplate; template != "" {
+		// %% is a literal percent sign; any other verb would be filled with garbage.
+		verbs := strings.ReplaceAll(template, "%%", "")
+		if strings.Count(verbs, "%s") != 1 || strings.Count(verbs, "%") != 1 {
+			return nil, fmt.Errorf("ARTIFACTS_HOST_PATH_TEMPLATE %q must contain %%s exactly once and no other verbs", template)
+		}
 	}
 
 	return cfg, nil
@@ -318,7 +1662,25 @@ func loadPortConfig() (*PortsConfig, error) {
 		return nil, fmt.Errorf("invalid PORT_RANGE_STEAM: %w", err)
 	}
 
-	return &PortsConfig{Game: game, SourceTV: sourceTV, Client: client, Steam: steam}, nil
+	mode := PortAllocationMode(strings.ToLower(getEnv("PORT_ALLOCATION_MODE", string(PortAllocationRanges))))
+	if mode != PortAllocationRanges && mode != PortAllocationContiguous {
+		return nil, fmt.Errorf("unsupported PORT_ALLOCATION_MODE: %s", mode)
+	}
+	if mode == PortAllocationContiguous && game.Size() < ContiguousBlockSize {
+		return nil, fmt.Errorf("PORT_RANGE_GAME must hold at least %d ports in contiguous mode", ContiguousBlockSize)
+	}
+
+	cfg := &PortsConfig{Mode: mode, Game: game, SourceTV: sourceTV, Client: client, Steam: steam}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	for _, named := range cfg.Ranges() {
+		if size := named.Range.Size(); size < minRecommendedPorts {
+			klog.Warningf("port range %s (%d-%d) only has %d ports, fewer than the recommended %d",
+				named.Name, named.Range.Start, named.Range.End, size, minRecommendedPorts)
+		}
+	}
+	return cfg, nil
 }
 
 func parsePortRange(raw string) (PortRange, error) {
@@ -341,6 +1703,85 @@ func parsePortRange(raw string) (PortRange, error) {
 	return r, nil
 }
 
+// parseDivisionResources reads per-division overrides in the form
+// "division:cpu_request=500m,mem_limit=2Gi;other:cpu_limit=2".
+func parseDivisionResources(raw string) (map[string]ResourceConfig, error) {
+	out := map[string]ResourceConfig{}
+	for _, entry := range strings.Split(strings.TrimSpace(raw), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		division, settings, ok := strings.Cut(entry, ":")
+		division = strings.ToLower(strings.TrimSpace(division))
+		if !ok || division == "" {
+			return nil, fmt.Errorf("expected division:key=value, got %q", entry)
+		}
+
+		var res ResourceConfig
+		for _, setting := range parseStringSlice(settings) {
+			key, value, ok := strings.Cut(setting, "=")
+			if !ok {
+				return nil, fmt.Errorf("expected key=value for division %q, got %q", division, setting)
+			}
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "cpu_request":
+				res.CPURequest = value
+			case "mem_request":
+				res.MemoryRequest = value
+			case "cpu_limit":
+				res.CPULimit = value
+			case "mem_limit":
+				res.MemoryLimit = value
+			default:
+				return nil, fmt.Errorf("unknown resource key %q for division %q", key, division)
+			}
+		}
+		if err := res.Validate(); err != nil {
+			return nil, fmt.Errorf("division %q: %w", division, err)
+		}
+		out[division] = res
+	}
+	return out, nil
+}
+
+// parseDivisionKeyValues reads "division:key=value,key=value;division2:key=value".
+func parseDivisionKeyValues(raw string) (map[string]map[string]string, error) {
+	out := map[string]map[string]string{}
+	for _, entry := range strings.Split(strings.TrimSpace(raw), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		division, settings, ok := strings.Cut(entry, ":")
+		division = strings.ToLower(strings.TrimSpace(division))
+		if !ok || division == "" {
+			return nil, fmt.Errorf("expected division:key=value, got %q", entry)
+		}
+		values, err := parseKeyValueMap(settings)
+		if err != nil {
+			return nil, fmt.Errorf("division %q: %w", division, err)
+		}
+		out[division] = values
+	}
+	return out, nil
+}
+
+// parseKeyValueMap reads comma-separated key=value pairs.
+func parseKeyValueMap(raw string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, pair := range parseStringSlice(raw) {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		out[key] = strings.TrimSpace(value)
+	}
+	return out, nil
+}
+
 func getEnv(key, fallback string) string {
 	value := strings.TrimSpace(os.Getenv(key))
 	if value == "" {
@@ -349,6 +1790,20 @@ func getEnv(key, fallback string) string {
 	return value
 }
 
+// getSecret reads a secret from the file named by key+"_FILE", trimming the
+// trailing newline, and falls back to the key itself when no file is set.
+func getSecret(key string) (string, error) {
+	path := getEnv(key+"_FILE", "")
+	if path == "" {
+		return os.Getenv(key), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s_FILE: %w", key, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
 func getEnvInt(key string, fallback int) (int, error) {
 	raw := strings.TrimSpace(os.Getenv(key))
 	if raw == "" {
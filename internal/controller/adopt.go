@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+	"github.com/UDL-TF/TourneyController/internal/ports"
+)
+
+// adoptServer rebuilds the state of a round's server that runs without a state
+// secret, e.g. one deployed by hand while the controller was down, from its
+// workload's pod template, so ensureRound takes it over on the same ports and
+// passwords instead of colliding with it. It returns nil when the round has no
+// workload. The next apply brings the workload in line with the chart, which
+// only restarts it if its spec differs.
+func (c *Controller) adoptServer(ctx context.Context, match database.Match, round database.MatchRound, releaseName string) (*serverState, error) {
+	name, template, err := c.findRoundWorkload(ctx, match.ID, round.ID, releaseName)
+	if err != nil || template == nil {
+		return nil, err
+	}
+	if name != releaseName {
+		// Applying the chart would start a second server next to it on the
+		// same ports.
+		return nil, fmt.Errorf("workload %s is labelled for match %d round %d but is not named %s; delete or rename it so it can be adopted", name, match.ID, round.ID, releaseName)
+	}
+
+	env := map[string]string{}
+	for _, container := range template.Spec.Containers {
+		for _, v := range container.Env {
+			if v.ValueFrom == nil {
+				env[v.Name] = v.Value
+			}
+		}
+	}
+	var assign ports.Assignment
+	for key, port := range map[string]*int{
+		"SRCDS_PORT":        &assign.Game,
+		"SRCDS_TV_PORT":     &assign.SourceTV,
+		"SRCDS_CLIENT_PORT": &assign.Client,
+		"SRCDS_STEAM_PORT":  &assign.Steam,
+	} {
+		if *port, err = strconv.Atoi(env[key]); err != nil || *port <= 0 {
+			return nil, fmt.Errorf("cannot adopt workload %s: %s is %q, want a port", name, key, env[key])
+		}
+	}
+	if env["SRCDS_PW"] == "" || env["SRCDS_RCONPW"] == "" {
+		return nil, fmt.Errorf("cannot adopt workload %s: SRCDS_PW and SRCDS_RCONPW must be set", name)
+	}
+
+	klog.Infof("adopting existing workload %s for match %d round %d", name, match.ID, round.ID)
+	return &serverState{
+		ReleaseName: releaseName,
+		Ports:       assign,
+		Password:    env["SRCDS_PW"],
+		RCON:        env["SRCDS_RCONPW"],
+		Map:         env["SRCDS_STARTMAP"],
+		Token:       env["SRCDS_TOKEN"],
+		TVPassword:  env["SRCDS_TV_PASSWORD"],
+	}, nil
+}
+
+// findRoundWorkload returns the Deployment or StatefulSet serving a round: the one
+// named after its release, or else one labelled with its match and round IDs. The
+// template is nil when there is none.
+func (c *Controller) findRoundWorkload(ctx context.Context, matchID, roundID int, releaseName string) (string, *corev1.PodTemplateSpec, error) {
+	apps := c.clientset.AppsV1()
+	if d, err := apps.Deployments(c.cfg.Namespace).Get(ctx, releaseName, metav1.GetOptions{}); err == nil {
+		return d.Name, &d.Spec.Template, nil
+	} else if !k8serrors.IsNotFound(err) {
+		return "", nil, fmt.Errorf("get deployment %s: %w", releaseName, err)
+	}
+	if s, err := apps.StatefulSets(c.cfg.Namespace).Get(ctx, releaseName, metav1.GetOptions{}); err == nil {
+		return s.Name, &s.Spec.Template, nil
+	} else if !k8serrors.IsNotFound(err) {
+		return "", nil, fmt.Errorf("get statefulset %s: %w", releaseName, err)
+	}
+
+	selector := metav1.ListOptions{LabelSelector: fmt.Sprintf("udl.tf/match-id=%d,udl.tf/round-id=%d", matchID, roundID)}
+	deployments, err := apps.Deployments(c.cfg.Namespace).List(ctx, selector)
+	if err != nil {
+		return "", nil, fmt.Errorf("list deployments: %w", err)
+	}
+	for _, d := range deployments.Items {
+		return d.Name, &d.Spec.Template, nil
+	}
+	statefulSets, err := apps.StatefulSets(c.cfg.Namespace).List(ctx, selector)
+	if err != nil {
+		return "", nil, fmt.Errorf("list statefulsets: %w", err)
+	}
+	for _, s := range statefulSets.Items {
+		return s.Name, &s.Spec.Template, nil
+	}
+	return "", nil, nil
+}
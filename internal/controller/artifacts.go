@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+// archiveJobTTL keeps finished archive Jobs around long enough to read their logs.
+const archiveJobTTL = int32(24 * 60 * 60)
+
+// archiveArtifacts records where a release's demos and logs live and, when an
+// archive image is configured, starts a Job on the same node to upload them. It
+// must run before the release is deleted, while the pod still tells us its node.
+// A teardown retried after a later failure finds its Job already started.
+func (c *Controller) archiveArtifacts(ctx context.Context, ref ServerRef, releaseName string) error {
+	if c.cfg.Artifacts.HostPathTemplate == "" {
+		return nil
+	}
+
+	nodeName, err := c.releaseNodeName(ctx, releaseName)
+	if err != nil {
+		return err
+	}
+	if nodeName == "" {
+		klog.V(2).Infof("no scheduled pod for %s, skipping artifact archival", releaseName)
+		return nil
+	}
+
+	hostPath := fmt.Sprintf(c.cfg.Artifacts.HostPathTemplate, releaseName)
+	if err := c.repo.RecordMatchArtifacts(ctx, database.MatchArtifacts{
+		MatchID:  ref.MatchID,
+		RoundID:  ref.RoundID,
+		NodeName: nodeName,
+		HostPath: hostPath,
+	}); err != nil {
+		return err
+	}
+
+	if c.cfg.Artifacts.ArchiveImage == "" {
+		return nil
+	}
+	job := c.archiveJob(ref, releaseName, nodeName, hostPath)
+	if _, err := c.clientset.BatchV1().Jobs(c.cfg.Namespace).Create(ctx, job, metav1.CreateOptions{}); err != nil {
+		if k8serrors.IsAlreadyExists(err) {
+			klog.V(2).Infof("artifact archive job %s already started", job.Name)
+			return nil
+		}
+		return fmt.Errorf("create archive job: %w", err)
+	}
+	klog.Infof("started artifact archive job %s for match %d round %d", job.Name, ref.MatchID, ref.RoundID)
+	return nil
+}
+
+func (c *Controller) releaseNodeName(ctx context.Context, releaseName string) (string, error) {
+	pods, err := c.clientset.CoreV1().Pods(c.cfg.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app.kubernetes.io/instance=%s", releaseName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("list pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" {
+			return pod.Spec.NodeName, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *Controller) archiveJob(ref ServerRef, releaseName, nodeName, hostPath string) *batchv1.Job {
+	ttl := archiveJobTTL
+	backoff := int32(2)
+	hostPathType := corev1.HostPathDirectory
+	labels := map[string]string{
+		"app.kubernetes.io/name":       "tourney-artifact-archive",
+		"app.kubernetes.io/managed-by": "tourney-controller",
+		"udl.tf/match-id":              strconv.Itoa(ref.MatchID),
+		"udl.tf/round-id":              strconv.Itoa(ref.RoundID),
+	}
+	for k, v := range c.cfg.CommonLabels {
+		if _, ok := labels[k]; !ok {
+			labels[k] = v
+		}
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        releaseName + "-archive",
+			Namespace:   c.cfg.Namespace,
+			Labels:      labels,
+			Annotations: c.cfg.CommonAnnotations,
+		},
+		Spec: batchv1.JobSpec{
+			TTLSecondsAfterFinished: &ttl,
+			BackoffLimit:            &backoff,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					NodeName:      nodeName,
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:  "archive",
+						Image: c.cfg.Artifacts.ArchiveImage,
+						Env: []corev1.EnvVar{
+							{Name: "MATCH_ID", Value: strconv.Itoa(ref.MatchID)},
+							{Name: "ROUND_ID", Value: strconv.Itoa(ref.RoundID)},
+							{Name: "RELEASE_NAME", Value: releaseName},
+							{Name: "ARTIFACTS_PATH", Value: "/artifacts"},
+						},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "artifacts",
+							MountPath: "/artifacts",
+							ReadOnly:  true,
+						}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "artifacts",
+						VolumeSource: corev1.VolumeSource{
+							HostPath: &corev1.HostPathVolumeSource{Path: hostPath, Type: &hostPathType},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOrphanCleanupArchivesCompletedMatch(t *testing.T) {
+	tc := newTestController(t)
+	tc.cfg.Artifacts.HostPathTemplate = "/srv/tf/%s"
+	tc.cfg.Artifacts.ArchiveImage = "archive:latest"
+	tc.reconcileOK(t)
+
+	// A completed match is no longer fetched, so only the orphan cleanup sees it.
+	tc.repo.mu.Lock()
+	match := tc.repo.Matches[testMatchID]
+	match.Status = tc.cfg.Match.CompletedStatuses[0]
+	tc.repo.Matches[testMatchID] = match
+	tc.repo.mu.Unlock()
+	tc.reconcileOK(t)
+
+	if _, ok := tc.details(); ok {
+		t.Fatal("server of a completed match was kept")
+	}
+	releaseName := tc.releaseName(testMatchID, testRoundID)
+	artifacts, ok := tc.repo.Artifacts[[2]int{testMatchID, testRoundID}]
+	if !ok {
+		t.Fatal("artifacts of a completed match were not recorded")
+	}
+	if artifacts.NodeName != "node-a" || artifacts.HostPath != "/srv/tf/"+releaseName {
+		t.Errorf("recorded artifacts %+v, want node-a and the release's host path", artifacts)
+	}
+
+	jobs, err := tc.clientset.BatchV1().Jobs(tc.cfg.Namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(jobs.Items) != 1 {
+		t.Errorf("started %d archive jobs, want 1", len(jobs.Items))
+	}
+}
+
+func TestArchiveArtifactsRetryReusesJob(t *testing.T) {
+	tc := newTestController(t)
+	tc.cfg.Artifacts.HostPathTemplate = "/srv/tf/%s"
+	tc.cfg.Artifacts.ArchiveImage = "archive:latest"
+	tc.reconcileOK(t)
+
+	// A teardown that failed after archiving is retried while the pod still runs.
+	ref := ServerRef{MatchID: testMatchID, RoundID: testRoundID}
+	releaseName := tc.releaseName(testMatchID, testRoundID)
+	for attempt := 1; attempt <= 2; attempt++ {
+		if err := tc.archiveArtifacts(context.Background(), ref, releaseName); err != nil {
+			t.Fatalf("attempt %d: %v", attempt, err)
+		}
+	}
+	jobs, err := tc.clientset.BatchV1().Jobs(tc.cfg.Namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(jobs.Items) != 1 || jobs.Items[0].Name != releaseName+"-archive" {
+		t.Errorf("started %d archive jobs, want one named %s-archive", len(jobs.Items), releaseName)
+	}
+}
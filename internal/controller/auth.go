@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireAuth rejects requests that are not authenticated with ADMIN_TOKEN or a
+// verified client certificate. Every mutating endpoint goes through it, and
+// read-only ones do too with HTTP_AUTH_READ.
+func (c *Controller) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !c.authenticated(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// readOnly gates a read-only endpoint behind requireAuth when HTTP_AUTH_READ is set.
+func (c *Controller) readOnly(next http.HandlerFunc) http.HandlerFunc {
+	if c.cfg.HTTP.AuthRead {
+		return c.requireAuth(next)
+	}
+	return next
+}
+
+// authenticated reports whether r presented a client certificate the TLS
+// handshake verified against HTTP_CLIENT_CA_FILE, or "Authorization: Bearer
+// <ADMIN_TOKEN>".
+func (c *Controller) authenticated(r *http.Request) bool {
+	if c.cfg.HTTP.ClientCAFile != "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true
+	}
+	token := c.cfg.HTTP.AdminToken
+	presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// reserveServer admits a new server for ref under MAX_CONCURRENT_SERVERS. Once
+// the cap is reached the round is queued like one waiting for ports, so it is
+// among the first retried after teardowns free capacity, and ErrCapacityReached
+// is returned. An admitted server counts against the cap once admitServer is
+// called for it.
+func (c *Controller) reserveServer(ctx context.Context, ref ServerRef) error {
+	limit := c.cfg.Match.MaxServers
+	if limit <= 0 {
+		return nil
+	}
+	running, err := c.runningServers(ctx)
+	if err != nil {
+		return fmt.Errorf("count running servers: %w", err)
+	}
+	if running >= limit {
+		if _, queued := c.waitingForCapacity[ref]; !queued {
+			c.waitingForCapacity[ref] = time.Now()
+		}
+		c.tick.throttle()
+		c.tick.queue(ref)
+		return fmt.Errorf("%w: %d of %d servers running", ErrCapacityReached, running, limit)
+	}
+	delete(c.waitingForCapacity, ref)
+	return nil
+}
+
+// admitServer counts a server reserveServer let through once its ports are
+// allocated, so a round that fails before then does not hold a slot for the rest
+// of the tick.
+func (c *Controller) admitServer() {
+	if c.tick != nil && c.tick.serversCounted {
+		c.tick.servers++
+	}
+}
+
+// runningServers counts the managed server workloads. Within a tick they are
+// listed once and servers admitted since are added, so one tick cannot overshoot
+// the cap before its new workloads show up in the API.
+func (c *Controller) runningServers(ctx context.Context) (int, error) {
+	if c.tick != nil && c.tick.serversCounted {
+		return c.tick.servers, nil
+	}
+	workloads, err := c.listWorkloads(ctx)
+	if err != nil {
+		return 0, err
+	}
+	running := 0
+	for _, workload := range workloads {
+		if _, ok := c.workloadRound(workload); ok {
+			running++
+		}
+	}
+	if c.tick != nil {
+		c.tick.servers, c.tick.serversCounted = running, true
+	}
+	return running, nil
+}
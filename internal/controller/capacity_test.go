@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReserveServerCountsOnlyAdmittedServers(t *testing.T) {
+	tc := newTestController(t)
+	tc.cfg.Match.MaxServers = 1
+	tc.tick = &tickSummary{start: time.Now()}
+	ref := ServerRef{MatchID: testMatchID, RoundID: testRoundID}
+
+	// A reservation whose port allocation failed does not hold the only slot.
+	for attempt := 1; attempt <= 2; attempt++ {
+		if err := tc.reserveServer(context.Background(), ref); err != nil {
+			t.Fatalf("attempt %d: %v", attempt, err)
+		}
+	}
+	tc.admitServer()
+	if err := tc.reserveServer(context.Background(), ServerRef{MatchID: testMatchID, RoundID: testRoundID + 1}); !errors.Is(err, ErrCapacityReached) {
+		t.Errorf("reserving past the cap: %v, want ErrCapacityReached", err)
+	}
+}
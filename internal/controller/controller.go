@@ -2,13 +2,27 @@ package controller
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"math/big"
 	"net"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"helm.sh/helm/v3/pkg/chartutil"
@@ -19,96 +33,873 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 
+	"github.com/UDL-TF/TourneyController/internal/a2s"
 	"github.com/UDL-TF/TourneyController/internal/chart"
 	"github.com/UDL-TF/TourneyController/internal/config"
 	"github.com/UDL-TF/TourneyController/internal/database"
 	"github.com/UDL-TF/TourneyController/internal/ports"
+	"github.com/UDL-TF/TourneyController/internal/rcon"
 	"github.com/UDL-TF/TourneyController/internal/steam"
 )
 
+// Repository is the database access the controller needs. *database.Repository
+// is the production implementation; tests use an in-memory one.
+type Repository interface {
+	QueryMatches(ctx context.Context, q database.MatchQuery) ([]database.Match, error)
+	FetchMatchByID(ctx context.Context, matchID int) (*database.Match, error)
+	FetchMatchRounds(ctx context.Context, matchID int) ([]database.MatchRound, error)
+	FetchMatchRoundByID(ctx context.Context, matchID, roundID int) (*database.MatchRound, error)
+	FetchDivision(ctx context.Context, rosterID int) (*database.Division, error)
+	FetchDivisionsForRosters(ctx context.Context, rosterIDs []int) (map[int]*database.Division, error)
+	FetchLeague(ctx context.Context, divisionID string) (*database.League, error)
+	FetchTeamSteamIDs(ctx context.Context, rosterID int) ([]string, error)
+	FetchTeamSteamIDsForRosters(ctx context.Context, rosterIDs []int) (map[int][]string, error)
+	FetchMapName(ctx context.Context, mapID int) (string, error)
+	FetchMapNames(ctx context.Context, mapIDs []int) (map[int]string, error)
+	FetchMapOverrides(ctx context.Context, matchID int) (map[int]string, error)
+	FetchValuesOverride(ctx context.Context, matchID int) (string, error)
+	FetchRoundUpdatedAt(ctx context.Context, matchID, roundID int) (time.Time, error)
+	FetchMatchDetails(ctx context.Context, matchID, roundID int) (*database.MatchDetails, error)
+	FetchAllMatchDetails(ctx context.Context) ([]database.MatchDetails, error)
+	UpsertMatchDetailsTx(ctx context.Context, tx *sql.Tx, details database.MatchDetails) error
+	DeleteMatchDetails(ctx context.Context, matchID, roundID int) error
+	RecordMatchArtifacts(ctx context.Context, artifacts database.MatchArtifacts) error
+	UpdatePlayerCounts(ctx context.Context, matchID, roundID, players, maxPlayers int) error
+	UpdateActualMap(ctx context.Context, matchID, roundID int, mapName string) error
+	SetReconcileError(ctx context.Context, matchID int, message string) error
+	UpdateServerIPv6Tx(ctx context.Context, tx *sql.Tx, matchID, roundID int, addr string) error
+	UpdateSourceTVTx(ctx context.Context, tx *sql.Tx, matchID, roundID int, password, connect string) error
+	UpdateServerStatusTx(ctx context.Context, tx *sql.Tx, matchID, roundID int, status string) error
+	FetchServerStatus(ctx context.Context, matchID, roundID int) (string, error)
+	SendNotificationsToTeamsTx(ctx context.Context, tx *sql.Tx, homeRosterID, awayRosterID int, message, link string) error
+	WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error
+	CacheStats() database.CacheStats
+	SlowQueries() uint64
+	HealthCheck(ctx context.Context) error
+	SetNotificationCooldown(d time.Duration)
+}
+
+// Renderer applies and removes the chart objects of a server release.
+// *chart.Renderer is the production implementation.
+type Renderer interface {
+	SetCommonMetadata(labels, annotations map[string]string)
+	SetFieldManager(name string, force bool)
+	Apply(ctx context.Context, releaseName string, overrides chartutil.Values, owner *metav1.OwnerReference) error
+	Delete(ctx context.Context, releaseName string, overrides chartutil.Values) error
+	Diff(ctx context.Context, releaseName string, overrides chartutil.Values) (string, error)
+	Validate(releaseName string, sample chartutil.Values, requiredKinds ...string) error
+}
+
+// TokenProvider manages Steam game server login tokens.
+// *steam.SteamClient is the production implementation.
+type TokenProvider interface {
+	CreateAccount(appID int, memo string) (steam.Account, error)
+	GetAccountList() ([]steam.Account, error)
+	DeleteAccount(steamID string) error
+	ResetLoginToken(steamID string) (steam.Account, error)
+}
+
+var (
+	_ Repository    = (*database.Repository)(nil)
+	_ Renderer      = (*chart.Renderer)(nil)
+	_ TokenProvider = (*steam.SteamClient)(nil)
+)
+
 // Controller coordinates database polling with Kubernetes reconciliation.
 type Controller struct {
 	cfg           *config.Config
-	repo          *database.Repository
+	repo          Repository
 	clientset     kubernetes.Interface
 	portAllocator *ports.Allocator
-	renderer      *chart.Renderer
-	steamClient   *steam.SteamClient
+	tokens        TokenProvider
+
+	// renderers holds each game mode's chart renderer, keyed as
+	// config.ChartConfig.Mode; "" is the default chart.
+	renderers map[string]Renderer
+
+	// waitingForPorts records when each round first failed to get ports, so those
+	// matches are retried first once capacity frees up.
+	waitingForPorts map[ServerRef]time.Time
+	// waitingForCapacity does the same for rounds held back by MAX_CONCURRENT_SERVERS.
+	waitingForCapacity map[ServerRef]time.Time
+
+	// idleSince tracks when each running server was last seen with no human players;
+	// idledOut holds rounds torn down for idleness so they are not recreated. It is
+	// persisted as marker ConfigMaps and loaded once idledOutLoaded is unset.
+	idleSince      map[ServerRef]time.Time
+	idledOut       map[ServerRef]bool
+	idledOutLoaded bool
+
+	// outcomeSeen holds when each finished round's outcome was recorded, read
+	// from league_match_rounds.updated_at once, so later edits to the row do not
+	// extend TEARDOWN_GRACE and a failed read is not retried every tick.
+	outcomeSeen map[ServerRef]time.Time
+
+	// settled holds the fingerprint of the values each round's server was last
+	// ensured with, once it was ready; see roundSettled.
+	settled map[ServerRef]string
+
+	// countdowns holds the TEARDOWN_COUNTDOWN of each server being shut down.
+	countdowns map[ServerRef]shutdownCountdown
+
+	// dbFailures counts consecutive reconciles that failed on the database.
+	dbFailures int
+	// failedTicks counts consecutive reconciles that failed outright, for
+	// RECONCILE_FAILURE_EXIT_THRESHOLD.
+	failedTicks int
+
+	// expiredAccounts holds Steam accounts whose tokens expired, keyed by memo. It is
+	// only filled on ticks that check, every STEAM_TOKEN_CHECK_INTERVAL.
+	expiredAccounts map[string]steam.Account
+	lastTokenCheck  time.Time
+
+	// lastErrors holds the last reconcile error of each match still failing;
+	// storedErrors what each match's reconcile_error column was last set to.
+	lastErrors   map[int]MatchError
+	storedErrors map[int]string
+
+	// activeOverrides holds the values override last logged per match ID, so an
+	// override is announced when it appears or changes rather than every tick;
+	// activeMapOverrides does the same for each round's map override.
+	activeOverrides    map[int]string
+	activeMapOverrides map[ServerRef]string
+
+	// changeLevelRetries backs off MAP_DRIFT_POLICY=enforce for servers that are
+	// still off their map after a changelevel, e.g. because the map fails to load.
+	changeLevelRetries map[ServerRef]changeLevelRetry
+
+	// stickyKey caches the key sticky passwords are derived from.
+	stickyKey []byte
+
+	// serverConfig renders each round's config file; nil when
+	// SRCDS_SERVER_CONFIG_TEMPLATE_FILE is unset.
+	serverConfig *template.Template
+
+	// triggers carries match IDs queued by TriggerReconcile to the Run loop.
+	triggers chan int
+
+	// tick collects the current reconcile's outcome for its summary line; nil
+	// outside a reconcile. lastTick keeps the finished one for RunOnce.
+	tick     *tickSummary
+	lastTick tickSummary
+
+	// releasePattern recognises release names built from RELEASE_NAME_TEMPLATE.
+	releasePattern *regexp.Regexp
+
+	// paused stops new servers from being created; existing ones are still
+	// reconciled and torn down.
+	paused atomic.Bool
+
+	debugMu    sync.Mutex
+	debugState DebugState
 }
 
-// New wires together the reconciliation dependencies.
-func New(cfg *config.Config, repo *database.Repository, clientset kubernetes.Interface, renderer *chart.Renderer) *Controller {
-	var steamClient *steam.SteamClient
-	if cfg.Steam.EnableAutoTokens && cfg.Steam.APIKey != "" {
-		steamClient = steam.NewSteamClient(cfg.Steam.APIKey)
-	}
-
-	return &Controller{
+// New wires together the reconciliation dependencies. renderers holds a renderer
+// per game mode in cfg.Chart.Modes plus the default chart's under "". tokens may
+// be nil when automatic Steam tokens are disabled; see NewTokenProvider.
+func New(cfg *config.Config, repo Repository, clientset kubernetes.Interface, renderers map[string]Renderer, tokens TokenProvider) *Controller {
+	ctrl := &Controller{
 		cfg:           cfg,
 		repo:          repo,
 		clientset:     clientset,
 		portAllocator: ports.NewAllocator(cfg.Ports),
-		renderer:      renderer,
-		steamClient:   steamClient,
+		tokens:        tokens,
+		renderers:     renderers,
+
+		waitingForPorts:    map[ServerRef]time.Time{},
+		waitingForCapacity: map[ServerRef]time.Time{},
+		idleSince:          map[ServerRef]time.Time{},
+		idledOut:           map[ServerRef]bool{},
+		outcomeSeen:        map[ServerRef]time.Time{},
+		settled:            map[ServerRef]string{},
+		countdowns:         map[ServerRef]shutdownCountdown{},
+		activeOverrides:    map[int]string{},
+		activeMapOverrides: map[ServerRef]string{},
+		changeLevelRetries: map[ServerRef]changeLevelRetry{},
+		lastErrors:         map[int]MatchError{},
+		storedErrors:       map[int]string{},
+		triggers:           make(chan int, triggerQueue),
+		releasePattern:     cfg.Release.Pattern(),
+	}
+	if cfg.SRCDS.ServerConfig != "" {
+		// Load already parsed the template once, so this cannot fail.
+		ctrl.serverConfig = template.Must(config.ParseServerConfigTemplate(cfg.SRCDS.ServerConfig))
+	}
+	ctrl.paused.Store(cfg.Match.Paused)
+	// validate builds a controller without a repository when the database is
+	// unreachable, to still check the chart.
+	if repo != nil {
+		repo.SetNotificationCooldown(cfg.Notifications.Cooldown)
+	}
+	for _, renderer := range renderers {
+		renderer.SetCommonMetadata(cfg.CommonLabels, cfg.CommonAnnotations)
+		renderer.SetFieldManager(cfg.Chart.FieldManager, cfg.Chart.ForceConflicts)
+	}
+	return ctrl
+}
+
+// NewTokenProvider returns the Steam client configured by cfg, or nil when
+// automatic tokens are disabled or no API key is set.
+func NewTokenProvider(cfg *config.Config) TokenProvider {
+	if cfg.Steam.EnableAutoTokens && cfg.Steam.APIKey != "" {
+		return steam.NewSteamClient(cfg.Steam.APIKey, cfg.Steam.BaseURL)
 	}
+	return nil
 }
 
 // Run blocks until the context is cancelled, reconciling on every tick.
 func (c *Controller) Run(ctx context.Context) error {
 	klog.Info("controller started")
-	ticker := time.NewTicker(c.cfg.PollInterval)
+	interval := c.cfg.PollInterval
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	if err := c.reconcile(ctx); err != nil {
+	err := c.reconcile(ctx)
+	if err != nil {
 		klog.Errorf("initial reconcile failed: %v", err)
 	}
 
 	for {
+		if err := c.checkFailureExit(err); err != nil {
+			return err
+		}
+		if next := c.nextPollInterval(err); next != interval {
+			interval = next
+			ticker.Reset(interval)
+		}
+
 		select {
 		case <-ctx.Done():
 			klog.Info("controller shutting down")
 			return ctx.Err()
 		case <-ticker.C:
-			if err := c.reconcile(ctx); err != nil {
+			if err = c.reconcile(ctx); err != nil {
 				klog.Errorf("reconcile tick failed: %v", err)
 			}
+		case matchID := <-c.triggers:
+			c.reconcileTriggered(ctx, matchID)
 		}
 	}
 }
 
+// errAllMatchesFailed marks a reconcile in which every fetched match errored.
+var errAllMatchesFailed = errors.New("every match failed to reconcile")
+
+// checkFailureExit counts consecutive failed reconciles and returns an error once
+// RECONCILE_FAILURE_EXIT_THRESHOLD is reached, so the process exits non-zero and
+// Kubernetes restarts it instead of the failure going unnoticed.
+func (c *Controller) checkFailureExit(err error) error {
+	if err == nil {
+		c.failedTicks = 0
+		return nil
+	}
+	c.failedTicks++
+	if threshold := c.cfg.FailureExit; threshold > 0 && c.failedTicks >= threshold {
+		return fmt.Errorf("%d consecutive reconciles failed, exiting for restart: %w", c.failedTicks, err)
+	}
+	return nil
+}
+
+// nextPollInterval returns the delay before the next tick. After DB_FAILURE_THRESHOLD
+// consecutive database failures the interval doubles per failure up to
+// DB_MAX_BACKOFF; the first successful tick restores POLL_INTERVAL.
+func (c *Controller) nextPollInterval(err error) time.Duration {
+	threshold := c.cfg.Database.FailureThreshold
+	if !errors.Is(err, ErrDatabaseUnavailable) {
+		if threshold > 0 && c.dbFailures >= threshold {
+			klog.Infof("database recovered, resuming %v poll interval", c.cfg.PollInterval)
+		}
+		c.dbFailures = 0
+		return c.cfg.PollInterval
+	}
+
+	c.dbFailures++
+	if threshold <= 0 || c.dbFailures < threshold {
+		return c.cfg.PollInterval
+	}
+	backoff := c.cfg.PollInterval
+	for i := threshold; i <= c.dbFailures && backoff < c.cfg.Database.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.cfg.Database.MaxBackoff {
+		backoff = c.cfg.Database.MaxBackoff
+	}
+	klog.Warningf("degraded mode: database failed %d consecutive reconciles, next attempt in %v", c.dbFailures, backoff)
+	return backoff
+}
+
+// EnsureNamespace verifies the target namespace exists, creating it when
+// CREATE_NAMESPACE is set, so a typo fails at startup rather than as NotFound
+// errors on every reconcile.
+func (c *Controller) EnsureNamespace(ctx context.Context) error {
+	namespaces := c.clientset.CoreV1().Namespaces()
+	_, err := namespaces.Get(ctx, c.cfg.Namespace, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("get namespace %q: %w", c.cfg.Namespace, err)
+	}
+	if !c.cfg.CreateNamespace {
+		return fmt.Errorf("namespace %q does not exist; create it or set CREATE_NAMESPACE=true", c.cfg.Namespace)
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: c.cfg.Namespace}}
+	if _, err := namespaces.Create(ctx, ns, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("create namespace %q: %w", c.cfg.Namespace, err)
+	}
+	klog.Infof("created namespace %s", c.cfg.Namespace)
+	return nil
+}
+
+// ValidateChart renders every configured chart with values for a sample round and
+// checks it produces every kind the controller relies on.
+func (c *Controller) ValidateChart() error {
+	match := database.Match{ID: 1, RosterHomeID: 1, RosterAwayID: 2, WinLimit: 3}
+	round := database.MatchRound{ID: 1, MatchID: 1}
+	state := &serverState{
+		ReleaseName: c.releaseName(match.ID, round.ID),
+		Ports:       c.portAllocator.Sample(),
+		Password:    "sample",
+		RCON:        "sample",
+		Map:         c.cfg.Match.DefaultMap,
+	}
+	values := c.buildValues(match, round, &database.Division{ID: "sample", Name: "sample"}, &database.League{Name: "sample"}, nil, nil, state)
+	for mode, renderer := range c.renderers {
+		// Every server release depends on its workload and its Service.
+		if err := renderer.Validate(state.ReleaseName, values, string(c.cfg.SRCDS.WorkloadKind), "Service"); err != nil {
+			if mode == "" {
+				return err
+			}
+			return fmt.Errorf("chart for game mode %s: %w", mode, err)
+		}
+	}
+	return nil
+}
+
+// SetPaused switches maintenance mode, in which no new servers are created.
+func (c *Controller) SetPaused(paused bool) {
+	c.paused.Store(paused)
+}
+
+// Paused reports whether the controller is in maintenance mode.
+func (c *Controller) Paused() bool {
+	return c.paused.Load()
+}
+
+// tickSummary counts what one reconcile did. Its methods are no-ops on a nil
+// receiver, so teardown paths shared with the CLI need not check for a tick.
+type tickSummary struct {
+	start     time.Time
+	matches   int
+	ensured   int
+	tornDown  int
+	errors    int
+	throttled int // New servers held back by MAX_CONCURRENT_SERVERS
+
+	// waiting holds the rounds queued for ports or capacity this tick; queue
+	// entries not renewed by the end of a full reconcile are dropped.
+	waiting map[ServerRef]bool
+
+	// servers counts running servers for the cap once serversCounted is set.
+	servers        int
+	serversCounted bool
+}
+
+func (t *tickSummary) throttle() {
+	if t != nil {
+		t.throttled++
+	}
+}
+
+func (t *tickSummary) queue(ref ServerRef) {
+	if t != nil {
+		if t.waiting == nil {
+			t.waiting = map[ServerRef]bool{}
+		}
+		t.waiting[ref] = true
+	}
+}
+
+func (t *tickSummary) ensure() {
+	if t != nil {
+		t.ensured++
+	}
+}
+
+func (t *tickSummary) tearDown() {
+	if t != nil {
+		t.tornDown++
+	}
+}
+
+func (t *tickSummary) fail() {
+	if t != nil {
+		t.errors++
+	}
+}
+
+func (t *tickSummary) log() {
+	klog.Infof("reconcile tick: %d matches fetched, %d servers ensured, %d torn down, %d throttled, %d waiting, %d errors in %v",
+		t.matches, t.ensured, t.tornDown, t.throttled, len(t.waiting), t.errors, time.Since(t.start).Round(time.Millisecond))
+}
+
 func (c *Controller) reconcile(ctx context.Context) error {
-	matches, err := c.repo.FetchMatches(ctx, c.cfg.Match.TargetStatuses)
+	c.tick = &tickSummary{start: time.Now()}
+	defer func() {
+		c.tick.log()
+		c.lastTick = *c.tick
+		c.tick = nil
+	}()
+
+	if c.Paused() {
+		klog.Info("controller is paused: not creating new servers, existing servers and teardowns are still handled")
+	}
+
+	matches, err := c.repo.QueryMatches(ctx, database.MatchQuery{
+		Statuses:    c.cfg.Match.TargetStatuses,
+		Allowlist:   c.cfg.Match.IDAllowlist,
+		Order:       c.cfg.Match.Order,
+		Limit:       c.cfg.Match.BatchLimit,
+		Lookahead:   c.lookahead(),
+		PrewarmLead: c.cfg.Match.PrewarmLead,
+		Priority:    c.cfg.Match.PrioritizesBy(config.PriorityColumn),
+		Schedule:    c.cfg.Match.PrioritizesBy(config.PriorityScheduled),
+	})
 	if err != nil {
-		return err
+		c.tick.fail()
+		return fmt.Errorf("%w: %w", ErrDatabaseUnavailable, err)
+	}
+	c.tick.matches = len(matches)
+
+	c.prioritizeWaitingMatches(matches)
+	c.checkExpiredTokens()
+
+	lookups, err := c.preloadLookups(ctx, matches)
+	if err != nil {
+		klog.Warningf("batch lookups failed, falling back to per-match queries: %v", err)
+		lookups = nil
 	}
+	c.prioritizeMatches(ctx, matches, lookups)
 
+	snapshot := DebugState{GeneratedAt: time.Now(), Paused: c.Paused()}
+	failedMatches := 0
+	fetched := make(map[int]bool, len(matches))
 	for _, match := range matches {
-		if err := c.reconcileMatch(ctx, match); err != nil {
-			klog.Errorf("match %d reconcile error: %v", match.ID, err)
+		fetched[match.ID] = true
+		status := MatchStatus{MatchID: match.ID, Status: match.Status, ManualNotDone: match.ManualNotDone}
+		err := c.reconcileMatch(ctx, match, lookups, &status)
+		if err != nil {
+			status.Error = err.Error()
+			status.Reason = FailureReason(err)
+			failedMatches++
+			c.tick.fail()
+			klog.Errorf("match %d reconcile error (%s): %v", match.ID, status.Reason, err)
 		}
+		c.recordReconcileResult(ctx, match.ID, err)
+		snapshot.Matches = append(snapshot.Matches, status)
 	}
+	c.forgetReconcileErrors(ctx, fetched)
+	c.pruneWaiting()
+	snapshot.LastErrors = c.reconcileErrors()
+	for ref := range c.waitingForPorts {
+		snapshot.WaitingForPorts = append(snapshot.WaitingForPorts, ref)
+	}
+	for ref := range c.waitingForCapacity {
+		snapshot.WaitingForCapacity = append(snapshot.WaitingForCapacity, ref)
+	}
+	c.setDebugState(snapshot)
 
 	// Clean up orphaned servers (servers that exist but shouldn't)
 	if err := c.cleanupOrphanedServers(ctx); err != nil {
+		c.tick.fail()
 		klog.Errorf("orphaned server cleanup error: %v", err)
 	}
 
 	// Clean up dangling deployments (K8s resources with no database record)
 	if err := c.cleanupDanglingDeployments(ctx); err != nil {
+		c.tick.fail()
 		klog.Errorf("dangling deployment cleanup error: %v", err)
 	}
 
+	// Clean up servers whose match was moved out of the target statuses
+	if err := c.cleanupUntargetedServers(ctx); err != nil {
+		c.tick.fail()
+		klog.Errorf("untargeted server cleanup error: %v", err)
+	}
+
+	// Copies carry their own deadline, so they are pruned even after retention
+	// is turned off.
+	if err := c.pruneRetainedSecrets(ctx); err != nil {
+		klog.Warningf("retained secret cleanup error: %v", err)
+	}
+
+	stats := c.repo.CacheStats()
+	klog.V(2).Infof("lookup cache: %d hits, %d misses; %d slow queries", stats.Hits, stats.Misses, c.repo.SlowQueries())
+
+	if len(matches) > 0 && failedMatches == len(matches) {
+		return fmt.Errorf("%w (%d matches)", errAllMatchesFailed, failedMatches)
+	}
 	return nil
 }
 
-func (c *Controller) reconcileMatch(ctx context.Context, match database.Match) error {
-	division, err := c.repo.FetchDivision(ctx, match.RosterHomeID)
+// pruneWaiting drops queued rounds that were not retried this tick because their
+// match left the target statuses or the round no longer needs a server, so they
+// neither linger on the debug endpoint nor jump the queue if they come back.
+func (c *Controller) pruneWaiting() {
+	for _, waiting := range []map[ServerRef]time.Time{c.waitingForPorts, c.waitingForCapacity} {
+		for ref := range waiting {
+			if !c.tick.waiting[ref] {
+				delete(waiting, ref)
+			}
+		}
+	}
+}
+
+// prioritizeWaitingMatches moves matches with rounds waiting for ports or capacity
+// to the front, longest-waiting first, keeping the fetch order for everything else.
+func (c *Controller) prioritizeWaitingMatches(matches []database.Match) {
+	if len(c.waitingForPorts) == 0 && len(c.waitingForCapacity) == 0 {
+		return
+	}
+	oldest := map[int]time.Time{}
+	for _, waiting := range []map[ServerRef]time.Time{c.waitingForPorts, c.waitingForCapacity} {
+		for ref, since := range waiting {
+			if current, ok := oldest[ref.MatchID]; !ok || since.Before(current) {
+				oldest[ref.MatchID] = since
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, aWaiting := oldest[matches[i].ID]
+		b, bWaiting := oldest[matches[j].ID]
+		if aWaiting != bWaiting {
+			return aWaiting
+		}
+		return aWaiting && a.Before(b)
+	})
+}
+
+// probeServer sends A2S_INFO to the address players would be given.
+func (c *Controller) probeServer(ctx context.Context, releaseName string, port int) error {
+	nodeIP, err := c.serverNodeIP(ctx, releaseName)
+	if err != nil {
+		return fmt.Errorf("discover node ip: %w", err)
+	}
+	addr := net.JoinHostPort(nodeIP, strconv.Itoa(port))
+	if _, err := a2s.QueryInfo(ctx, addr, c.cfg.Networking.ProbeTimeout); err != nil {
+		return fmt.Errorf("a2s probe %s: %w", addr, err)
+	}
+	return nil
+}
+
+// rconTimeout bounds each RCON dial, read and write issued during reconcile.
+const rconTimeout = 5 * time.Second
+
+// observesServers reports whether running servers are queried over A2S each tick.
+func (c *Controller) observesServers() bool {
+	return c.cfg.Match.PlayerCounts || c.cfg.Match.IdleTimeout > 0 || c.cfg.Match.MapDrift != config.MapDriftOff
+}
+
+// observeServer queries a running server over A2S_INFO, stores its live player count
+// and running map when configured, and applies MAP_DRIFT_POLICY. It returns the
+// number of human players, or -1 when the server did not answer.
+func (c *Controller) observeServer(ctx context.Context, details *database.MatchDetails, desiredMap, releaseName string, status *RoundStatus) int {
+	addr := net.JoinHostPort(details.ServerIP, strconv.Itoa(details.Port))
+	info, err := a2s.QueryInfo(ctx, addr, c.cfg.Networking.ProbeTimeout)
+	if err != nil {
+		klog.V(2).Infof("a2s query for match %d round %d failed: %v", details.MatchID, details.RoundID, err)
+		return -1
+	}
+	status.ActualMap = info.Map
+
+	humans := info.Players - info.Bots
+	if humans < 0 {
+		humans = 0
+	}
+	if c.cfg.Match.PlayerCounts {
+		if err := c.repo.UpdatePlayerCounts(ctx, details.MatchID, details.RoundID, humans, info.MaxPlayers); err != nil {
+			klog.Warningf("store player counts for match %d round %d: %v", details.MatchID, details.RoundID, err)
+		}
+	}
+	if c.cfg.Match.MapDrift != config.MapDriftOff {
+		if err := c.repo.UpdateActualMap(ctx, details.MatchID, details.RoundID, info.Map); err != nil {
+			klog.Warningf("store actual map for match %d round %d: %v", details.MatchID, details.RoundID, err)
+		}
+		if c.cfg.Match.MapDrift == config.MapDriftEnforce && desiredMap != "" {
+			c.enforceMap(ctx, details, addr, releaseName, info.Map, desiredMap)
+		}
+	}
+	return humans
+}
+
+// changeLevelMaxBackoff caps the wait between changelevel attempts on a server
+// that stays off its map.
+const changeLevelMaxBackoff = 10 * time.Minute
+
+// changeLevelRetry is how often a server has been sent back to its map without
+// it sticking, and when the next attempt is due.
+type changeLevelRetry struct {
+	attempts int
+	next     time.Time
+}
+
+// enforceMap changes a drifted server back to desiredMap. Each attempt, whether
+// or not RCON accepted it, doubles the wait before the next one from POLL_INTERVAL
+// up to changeLevelMaxBackoff, so a map that fails to load is not retried every
+// tick; the backoff resets once the server is on desiredMap.
+func (c *Controller) enforceMap(ctx context.Context, details *database.MatchDetails, addr, releaseName, actualMap, desiredMap string) {
+	ref := ServerRef{MatchID: details.MatchID, RoundID: details.RoundID}
+	if strings.EqualFold(actualMap, desiredMap) {
+		delete(c.changeLevelRetries, ref)
+		return
+	}
+	retry := c.changeLevelRetries[ref]
+	if time.Now().Before(retry.next) {
+		klog.V(2).Infof("match %d round %d is on %s instead of %s, next changelevel at %s",
+			details.MatchID, details.RoundID, actualMap, desiredMap, retry.next.Format(time.RFC3339))
+		return
+	}
+
+	backoff := c.cfg.PollInterval
+	for i := 0; i < retry.attempts && backoff < changeLevelMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > changeLevelMaxBackoff {
+		backoff = changeLevelMaxBackoff
+	}
+	retry.attempts++
+	retry.next = time.Now().Add(backoff)
+	c.changeLevelRetries[ref] = retry
+
+	if err := c.changeLevel(ctx, addr, releaseName, desiredMap); err != nil {
+		klog.Warningf("match %d round %d is on %s instead of %s, changelevel failed (attempt %d, next in %v): %v",
+			details.MatchID, details.RoundID, actualMap, desiredMap, retry.attempts, backoff, err)
+		return
+	}
+	klog.Infof("match %d round %d was on %s, changed level back to %s (attempt %d)",
+		details.MatchID, details.RoundID, actualMap, desiredMap, retry.attempts)
+}
+
+// changeLevel switches a running server to mapName over RCON.
+func (c *Controller) changeLevel(ctx context.Context, addr, releaseName, mapName string) error {
+	state, err := c.loadServerState(ctx, releaseName)
+	if err != nil {
+		return fmt.Errorf("load server state: %w", err)
+	}
+	if state == nil || state.RCON == "" {
+		return fmt.Errorf("no rcon password for %s", releaseName)
+	}
+	client, err := dialRCON(ctx, addr, state)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if _, err := client.Execute("changelevel " + mapName); err != nil {
+		return fmt.Errorf("rcon changelevel: %w", err)
+	}
+	return nil
+}
+
+// idleExpired reports whether a running server has had no human players for longer
+// than IDLE_TIMEOUT. humans is the A2S count from this tick; when it is negative the
+// count comes from RCON status instead. When neither answers, idleness is left unchanged.
+func (c *Controller) idleExpired(ctx context.Context, ref ServerRef, details *database.MatchDetails, releaseName string, humans int) bool {
+	if c.cfg.Match.IdleTimeout <= 0 {
+		return false
+	}
+
+	if humans < 0 {
+		state, err := c.loadServerState(ctx, releaseName)
+		if err != nil || state == nil || state.RCON == "" {
+			klog.V(2).Infof("no rcon password for %s, skipping idle check", releaseName)
+			return false
+		}
+
+		addr := net.JoinHostPort(details.ServerIP, strconv.Itoa(details.Port))
+		humans, err = queryHumanPlayers(ctx, addr, state)
+		if err != nil {
+			klog.V(2).Infof("idle check for %s failed: %v", releaseName, err)
+			return false
+		}
+	}
+
+	if humans > 0 {
+		delete(c.idleSince, ref)
+		return false
+	}
+	since, ok := c.idleSince[ref]
+	if !ok {
+		c.idleSince[ref] = time.Now()
+		return false
+	}
+	return time.Since(since) > c.cfg.Match.IdleTimeout
+}
+
+// dialRCON connects to a running server, trying each of its known passwords.
+func dialRCON(ctx context.Context, addr string, state *serverState) (*rcon.Client, error) {
+	var err error
+	for _, password := range state.rconPasswords() {
+		var client *rcon.Client
+		client, err = rcon.Dial(ctx, addr, password, rconTimeout)
+		if !errors.Is(err, rcon.ErrAuthFailed) {
+			return client, err
+		}
+	}
+	return nil, err
+}
+
+func queryHumanPlayers(ctx context.Context, addr string, state *serverState) (int, error) {
+	client, err := dialRCON(ctx, addr, state)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	status, err := client.Execute("status")
+	if err != nil {
+		return 0, fmt.Errorf("rcon status: %w", err)
+	}
+	return rcon.HumanPlayers(status)
+}
+
+// lookahead is MATCH_LOOKAHEAD widened to PREWARM_LEAD, so matches due to be
+// pre-warmed are always fetched.
+func (c *Controller) lookahead() time.Duration {
+	if c.cfg.Match.Lookahead > 0 && c.cfg.Match.Lookahead < c.cfg.Match.PrewarmLead {
+		return c.cfg.Match.PrewarmLead
+	}
+	return c.cfg.Match.Lookahead
+}
+
+// prewarmRound returns the ID of the round to pre-warm for match: the earliest
+// round without an outcome, or 0 when the match does not start within PREWARM_LEAD.
+func prewarmRound(match database.Match, rounds []database.MatchRound) int {
+	if !match.Prewarm {
+		return 0
+	}
+	next := 0
+	for _, round := range rounds {
+		if !round.HasOutcome && (next == 0 || round.ID < next) {
+			next = round.ID
+		}
+	}
+	return next
+}
+
+// mapOverrides returns the maps staff forced for a match's rounds, keyed by round
+// ID, or nil when MATCH_MAP_OVERRIDES is off. A failed lookup falls back to the
+// scheduled maps rather than failing the match.
+func (c *Controller) mapOverrides(ctx context.Context, matchID int) map[int]string {
+	if !c.cfg.Match.MapOverrides {
+		return nil
+	}
+	overrides, err := c.repo.FetchMapOverrides(ctx, matchID)
+	if err != nil {
+		klog.Warningf("match %d map override lookup failed, using scheduled maps: %v", matchID, err)
+		return nil
+	}
+	return overrides
+}
+
+// inTeardownGrace reports whether a finished round's server should keep running
+// because its outcome was recorded less than TEARDOWN_GRACE ago. When the time
+// cannot be read, the grace period runs from when the outcome was first seen.
+func (c *Controller) inTeardownGrace(ctx context.Context, ref ServerRef) bool {
+	if c.cfg.Match.TeardownGrace <= 0 {
+		return false
+	}
+	updatedAt, ok := c.outcomeSeen[ref]
+	if !ok {
+		var err error
+		updatedAt, err = c.repo.FetchRoundUpdatedAt(ctx, ref.MatchID, ref.RoundID)
+		if err != nil {
+			klog.Warningf("match %d round %d outcome time unknown, waiting TEARDOWN_GRACE from now: %v", ref.MatchID, ref.RoundID, err)
+			updatedAt = time.Now()
+		}
+		c.outcomeSeen[ref] = updatedAt
+	}
+	// The shutdown countdown runs in the last part of the grace period, so the
+	// server still goes away TEARDOWN_GRACE after the outcome.
+	grace := c.cfg.Match.TeardownGrace
+	if countdown := c.cfg.Match.TeardownCountdown; countdown > 0 {
+		grace -= countdown
+	}
+	if remaining := grace - time.Since(updatedAt); remaining > 0 {
+		klog.V(2).Infof("match %d round %d finished, tearing down in %v", ref.MatchID, ref.RoundID, remaining.Round(time.Second))
+		return true
+	}
+	return false
+}
+
+// teamsReady reports whether enough teams have readied up to provision a server:
+// both by default, or either one when REQUIRE_BOTH_READY is disabled.
+func (c *Controller) teamsReady(round database.MatchRound) bool {
+	if c.cfg.Match.RequireBothReady {
+		return round.HomeReady && round.AwayReady
+	}
+	return round.HomeReady || round.AwayReady
+}
+
+// matchLookups holds per-tick data fetched for every match in one query each, so
+// reconcileMatch does not issue the same lookups match by match.
+type matchLookups struct {
+	divisions map[int]*database.Division // keyed by roster ID
+	steamIDs  map[int][]string           // keyed by roster ID
+}
+
+func (c *Controller) preloadLookups(ctx context.Context, matches []database.Match) (*matchLookups, error) {
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	homeRosters := make([]int, 0, len(matches))
+	rosters := make([]int, 0, 2*len(matches))
+	for _, match := range matches {
+		homeRosters = append(homeRosters, match.RosterHomeID)
+		rosters = append(rosters, match.RosterHomeID, match.RosterAwayID)
+	}
+
+	divisions, err := c.repo.FetchDivisionsForRosters(ctx, homeRosters)
+	if err != nil {
+		return nil, err
+	}
+	steamIDs, err := c.repo.FetchTeamSteamIDsForRosters(ctx, rosters)
+	if err != nil {
+		return nil, err
+	}
+	return &matchLookups{divisions: divisions, steamIDs: steamIDs}, nil
+}
+
+func (c *Controller) lookupDivision(ctx context.Context, lookups *matchLookups, rosterID int) (*database.Division, error) {
+	if lookups != nil {
+		if division, ok := lookups.divisions[rosterID]; ok {
+			return division, nil
+		}
+	}
+	return c.repo.FetchDivision(ctx, rosterID)
+}
+
+func (c *Controller) lookupSteamIDs(ctx context.Context, lookups *matchLookups, rosterID int) ([]string, error) {
+	if lookups != nil {
+		if ids, ok := lookups.steamIDs[rosterID]; ok {
+			return ids, nil
+		}
+	}
+	return c.repo.FetchTeamSteamIDs(ctx, rosterID)
+}
+
+func (c *Controller) reconcileMatch(ctx context.Context, match database.Match, lookups *matchLookups, status *MatchStatus) error {
+	// Both rosters of a match belong to the same division, so the home roster's
+	// is the match's.
+	division, err := c.lookupDivision(ctx, lookups, match.RosterHomeID)
 	if err != nil {
 		return fmt.Errorf("fetch division: %w", err)
 	}
+	status.Division = division.Name
 
 	if !c.divisionMatchesFilter(division.Name) {
 		klog.V(2).Infof("skipping match %d: division %q excluded by filter", match.ID, division.Name)
+		status.Skipped = "division excluded by filter"
 		return nil
 	}
 
@@ -117,12 +908,12 @@ func (c *Controller) reconcileMatch(ctx context.Context, match database.Match) e
 		return fmt.Errorf("fetch league: %w", err)
 	}
 
-	homeIDs, err := c.repo.FetchTeamSteamIDs(ctx, match.RosterHomeID)
+	homeIDs, err := c.lookupSteamIDs(ctx, lookups, match.RosterHomeID)
 	if err != nil {
 		return fmt.Errorf("fetch home steam ids: %w", err)
 	}
 
-	awayIDs, err := c.repo.FetchTeamSteamIDs(ctx, match.RosterAwayID)
+	awayIDs, err := c.lookupSteamIDs(ctx, lookups, match.RosterAwayID)
 	if err != nil {
 		return fmt.Errorf("fetch away steam ids: %w", err)
 	}
@@ -132,12 +923,37 @@ func (c *Controller) reconcileMatch(ctx context.Context, match database.Match) e
 		return fmt.Errorf("fetch match rounds: %w", err)
 	}
 
+	mapIDs := make([]int, 0, len(rounds))
 	for _, round := range rounds {
-		mapName, err := c.repo.FetchMapName(ctx, round.MapID)
-		if err != nil {
-			klog.Warningf("round %d map lookup failed, using default: %v", round.ID, err)
+		mapIDs = append(mapIDs, round.MapID)
+	}
+	mapNames, mapErr := c.repo.FetchMapNames(ctx, mapIDs)
+	if mapErr != nil {
+		klog.Warningf("match %d map lookup failed, using default: %v", match.ID, mapErr)
+	}
+	overrides := c.mapOverrides(ctx, match.ID)
+	prewarm := prewarmRound(match, rounds)
+
+	for _, round := range rounds {
+		mapName, ok := mapNames[round.MapID]
+		if !ok {
+			if mapErr == nil {
+				klog.Warningf("round %d map %d not found, using default", round.ID, round.MapID)
+			}
 			mapName = c.cfg.Match.DefaultMap
 		}
+		ref := ServerRef{MatchID: match.ID, RoundID: round.ID}
+		override, overridden := overrides[round.ID]
+		if overridden {
+			if c.activeMapOverrides[ref] != override {
+				klog.Infof("match %d round %d map overridden to %s (scheduled %s)", match.ID, round.ID, override, mapName)
+				c.activeMapOverrides[ref] = override
+			}
+			mapName = override
+		} else if previous, ok := c.activeMapOverrides[ref]; ok {
+			klog.Infof("match %d round %d map override %s removed, using %s", match.ID, round.ID, previous, mapName)
+			delete(c.activeMapOverrides, ref)
+		}
 
 		details, err := c.repo.FetchMatchDetails(ctx, match.ID, round.ID)
 		if err != nil {
@@ -146,24 +962,104 @@ func (c *Controller) reconcileMatch(ctx context.Context, match database.Match) e
 
 		// Server is needed if:
 		// 1. Manual flag is set, OR
-		// 2. Round has no outcome AND both teams are ready (to create new server), OR
-		// 3. Server already exists AND round has no outcome (to keep existing server running)
+		// 2. Round has no outcome AND the teams are ready (to create new server), OR
+		// 3. Server already exists AND round has no outcome (to keep existing server running), OR
+		// 4. Round is the next one of a match starting within PREWARM_LEAD (to warm up)
 		needsServer := match.ManualNotDone ||
-			(!round.HasOutcome && round.HomeReady && round.AwayReady) ||
-			(details != nil && !round.HasOutcome)
-		releaseName := releaseName(match.ID, round.ID)
+			(!round.HasOutcome && c.teamsReady(round)) ||
+			(details != nil && !round.HasOutcome) ||
+			round.ID == prewarm
+		releaseName := c.releaseName(match.ID, round.ID)
+
+		status.Rounds = append(status.Rounds, newRoundStatus(round, details))
+		roundStatus := &status.Rounds[len(status.Rounds)-1]
+		roundStatus.DesiredMap = mapName
+		roundStatus.MapOverride = overridden
+		if c.cfg.Match.IdleTimeout > 0 {
+			c.loadIdledOut(ctx)
+		}
+		if round.HasOutcome {
+			c.clearIdledOut(ctx, ref)
+		}
+		if needsServer && !match.ManualNotDone && c.idledOut[ref] {
+			klog.V(2).Infof("match %d round %d was torn down for idleness, not recreating", match.ID, round.ID)
+			needsServer = false
+		}
+
+		humans := -1
+		if needsServer && details != nil && c.observesServers() {
+			humans = c.observeServer(ctx, details, mapName, releaseName, roundStatus)
+		}
+
+		// A pre-warmed server is expected to sit empty until the match starts.
+		if needsServer && details != nil && !match.ManualNotDone && round.ID != prewarm && c.idleExpired(ctx, ref, details, releaseName, humans) {
+			if err := c.markIdledOut(ctx, ref); err != nil {
+				klog.Warningf("match %d round %d had no players for %v, not tearing down until it can be recorded: %v", match.ID, round.ID, c.cfg.Match.IdleTimeout, err)
+			} else {
+				klog.Infof("match %d round %d had no players for %v, tearing down", match.ID, round.ID, c.cfg.Match.IdleTimeout)
+				needsServer = false
+			}
+		}
+
+		if needsServer && details != nil && len(c.expiredAccounts) > 0 {
+			if err := c.refreshExpiredToken(ctx, match, round, releaseName); err != nil {
+				klog.Warningf("token refresh for match %d round %d failed: %v", match.ID, round.ID, err)
+			}
+		}
+
+		if needsServer && details != nil && c.cfg.SRCDS.RCONRotation > 0 {
+			if err := c.rotateRCON(ctx, match, round, details, releaseName); err != nil {
+				klog.Warningf("rcon rotation for match %d round %d failed: %v", match.ID, round.ID, err)
+			}
+		}
 
+		roundStatus.NeedsServer = needsServer
 		if needsServer {
-			if err := c.ensureRound(ctx, match, round, division.ID, league, homeIDs, awayIDs, mapName, details, releaseName); err != nil {
-				klog.Errorf("ensure round %d: %v", round.ID, err)
+			c.cancelCountdown(ref)
+			if err := c.ensureRound(ctx, match, round, division, league, homeIDs, awayIDs, mapName, details, releaseName); err != nil {
+				roundStatus.Error = err.Error()
+				roundStatus.Reason = FailureReason(err)
+				if errors.Is(err, ErrServerUnreachable) {
+					roundStatus.Unreachable = true
+					klog.Warningf("match %d round %d is running but unreachable, not advertising it: %v", match.ID, round.ID, err)
+					continue
+				}
+				if errors.Is(err, ErrPortsExhausted) {
+					klog.Warningf("match %d round %d is waiting for free ports (queued %v ago), will retry next tick: %v",
+						match.ID, round.ID, time.Since(c.waitingForPorts[ref]).Round(time.Second), err)
+					continue
+				}
+				if errors.Is(err, ErrCapacityReached) {
+					klog.Warningf("throttling: match %d round %d is waiting for capacity (queued %v ago), will retry next tick: %v",
+						match.ID, round.ID, time.Since(c.waitingForCapacity[ref]).Round(time.Second), err)
+					continue
+				}
+				c.tick.fail()
+				klog.Errorf("ensure round %d (%s): %v", round.ID, roundStatus.Reason, err)
+				continue
 			}
+			c.tick.ensure()
 			continue
 		}
 
 		// Teardown if server exists but is no longer needed
+		if details != nil && round.HasOutcome && c.inTeardownGrace(ctx, ref) {
+			continue
+		}
+		if details != nil && c.countingDown(ctx, ref, details, releaseName) {
+			continue
+		}
 		if details != nil {
-			if err := c.teardownRound(ctx, match, round, division.ID, league, homeIDs, awayIDs, mapName, releaseName, details); err != nil {
-				klog.Errorf("teardown round %d: %v", round.ID, err)
+			err := c.withRoundLock(ctx, releaseName, func() error {
+				return c.teardownRound(ctx, match, round, division, league, homeIDs, awayIDs, mapName, releaseName, details)
+			})
+			if err != nil {
+				roundStatus.Error = err.Error()
+				roundStatus.Reason = FailureReason(err)
+				c.tick.fail()
+				klog.Errorf("teardown round %d (%s): %v", round.ID, roundStatus.Reason, err)
+			} else {
+				c.tick.tearDown()
 			}
 		}
 	}
@@ -171,38 +1067,117 @@ func (c *Controller) reconcileMatch(ctx context.Context, match database.Match) e
 	return nil
 }
 
+// ensureRound drives one round towards a running, advertised server. Every step is
+// idempotent, so a failure at any point is retried from the top on the next tick:
+//
+//  1. The state secret is written first. It holds the allocated ports and passwords,
+//     so a retry reuses them instead of allocating new ones.
+//  2. The chart is applied, owned by the state secret.
+//  3. Once the deployment is ready (and answers the A2S probe, if enabled), the
+//     connection details are upserted and, the first time they are written, the
+//     teams are notified. Both happen in one transaction: either the site shows the
+//     server and the teams were told, or neither, and the next tick tries again.
+//
+// These steps hold the round lock. A server already running as last ensured is
+// left alone without taking it; see roundSettled.
 func (c *Controller) ensureRound(
 	ctx context.Context,
 	match database.Match,
 	round database.MatchRound,
-	divisionID string,
+	division *database.Division,
 	league *database.League,
 	homeIDs, awayIDs []string,
 	mapName string,
 	details *database.MatchDetails,
 	releaseName string,
 ) error {
+	if c.roundSettled(ctx, match, round, division, league, homeIDs, awayIDs, mapName, details, releaseName) {
+		return nil
+	}
+	ref := ServerRef{MatchID: match.ID, RoundID: round.ID}
+	delete(c.settled, ref)
+
+	unlock, err := c.lockRound(ctx, releaseName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	state, err := c.loadServerState(ctx, releaseName)
 	if err != nil {
 		return fmt.Errorf("load server state: %w", err)
 	}
 
-	isNew := false
 	if state == nil {
+		// A server running without its state secret is taken over rather than
+		// duplicated. This also covers servers paused mode would not create.
+		if state, err = c.adoptServer(ctx, match, round, releaseName); err != nil {
+			return fmt.Errorf("adopt existing server: %w", err)
+		}
+		if state != nil {
+			state.Chart = c.cfg.Chart.Mode(division.Name, league.Name)
+		}
+	}
+	if state == nil && c.Paused() {
+		klog.V(1).Infof("paused, not creating server for match %d round %d", match.ID, round.ID)
+		return nil
+	}
+	if state == nil && details != nil {
+		// The state secret is gone but the teams were already given this server's
+		// address and password: bring it back on the same ones.
+		klog.Infof("restoring advertised password and ports for match %d round %d from match details", match.ID, round.ID)
+		rcon, err := generateSecret(c.secretSource(fmt.Sprintf("rcon/%d/%d", match.ID, round.ID)), c.cfg.SRCDS.RCONLength, c.cfg.SRCDS.RCONAlphabet)
+		if err != nil {
+			return fmt.Errorf("generate rcon: %w", err)
+		}
+		tvPassword, err := c.sourceTVPassword(ctx, match.ID, round.ID)
+		if err != nil {
+			return fmt.Errorf("generate sourcetv password: %w", err)
+		}
+		state = &serverState{
+			ReleaseName: releaseName,
+			Ports:       c.assignmentFromDetails(details),
+			Password:    details.Password,
+			RCON:        rcon,
+			Map:         preferValue(mapName, details.Map, c.cfg.Match.DefaultMap),
+			TVPassword:  tvPassword,
+			Chart:       c.cfg.Chart.Mode(division.Name, league.Name),
+		}
+	}
+	if state == nil {
+		// Servers restored above were already advertised, so only new ones count
+		// against the cap.
+		if err := c.reserveServer(ctx, ref); err != nil {
+			return err
+		}
 		assign, err := c.portAllocator.AllocateWithSecrets(ctx,
 			c.clientset.CoreV1().Services(c.cfg.Namespace),
 			c.clientset.CoreV1().Secrets(c.cfg.Namespace))
 		if err != nil {
+			if errors.Is(err, ErrPortsExhausted) {
+				if _, queued := c.waitingForPorts[ref]; !queued {
+					c.waitingForPorts[ref] = time.Now()
+					c.recordEvent(ctx, corev1.EventTypeWarning, "PortsExhausted",
+						fmt.Sprintf("no free ports for match %d round %d, queued until ports are freed: %v", match.ID, round.ID, err))
+				}
+				c.tick.queue(ref)
+			}
 			return fmt.Errorf("allocate ports: %w", err)
 		}
-		password, err := generateSecret(c.cfg.SRCDS.PasswordLength)
+		c.admitServer()
+		delete(c.waitingForPorts, ref)
+		password, err := generateSecret(c.passwordSource(ctx, fmt.Sprintf("password/%d/%d", match.ID, round.ID)), c.cfg.SRCDS.PasswordLength, c.cfg.SRCDS.PasswordAlphabet)
 		if err != nil {
 			return fmt.Errorf("generate password: %w", err)
 		}
-		rcon, err := generateSecret(c.cfg.SRCDS.RCONLength)
+		rcon, err := generateSecret(c.secretSource(fmt.Sprintf("rcon/%d/%d", match.ID, round.ID)), c.cfg.SRCDS.RCONLength, c.cfg.SRCDS.RCONAlphabet)
 		if err != nil {
 			return fmt.Errorf("generate rcon: %w", err)
 		}
+		tvPassword, err := c.sourceTVPassword(ctx, match.ID, round.ID)
+		if err != nil {
+			return fmt.Errorf("generate sourcetv password: %w", err)
+		}
 
 		token, err := c.generateSRCDSToken(match.ID, round.ID)
 		if err != nil {
@@ -217,10 +1192,17 @@ func (c *Controller) ensureRound(
 			RCON:        rcon,
 			Map:         mapName,
 			Token:       token,
+			TVPassword:  tvPassword,
+			Chart:       c.cfg.Chart.Mode(division.Name, league.Name),
 		}
-		isNew = true
 	} else {
 		state.Map = preferValue(mapName, state.Map, c.cfg.Match.DefaultMap)
+		if state.TVPassword == "" {
+			// State secrets written before SourceTV settings existed.
+			if state.TVPassword, err = c.sourceTVPassword(ctx, match.ID, round.ID); err != nil {
+				return fmt.Errorf("generate sourcetv password: %w", err)
+			}
+		}
 		if state.Token == "" {
 			token, err := c.generateSRCDSToken(match.ID, round.ID)
 			if err != nil {
@@ -232,12 +1214,21 @@ func (c *Controller) ensureRound(
 		}
 	}
 
-	if err := c.persistStateSecret(ctx, match, round, state); err != nil {
+	stateSecret, err := c.persistStateSecret(ctx, match, round, state)
+	if err != nil {
 		return fmt.Errorf("persist secret: %w", err)
 	}
 
-	values := c.buildValues(match, round, divisionID, league, homeIDs, awayIDs, state)
-	if err := c.applyHelmRelease(ctx, releaseName, values); err != nil {
+	values, content, err := c.releaseValues(ctx, match, round, division, league, homeIDs, awayIDs, state)
+	if err != nil {
+		return err
+	}
+	if c.serverConfig != nil {
+		if err := c.applyServerConfig(ctx, match, round, releaseName, content, stateOwnerReference(stateSecret)); err != nil {
+			return fmt.Errorf("apply server config: %w", err)
+		}
+	}
+	if err := c.applyHelmRelease(ctx, state, values, stateOwnerReference(stateSecret)); err != nil {
 		return fmt.Errorf("apply helm release: %w", err)
 	}
 
@@ -247,25 +1238,86 @@ func (c *Controller) ensureRound(
 		klog.Warningf("failed to check deployment status for match %d round %d: %v", match.ID, round.ID, err)
 	}
 
+	// A new server is only advertised once it answers A2S_INFO at the address
+	// players will be given; servers already advertised are not re-probed. With
+	// MATCH_SERVER_STATUS an unreachable server's details are stored as such, so
+	// the site can show it, and it is probed again until it answers.
+	advertised := details != nil
+	if advertised && c.cfg.Match.ServerStatus {
+		status, err := c.repo.FetchServerStatus(ctx, match.ID, round.ID)
+		if err != nil {
+			return fmt.Errorf("fetch server status: %w", err)
+		}
+		advertised = status != database.ServerStatusUnreachable
+	}
+	var probeErr error
+	if ready && !advertised && c.cfg.Networking.ProbeEnabled {
+		if probeErr = c.probeServer(ctx, releaseName, state.Ports.Game); probeErr != nil && !c.cfg.Match.ServerStatus {
+			ready = false
+		}
+	}
+
 	// Only create/update match details if deployment is ready
 	if ready {
-		nodeIP, err := c.pickNodeIP(ctx)
+		nodeIP, err := c.serverNodeIP(ctx, releaseName)
 		if err != nil {
 			return fmt.Errorf("discover node ip: %w", err)
 		}
-
-		detailsPayload := database.MatchDetails{
-			MatchID:      match.ID,
-			RoundID:      round.ID,
-			ServerIP:     nodeIP,
-			Port:         state.Ports.Game,
-			SourceTVPort: state.Ports.SourceTV,
-			Password:     state.Password,
-			Map:          preferValue(state.Map, mapName, c.cfg.Match.DefaultMap),
+		var nodeIPv6 string
+		if c.cfg.Networking.DualStack {
+			// A node without an IPv6 address still serves IPv4 players.
+			if nodeIPv6, err = c.serverNodeIPv6(ctx, releaseName); err != nil {
+				klog.Warningf("no ipv6 address for match %d round %d, advertising %s only: %v", match.ID, round.ID, nodeIP, err)
+			}
+			if nodeIPv6 == nodeIP {
+				nodeIPv6 = ""
+			}
 		}
 
-		if err := c.repo.UpsertMatchDetails(ctx, detailsPayload); err != nil {
-			return fmt.Errorf("upsert match details: %w", err)
+		detailsPayload := c.advertisedDetails(match, round, mapName, nodeIP, state)
+
+		// Teams are told about a server once, when it is first advertised as reachable.
+		notify := !advertised && probeErr == nil && c.cfg.Notifications.Enabled
+		serverStatus := database.ServerStatusRunning
+		if probeErr != nil {
+			serverStatus = database.ServerStatusUnreachable
+		}
+		err = c.repo.WithTx(ctx, func(tx *sql.Tx) error {
+			if err := c.repo.UpsertMatchDetailsTx(ctx, tx, detailsPayload); err != nil {
+				return err
+			}
+			if c.cfg.Networking.DualStack {
+				if err := c.repo.UpdateServerIPv6Tx(ctx, tx, match.ID, round.ID, nodeIPv6); err != nil {
+					return err
+				}
+			}
+			if c.cfg.Match.SourceTVDetails {
+				connect := sourceTVConnect(nodeIP, state.Ports.SourceTV, state.TVPassword)
+				if err := c.repo.UpdateSourceTVTx(ctx, tx, match.ID, round.ID, state.TVPassword, connect); err != nil {
+					return err
+				}
+			}
+			if c.cfg.Match.ServerStatus {
+				if err := c.repo.UpdateServerStatusTx(ctx, tx, match.ID, round.ID, serverStatus); err != nil {
+					return err
+				}
+			}
+			if !notify {
+				return nil
+			}
+			gameAddr := net.JoinHostPort(nodeIP, strconv.Itoa(state.Ports.Game))
+			message := fmt.Sprintf("Match %d Round %d is running on %s with password %s", match.ID, round.ID, gameAddr, state.Password)
+			if nodeIPv6 != "" {
+				message = fmt.Sprintf("Match %d Round %d is running on %s (IPv6 %s) with password %s", match.ID, round.ID, gameAddr, net.JoinHostPort(nodeIPv6, strconv.Itoa(state.Ports.Game)), state.Password)
+			}
+			link := fmt.Sprintf(c.cfg.Notifications.LinkFormat, match.ID)
+			if err := c.repo.SendNotificationsToTeamsTx(ctx, tx, match.RosterHomeID, match.RosterAwayID, message, link); err != nil {
+				return fmt.Errorf("notify teams: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("advertise server: %w", err)
 		}
 	} else {
 		klog.V(2).Infof("deployment not ready yet for match %d round %d, skipping match details creation", match.ID, round.ID)
@@ -278,28 +1330,104 @@ func (c *Controller) ensureRound(
 		}
 	}
 
-	// Only send notifications if the deployment is ready and this is a new server
-	if ready && isNew && c.cfg.Notifications.Enabled {
-		nodeIP, err := c.pickNodeIP(ctx)
-		if err != nil {
-			klog.Errorf("failed to get node IP for notifications: %v", err)
-		} else {
-			message := fmt.Sprintf("Match %d Round %d is running on %s:%d with password %s", match.ID, round.ID, nodeIP, state.Ports.Game, state.Password)
-			link := fmt.Sprintf(c.cfg.Notifications.LinkFormat, match.ID)
-			if err := c.repo.SendNotificationsToTeams(ctx, match.RosterHomeID, match.RosterAwayID, message, link); err != nil {
-				klog.Errorf("notifications failed for match %d: %v", match.ID, err)
-			}
+	if probeErr != nil {
+		return fmt.Errorf("%w: %v", ErrServerUnreachable, probeErr)
+	}
+	if fingerprint := valuesFingerprint(state.Chart, values); ready && fingerprint != "" {
+		c.settled[ref] = fingerprint
+	}
+	return nil
+}
+
+// roundSettled reports whether an advertised round's server is ready, on the
+// node its details name, and running the values ensureRound last applied, so
+// the tick can skip it without taking the round lock or writing anything.
+// Anything else, including a deleted workload, goes through ensureRound.
+func (c *Controller) roundSettled(
+	ctx context.Context,
+	match database.Match,
+	round database.MatchRound,
+	division *database.Division,
+	league *database.League,
+	homeIDs, awayIDs []string,
+	mapName string,
+	details *database.MatchDetails,
+	releaseName string,
+) bool {
+	settled, ok := c.settled[ServerRef{MatchID: match.ID, RoundID: round.ID}]
+	if !ok || details == nil {
+		return false
+	}
+	state, err := c.loadServerState(ctx, releaseName)
+	if err != nil || state == nil {
+		return false
+	}
+	state.Map = preferValue(mapName, state.Map, c.cfg.Match.DefaultMap)
+	values, _, err := c.releaseValues(ctx, match, round, division, league, homeIDs, awayIDs, state)
+	if err != nil || valuesFingerprint(state.Chart, values) != settled {
+		return false
+	}
+	if ready, err := c.isDeploymentReady(ctx, releaseName); err != nil || !ready {
+		return false
+	}
+	nodeIP, err := c.serverNodeIP(ctx, releaseName)
+	return err == nil && *details == c.advertisedDetails(match, round, mapName, nodeIP, state)
+}
+
+// releaseValues builds the chart values for state and, with SERVER_CONFIG, the
+// server config they mount.
+func (c *Controller) releaseValues(
+	ctx context.Context,
+	match database.Match,
+	round database.MatchRound,
+	division *database.Division,
+	league *database.League,
+	homeIDs, awayIDs []string,
+	state *serverState,
+) (chartutil.Values, string, error) {
+	values := c.buildValues(match, round, division, league, homeIDs, awayIDs, state)
+	var content string
+	if c.serverConfig != nil {
+		var err error
+		if content, err = c.renderServerConfig(match, round, division, league, state); err != nil {
+			return nil, "", err
 		}
+		c.withServerConfig(values, state.ReleaseName, content)
+	}
+	return c.withValuesOverride(ctx, match.ID, values), content, nil
+}
+
+// advertisedDetails is the match details row advertising state's server on nodeIP.
+func (c *Controller) advertisedDetails(match database.Match, round database.MatchRound, mapName, nodeIP string, state *serverState) database.MatchDetails {
+	return database.MatchDetails{
+		MatchID:      match.ID,
+		RoundID:      round.ID,
+		ServerIP:     nodeIP,
+		Port:         state.Ports.Game,
+		SourceTVPort: state.Ports.SourceTV,
+		ClientPort:   state.Ports.Client,
+		SteamPort:    state.Ports.Steam,
+		Password:     state.Password,
+		Map:          preferValue(state.Map, mapName, c.cfg.Match.DefaultMap),
 	}
+}
 
-	return nil
+// valuesFingerprint identifies the values applied with a chart, or is empty if
+// they cannot be encoded.
+func valuesFingerprint(chart string, values chartutil.Values) string {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(append([]byte(chart+"\x00"), data...))
+	return hex.EncodeToString(sum[:])
 }
 
 func (c *Controller) teardownRound(
 	ctx context.Context,
 	match database.Match,
 	round database.MatchRound,
-	divisionID string,
+	division *database.Division,
 	league *database.League,
 	homeIDs, awayIDs []string,
 	mapName, releaseName string,
@@ -309,23 +1437,29 @@ func (c *Controller) teardownRound(
 	if err != nil {
 		return fmt.Errorf("load state for teardown: %w", err)
 	}
+	owned := state != nil
 	if state == nil {
 		state = &serverState{
 			ReleaseName: releaseName,
-			Ports: ports.Assignment{
-				Game:     details.Port,
-				SourceTV: details.SourceTVPort,
-				Client:   details.Port + 1,
-				Steam:    details.Port + 2,
-			},
-			Password: details.Password,
-			RCON:     "",
-			Map:      preferValue(details.Map, mapName, c.cfg.Match.DefaultMap),
-			Token:    c.cfg.SRCDS.StaticToken,
+			Ports:       c.assignmentFromDetails(details),
+			Password:    details.Password,
+			RCON:        "",
+			Map:         preferValue(details.Map, mapName, c.cfg.Match.DefaultMap),
+			Token:       c.cfg.SRCDS.StaticToken,
+			Chart:       c.cfg.Chart.Mode(division.Name, league.Name),
 		}
 	}
 
-	if err := c.deleteHelmRelease(ctx, releaseName, c.buildValues(match, round, divisionID, league, homeIDs, awayIDs, state)); err != nil {
+	ref := ServerRef{MatchID: match.ID, RoundID: round.ID}
+	if err := c.archiveArtifacts(ctx, ref, releaseName); err != nil {
+		klog.Warningf("failed to archive artifacts for match %d round %d: %v", match.ID, round.ID, err)
+	}
+
+	// Release objects are owned by the state secret, so deleting it would tear the
+	// server down through garbage collection, but servers created before owner
+	// references were set, or without a state secret, are owned by nothing. They
+	// are deleted explicitly first, and the secret's collection sweeps anything missed.
+	if err := c.deleteHelmRelease(ctx, state, c.buildValues(match, round, division, league, homeIDs, awayIDs, state)); err != nil {
 		// If Helm deletion fails, try direct resource cleanup as fallback
 		klog.Errorf("helm release deletion failed for %s, attempting direct cleanup: %v", releaseName, err)
 		if directErr := c.directResourceCleanup(ctx, releaseName); directErr != nil {
@@ -333,48 +1467,75 @@ func (c *Controller) teardownRound(
 		}
 		klog.Infof("direct cleanup succeeded for %s after helm deletion failure", releaseName)
 	}
+	if owned {
+		if err := c.deleteStateSecret(ctx, releaseName); err != nil {
+			return fmt.Errorf("delete state secret: %w", err)
+		}
+	}
 
 	if err := c.repo.DeleteMatchDetails(ctx, match.ID, round.ID); err != nil {
 		return fmt.Errorf("delete match details: %w", err)
 	}
 
-	if err := c.deleteStateSecret(ctx, releaseName); err != nil {
-		klog.Errorf("failed to delete state secret %s: %v", releaseName, err)
-		// Don't return early - continue with other cleanup
-	}
-
 	// Clean up Steam token if enabled
 	if err := c.cleanupSRCDSToken(match.ID, round.ID); err != nil {
 		klog.Warningf("failed to cleanup SRCDS token for match %d round %d: %v", match.ID, round.ID, err)
 	}
 
+	delete(c.waitingForPorts, ref)
+	delete(c.waitingForCapacity, ref)
+	delete(c.idleSince, ref)
+	delete(c.changeLevelRetries, ref)
+	delete(c.outcomeSeen, ref)
+	delete(c.settled, ref)
+	c.cancelCountdown(ref)
 	klog.Infof("tore down server for match %d round %d", match.ID, round.ID)
 	return nil
 }
 
+// assignmentFromDetails rebuilds a port assignment when the state secret is gone.
+// Rows carrying every port are exact, as is contiguous allocation; older rows under
+// independent ranges can only be guessed.
+func (c *Controller) assignmentFromDetails(details *database.MatchDetails) ports.Assignment {
+	if details.ClientPort > 0 && details.SteamPort > 0 {
+		return ports.Assignment{
+			Game:     details.Port,
+			SourceTV: details.SourceTVPort,
+			Client:   details.ClientPort,
+			Steam:    details.SteamPort,
+		}
+	}
+	if c.cfg.Ports.Mode == config.PortAllocationContiguous {
+		return ports.ContiguousAssignment(details.Port)
+	}
+	return ports.Assignment{
+		Game:     details.Port,
+		SourceTV: details.SourceTVPort,
+		Client:   details.Port + 1,
+		Steam:    details.Port + 2,
+	}
+}
+
 func (c *Controller) buildValues(
 	match database.Match,
 	round database.MatchRound,
-	divisionID string,
+	division *database.Division,
 	league *database.League,
 	homeIDs, awayIDs []string,
 	state *serverState,
 ) chartutil.Values {
-	maxPlayers := league.MaxPlayers
-	if c.cfg.SRCDS.MaxPlayersOverride > 0 {
-		maxPlayers = c.cfg.SRCDS.MaxPlayersOverride
-	}
-
 	env := []map[string]interface{}{
 		envVar("SRCDS_PORT", state.Ports.Game),
 		envVar("SRCDS_PW", state.Password),
 		envVar("SRCDS_MAXPLAYERS", "24"),
-		envVar("SRCDS_TICKRATE", c.cfg.SRCDS.TickRate),
+		envVar("SRCDS_TICKRATE", c.tickRate(league)),
 		envVar("SRCDS_RCONPW", state.RCON),
 		envVar("SRCDS_STARTMAP", preferValue(state.Map, c.cfg.Match.DefaultMap, "")),
-		envVar("SRCDS_STATIC_HOSTNAME", fmt.Sprintf("UDL.TF | %d | Round #%d", match.ID, round.ID)),
+		envVar("SRCDS_STATIC_HOSTNAME", c.serverHostname(match, round, division, league, state)),
 		envVar("SRCDS_TOKEN", state.Token),
 		envVar("SRCDS_TV_PORT", state.Ports.SourceTV),
+		envVar("SRCDS_TV_DELAY", c.cfg.SRCDS.TVDelay),
+		envVar("SRCDS_TV_PASSWORD", state.TVPassword),
 		envVar("SRCDS_CLIENT_PORT", state.Ports.Client),
 		envVar("SRCDS_STEAM_PORT", state.Ports.Steam),
 		envVar("MATCH_ID", match.ID),
@@ -384,24 +1545,20 @@ func (c *Controller) buildValues(
 		envVar("HOME_TEAM", strings.Join(homeIDs, ",")),
 		envVar("HOME_TEAM_ID", match.RosterHomeID),
 		envVar("MIN_PLAYERS", league.MinPlayers),
-		envVar("MAX_PLAYERS", maxPlayers),
-		envVar("WIN_LIMIT", match.WinLimit),
+		envVar("MAX_PLAYERS", c.maxPlayers(league)),
 	}
-
-	appPorts := []map[string]interface{}{
-		namedPort("game-udp", state.Ports.Game, "UDP", 0),
-		namedPort("game-tcp", state.Ports.Game, "TCP", 0),
-		namedPort("sourcetv", state.Ports.SourceTV, "UDP", 0),
-		namedPort("client", state.Ports.Client, "UDP", 0),
-		namedPort("steam", state.Ports.Steam, "UDP", 0),
+	if c.cfg.SRCDS.TVTitle != "" {
+		env = append(env, envVar("SRCDS_TV_TITLE", c.cfg.SRCDS.TVTitle))
 	}
+	env = append(env, c.limitEnv(match, league)...)
+	env = c.appendExtraEnv(env, division)
 
-	servicePorts := []map[string]interface{}{
-		servicePort("game-udp", state.Ports.Game, state.Ports.Game, "UDP"),
-		servicePort("game-tcp", state.Ports.Game, state.Ports.Game, "TCP"),
-		servicePort("sourcetv", state.Ports.SourceTV, state.Ports.SourceTV, "UDP"),
-		servicePort("client", state.Ports.Client, state.Ports.Client, "UDP"),
-		servicePort("steam", state.Ports.Steam, state.Ports.Steam, "UDP"),
+	// The game port is listed once per protocol: srcds serves UDP game traffic
+	// and TCP RCON on the same number.
+	var appPorts, servicePorts []map[string]interface{}
+	for _, sp := range state.Ports.Ports() {
+		appPorts = append(appPorts, namedPort(sp.Name, sp.Port, string(sp.Protocol), 0))
+		servicePorts = append(servicePorts, servicePort(sp.Name, sp.Port, sp.Port, string(sp.Protocol)))
 	}
 
 	// Always create services for port tracking and operational visibility
@@ -413,18 +1570,23 @@ func (c *Controller) buildValues(
 	}
 
 	// Add metadata when using hostNetwork to clarify purpose
+	serviceAnnotations := withCommon(withCommon(nil, c.cfg.Networking.ServiceAnnotations), c.cfg.CommonAnnotations)
 	if c.cfg.Networking.HostNetwork {
-		serviceConfig["annotations"] = map[string]interface{}{
-			"udl.tf/purpose":     "port-tracking",
-			"udl.tf/hostNetwork": "true",
-		}
+		serviceAnnotations["udl.tf/purpose"] = "port-tracking"
+		serviceAnnotations["udl.tf/hostNetwork"] = "true"
+	}
+	if len(serviceAnnotations) > 0 {
+		serviceConfig["annotations"] = serviceAnnotations
+	}
+	if len(c.cfg.CommonLabels) > 0 {
+		serviceConfig["labels"] = withCommon(nil, c.cfg.CommonLabels)
 	}
 
 	values := chartutil.Values{
 		"workload": map[string]interface{}{
-			"kind":               "Deployment",
+			"kind":               string(c.cfg.SRCDS.WorkloadKind),
 			"nameOverride":       state.ReleaseName,
-			"deploymentStrategy": map[string]interface{}{"type": "Recreate"},
+			"deploymentStrategy": map[string]interface{}{"type": string(c.strategyFor(division))},
 		},
 		"service": serviceConfig,
 		"app": map[string]interface{}{
@@ -512,11 +1674,47 @@ func (c *Controller) buildValues(
 			"group":            1000,
 			"chmod":            "775",
 		},
-		"podLabels": map[string]interface{}{
+		"podLabels": withCommon(map[string]interface{}{
 			"udl.tf/match-id": strconv.Itoa(match.ID),
 			"udl.tf/round-id": strconv.Itoa(round.ID),
-			"udl.tf/division": divisionID,
-		},
+			"udl.tf/division": division.ID,
+		}, c.cfg.CommonLabels),
+	}
+	if len(c.cfg.CommonAnnotations) > 0 {
+		values["podAnnotations"] = withCommon(nil, c.cfg.CommonAnnotations)
+	}
+
+	if resources := c.resourcesFor(division); !resources.IsZero() {
+		app := values["app"].(map[string]interface{})
+		app["resources"] = resourceValues(resources)
+	}
+
+	if image := c.imageFor(division); !image.IsZero() {
+		app := values["app"].(map[string]interface{})
+		imageValues := map[string]interface{}{}
+		if image.Repository != "" {
+			imageValues["repository"] = image.Repository
+		}
+		if image.Tag != "" {
+			imageValues["tag"] = image.Tag
+		}
+		app["image"] = imageValues
+	}
+
+	if len(c.cfg.SRCDS.NodeSelector) > 0 {
+		nodeSelector := map[string]interface{}{}
+		for key, value := range c.cfg.SRCDS.NodeSelector {
+			nodeSelector[key] = value
+		}
+		values["nodeSelector"] = nodeSelector
+	}
+
+	if len(c.cfg.SRCDS.Affinity) > 0 {
+		values["affinity"] = c.cfg.SRCDS.Affinity
+	}
+
+	if len(c.cfg.SRCDS.Tolerations) > 0 {
+		values["tolerations"] = c.cfg.SRCDS.Tolerations
 	}
 
 	if c.cfg.Networking.HostNetwork {
@@ -524,13 +1722,37 @@ func (c *Controller) buildValues(
 		values["dnsPolicy"] = "ClusterFirstWithHostNet"
 	}
 
-	// Apply external traffic policy if configured, regardless of hostNetwork setting
-	// (for consistency and potential future use cases)
+	// The policy is set with hostNetwork too, so the Service always matches the
+	// configuration, but it only affects traffic routed through the NodePort:
+	// with hostNetwork players reach the pod on the node directly and their
+	// source IPs are preserved either way.
 	if c.cfg.Networking.ExternalTrafficPolicy != "" {
 		service := values["service"].(map[string]interface{})
 		service["externalTrafficPolicy"] = c.cfg.Networking.ExternalTrafficPolicy
 	}
 
+	// PreferDualStack still creates the Service on single-stack clusters, so
+	// enabling this ahead of a cluster upgrade is harmless.
+	if c.cfg.Networking.DualStack {
+		service := values["service"].(map[string]interface{})
+		service["ipFamilyPolicy"] = "PreferDualStack"
+		service["ipFamilies"] = []interface{}{"IPv4", "IPv6"}
+	}
+
+	return values
+}
+
+// withCommon adds COMMON_LABELS/COMMON_ANNOTATIONS entries to chart metadata values
+// without overriding keys the controller sets itself.
+func withCommon(values map[string]interface{}, common map[string]string) map[string]interface{} {
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	for k, v := range common {
+		if _, ok := values[k]; !ok {
+			values[k] = v
+		}
+	}
 	return values
 }
 
@@ -554,6 +1776,99 @@ func (c *Controller) divisionMatchesFilter(name string) bool {
 	return false
 }
 
+// resourcesFor layers any per-division override on top of the global SRCDS resources.
+func (c *Controller) resourcesFor(division *database.Division) config.ResourceConfig {
+	resources := c.cfg.SRCDS.Resources
+	if override, ok := c.cfg.SRCDS.DivisionResources[strings.ToLower(strings.TrimSpace(division.Name))]; ok {
+		resources = resources.Merge(override)
+	}
+	return resources
+}
+
+// limitEnv derives WIN_LIMIT and MAX_ROUNDS from the match's win_limit using the
+// league's configured strategy, so first-to and best-of formats can coexist.
+func (c *Controller) limitEnv(match database.Match, league *database.League) []map[string]interface{} {
+	winLimit, maxRounds := c.roundLimits(match, league)
+	env := []map[string]interface{}{envVar("WIN_LIMIT", winLimit)}
+	if maxRounds > 0 {
+		env = append(env, envVar("MAX_ROUNDS", maxRounds))
+	}
+	return env
+}
+
+// roundLimits returns the win limit and round limit of a match under its
+// league's limit strategy. A maxRounds of 0 leaves the rounds unlimited.
+func (c *Controller) roundLimits(match database.Match, league *database.League) (winLimit, maxRounds int) {
+	strategy := c.cfg.Match.LimitStrategy
+	if override, ok := c.cfg.Match.LeagueLimits[strings.ToLower(strings.TrimSpace(league.Name))]; ok {
+		strategy = override
+	}
+
+	switch strategy {
+	case config.LimitBestOf:
+		return match.WinLimit/2 + 1, match.WinLimit
+	case config.LimitRoundLimit:
+		return 0, match.WinLimit
+	default:
+		return match.WinLimit, 0
+	}
+}
+
+// appendExtraEnv adds SRCDS_EXTRA_ENV and the division's overrides to env, sorted
+// by name so rendered values stay stable. Keys the controller already sets are kept.
+func (c *Controller) appendExtraEnv(env []map[string]interface{}, division *database.Division) []map[string]interface{} {
+	extra := map[string]string{}
+	for key, value := range c.cfg.SRCDS.ExtraEnv {
+		extra[key] = value
+	}
+	for key, value := range c.cfg.SRCDS.DivisionExtraEnv[strings.ToLower(strings.TrimSpace(division.Name))] {
+		extra[key] = value
+	}
+	for _, entry := range env {
+		name := entry["name"].(string)
+		if _, ok := extra[name]; ok {
+			klog.V(2).Infof("ignoring extra env %s: set by the controller", name)
+			delete(extra, name)
+		}
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, envVar(name, extra[name]))
+	}
+	return env
+}
+
+func (c *Controller) imageFor(division *database.Division) config.ImageConfig {
+	image := c.cfg.SRCDS.Image
+	if override, ok := c.cfg.SRCDS.DivisionImages[strings.ToLower(strings.TrimSpace(division.Name))]; ok {
+		image = image.Merge(override)
+	}
+	return image
+}
+
+// strategyFor returns the deployment strategy for the division, falling back to
+// SRCDS_DEPLOYMENT_STRATEGY.
+func (c *Controller) strategyFor(division *database.Division) config.DeploymentStrategy {
+	if strategy, ok := c.cfg.SRCDS.DivisionStrategies[strings.ToLower(strings.TrimSpace(division.Name))]; ok {
+		return strategy
+	}
+	return c.cfg.SRCDS.Strategy
+}
+
+func (c *Controller) isMatchStatusTargeted(status int) bool {
+	for _, targetStatus := range c.cfg.Match.TargetStatuses {
+		if status == targetStatus {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Controller) isMatchStatusCompleted(status int) bool {
 	for _, completedStatus := range c.cfg.Match.CompletedStatuses {
 		if status == completedStatus {
@@ -579,11 +1894,19 @@ func (c *Controller) cleanupOrphanedServers(ctx context.Context) error {
 			continue
 		}
 
+		ref := ServerRef{MatchID: detail.MatchID, RoundID: detail.RoundID}
+
 		// If match is completed, tear down the server
 		if c.isMatchStatusCompleted(match.Status) {
+			if c.inTeardownGrace(ctx, ref) || c.countingDown(ctx, ref, &detail, c.releaseName(ref.MatchID, ref.RoundID)) {
+				continue
+			}
 			klog.Infof("cleaning up orphaned server for completed match %d round %d", detail.MatchID, detail.RoundID)
 			if err := c.cleanupServerByDetails(ctx, detail); err != nil {
+				c.tick.fail()
 				klog.Errorf("failed to cleanup server for match %d round %d: %v", detail.MatchID, detail.RoundID, err)
+			} else {
+				c.tick.tearDown()
 			}
 			continue
 		}
@@ -597,9 +1920,15 @@ func (c *Controller) cleanupOrphanedServers(ctx context.Context) error {
 
 		// If round has outcome and manual flag is not set, tear down
 		if round.HasOutcome && !match.ManualNotDone {
+			if c.inTeardownGrace(ctx, ref) || c.countingDown(ctx, ref, &detail, c.releaseName(ref.MatchID, ref.RoundID)) {
+				continue
+			}
 			klog.Infof("cleaning up orphaned server for match %d round %d (has outcome)", detail.MatchID, detail.RoundID)
 			if err := c.cleanupServerByDetails(ctx, detail); err != nil {
+				c.tick.fail()
 				klog.Errorf("failed to cleanup server for match %d round %d: %v", detail.MatchID, detail.RoundID, err)
+			} else {
+				c.tick.tearDown()
 			}
 		}
 	}
@@ -609,7 +1938,12 @@ func (c *Controller) cleanupOrphanedServers(ctx context.Context) error {
 
 // cleanupServerByDetails tears down a server using just the match details
 func (c *Controller) cleanupServerByDetails(ctx context.Context, detail database.MatchDetails) error {
-	releaseName := releaseName(detail.MatchID, detail.RoundID)
+	releaseName := c.releaseName(detail.MatchID, detail.RoundID)
+	unlock, err := c.lockRound(ctx, releaseName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Load state from secret
 	state, err := c.loadServerState(ctx, releaseName)
@@ -618,19 +1952,15 @@ func (c *Controller) cleanupServerByDetails(ctx context.Context, detail database
 	}
 
 	// If no state found, reconstruct minimal state from details
+	owned := state != nil
 	if state == nil {
 		state = &serverState{
 			ReleaseName: releaseName,
-			Ports: ports.Assignment{
-				Game:     detail.Port,
-				SourceTV: detail.SourceTVPort,
-				Client:   detail.Port + 1,
-				Steam:    detail.Port + 2,
-			},
-			Password: detail.Password,
-			RCON:     "",
-			Map:      detail.Map,
-			Token:    c.cfg.SRCDS.StaticToken,
+			Ports:       c.assignmentFromDetails(&detail),
+			Password:    detail.Password,
+			RCON:        "",
+			Map:         detail.Map,
+			Token:       c.cfg.SRCDS.StaticToken,
 		}
 	}
 
@@ -671,6 +2001,9 @@ func (c *Controller) cleanupServerByDetails(ctx context.Context, detail database
 		}
 		return fmt.Errorf("fetch league for cleanup: %w", err)
 	}
+	if !owned {
+		state.Chart = c.cfg.Chart.Mode(division.Name, league.Name)
+	}
 
 	homeIDs, err := c.repo.FetchTeamSteamIDs(ctx, match.RosterHomeID)
 	if err != nil {
@@ -685,9 +2018,14 @@ func (c *Controller) cleanupServerByDetails(ctx context.Context, detail database
 	}
 
 	// Use the complete values structure like teardownRound does
-	values := c.buildValues(*match, *round, division.ID, league, homeIDs, awayIDs, state)
+	values := c.buildValues(*match, *round, division, league, homeIDs, awayIDs, state)
+
+	ref := ServerRef{MatchID: detail.MatchID, RoundID: detail.RoundID}
+	if err := c.archiveArtifacts(ctx, ref, releaseName); err != nil {
+		klog.Warningf("failed to archive artifacts for match %d round %d: %v", detail.MatchID, detail.RoundID, err)
+	}
 
-	if err := c.deleteHelmRelease(ctx, releaseName, values); err != nil {
+	if err := c.deleteHelmRelease(ctx, state, values); err != nil {
 		return fmt.Errorf("delete helm release for cleanup: %w", err)
 	}
 
@@ -706,13 +2044,13 @@ func (c *Controller) cleanupServerByDetails(ctx context.Context, detail database
 		klog.Warningf("failed to cleanup SRCDS token for match %d round %d: %v", detail.MatchID, detail.RoundID, err)
 	}
 
+	delete(c.outcomeSeen, ref)
+	delete(c.settled, ref)
+	c.cancelCountdown(ref)
 	klog.Infof("cleaned up orphaned server for match %d round %d", detail.MatchID, detail.RoundID)
 	return nil
 }
 
-// releaseNamePattern matches the naming convention "udl-{matchID}-r{roundID}"
-var releaseNamePattern = regexp.MustCompile(`^udl-(\d+)-r(\d+)$`)
-
 // danglingDeploymentGracePeriod is the minimum age a deployment must have before
 // being considered for dangling cleanup. This prevents deleting deployments that
 // are still being provisioned (deployment exists but DB record not yet created).
@@ -723,9 +2061,9 @@ const danglingDeploymentGracePeriod = 5 * time.Minute
 // records were deleted but Kubernetes resources remain.
 func (c *Controller) cleanupDanglingDeployments(ctx context.Context) error {
 	// List all deployments in the namespace
-	deployments, err := c.clientset.AppsV1().Deployments(c.cfg.Namespace).List(ctx, metav1.ListOptions{})
+	workloads, err := c.listWorkloads(ctx)
 	if err != nil {
-		return fmt.Errorf("list deployments: %w", err)
+		return err
 	}
 
 	// Get all match details to build a set of known servers
@@ -737,30 +2075,21 @@ func (c *Controller) cleanupDanglingDeployments(ctx context.Context) error {
 	// Build a set of known release names from database
 	knownReleaseNames := make(map[string]bool)
 	for _, detail := range allDetails {
-		knownReleaseNames[releaseName(detail.MatchID, detail.RoundID)] = true
+		knownReleaseNames[c.releaseName(detail.MatchID, detail.RoundID)] = true
 	}
 
 	// Find deployments that match our naming pattern but have no database record
-	for _, deployment := range deployments.Items {
-		name := deployment.Name
+	for _, workload := range workloads {
+		name := workload.Name
 
 		// Check if this deployment matches our naming pattern
-		matches := releaseNamePattern.FindStringSubmatch(name)
-		if matches == nil {
+		ref, ok := c.workloadRound(workload)
+		if !ok {
 			continue // Not a tournament server deployment
 		}
+		matchID, roundID := ref.MatchID, ref.RoundID
 
-		// Parse match and round IDs
-		matchID, err := strconv.Atoi(matches[1])
-		if err != nil {
-			continue
-		}
-		roundID, err := strconv.Atoi(matches[2])
-		if err != nil {
-			continue
-		}
-
-		relName := releaseName(matchID, roundID)
+		relName := c.releaseName(matchID, roundID)
 
 		// If this release name is known in the database, skip it
 		// (it will be handled by normal cleanup logic)
@@ -770,7 +2099,7 @@ func (c *Controller) cleanupDanglingDeployments(ctx context.Context) error {
 
 		// Skip deployments that are younger than the grace period - they may still
 		// be provisioning (deployment created but DB record not yet inserted)
-		deploymentAge := time.Since(deployment.CreationTimestamp.Time)
+		deploymentAge := time.Since(workload.Created)
 		if deploymentAge < danglingDeploymentGracePeriod {
 			klog.V(2).Infof("skipping dangling deployment %s (age %v < grace period %v), may still be provisioning",
 				name, deploymentAge.Round(time.Second), danglingDeploymentGracePeriod)
@@ -780,10 +2109,16 @@ func (c *Controller) cleanupDanglingDeployments(ctx context.Context) error {
 		// This deployment has no database record - it's dangling
 		klog.Infof("found dangling deployment %s (match %d round %d) with no database record, cleaning up", name, matchID, roundID)
 
+		if err := c.archiveArtifacts(ctx, ref, relName); err != nil {
+			klog.Warningf("failed to archive artifacts for dangling deployment %s: %v", name, err)
+		}
+
 		if err := c.directResourceCleanup(ctx, relName); err != nil {
+			c.tick.fail()
 			klog.Errorf("failed to cleanup dangling deployment %s: %v", name, err)
 			continue
 		}
+		c.tick.tearDown()
 
 		// Also try to clean up any state secret that might exist
 		if err := c.deleteStateSecret(ctx, relName); err != nil {
@@ -834,7 +2169,17 @@ func (c *Controller) directResourceCleanup(ctx context.Context, releaseName stri
 		klog.Errorf("failed to delete deployments for %s: %v", releaseName, err)
 	}
 
+	// Delete statefulsets
+	if err := c.clientset.AppsV1().StatefulSets(c.cfg.Namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	}); err != nil && !k8serrors.IsNotFound(err) {
+		klog.Errorf("failed to delete statefulsets for %s: %v", releaseName, err)
+	}
+
 	// Delete secrets (both state secrets and any other secrets with the label)
+	if err := c.retainStateSecret(ctx, releaseName); err != nil {
+		klog.Warningf("failed to retain state secret for %s: %v", releaseName, err)
+	}
 	if err := c.clientset.CoreV1().Secrets(c.cfg.Namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{
 		LabelSelector: labelSelector,
 	}); err != nil && !k8serrors.IsNotFound(err) {
@@ -852,18 +2197,34 @@ func (c *Controller) directResourceCleanup(ctx context.Context, releaseName stri
 	return nil
 }
 
-func (c *Controller) applyHelmRelease(ctx context.Context, releaseName string, overrides chartutil.Values) error {
-	if c.renderer == nil {
-		return fmt.Errorf("helm renderer is not configured")
+func (c *Controller) applyHelmRelease(ctx context.Context, state *serverState, overrides chartutil.Values, owner *metav1.OwnerReference) error {
+	renderer, err := c.rendererFor(state.Chart)
+	if err != nil {
+		return err
+	}
+	return renderer.Apply(ctx, state.ReleaseName, overrides, owner)
+}
+
+func (c *Controller) deleteHelmRelease(ctx context.Context, state *serverState, overrides chartutil.Values) error {
+	renderer, err := c.rendererFor(state.Chart)
+	if err != nil {
+		return err
 	}
-	return c.renderer.Apply(ctx, releaseName, overrides)
+	return renderer.Delete(ctx, state.ReleaseName, overrides)
 }
 
-func (c *Controller) deleteHelmRelease(ctx context.Context, releaseName string, overrides chartutil.Values) error {
-	if c.renderer == nil {
-		return fmt.Errorf("helm renderer is not configured")
+// rendererFor returns the renderer of a game mode's chart. A server keeps the
+// chart it was created with, so a mode dropped from GAME_MODE_CHARTS leaves its
+// running servers unmanageable until it is restored.
+func (c *Controller) rendererFor(mode string) (Renderer, error) {
+	renderer, ok := c.renderers[mode]
+	if !ok || renderer == nil {
+		if mode == "" {
+			return nil, fmt.Errorf("helm renderer is not configured")
+		}
+		return nil, fmt.Errorf("no chart configured for game mode %s", mode)
 	}
-	return c.renderer.Delete(ctx, releaseName, overrides)
+	return renderer, nil
 }
 
 // isDeploymentReady checks if the deployment has at least one running pod
@@ -900,6 +2261,18 @@ func (c *Controller) isDeploymentReady(ctx context.Context, releaseName string)
 	return false, nil
 }
 
+// serverHostname expands SRCDS_HOSTNAME_TEMPLATE for a round. Placeholders are
+// validated when the config loads.
+func (c *Controller) serverHostname(match database.Match, round database.MatchRound, division *database.Division, league *database.League, state *serverState) string {
+	return strings.NewReplacer(
+		"{match_id}", strconv.Itoa(match.ID),
+		"{round_id}", strconv.Itoa(round.ID),
+		"{division}", division.Name,
+		"{map}", preferValue(state.Map, c.cfg.Match.DefaultMap, ""),
+		"{league}", league.Name,
+	).Replace(c.cfg.SRCDS.HostnameTemplate)
+}
+
 func envVar(name string, value interface{}) map[string]interface{} {
 	return map[string]interface{}{
 		"name":  name,
@@ -913,37 +2286,146 @@ func namedPort(name string, port int, protocol string, hostPort int) map[string]
 		"containerPort": port,
 		"protocol":      protocol,
 	}
-	if hostPort > 0 {
-		entry["hostPort"] = hostPort
+	if hostPort > 0 {
+		entry["hostPort"] = hostPort
+	}
+	return entry
+}
+
+func resourceValues(res config.ResourceConfig) map[string]interface{} {
+	requests := map[string]interface{}{}
+	limits := map[string]interface{}{}
+	if res.CPURequest != "" {
+		requests["cpu"] = res.CPURequest
+	}
+	if res.MemoryRequest != "" {
+		requests["memory"] = res.MemoryRequest
+	}
+	if res.CPULimit != "" {
+		limits["cpu"] = res.CPULimit
+	}
+	if res.MemoryLimit != "" {
+		limits["memory"] = res.MemoryLimit
+	}
+
+	out := map[string]interface{}{}
+	if len(requests) > 0 {
+		out["requests"] = requests
+	}
+	if len(limits) > 0 {
+		out["limits"] = limits
+	}
+	return out
+}
+
+func servicePort(name string, port, target int, protocol string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       name,
+		"port":       port,
+		"targetPort": target,
+		"protocol":   protocol,
+		"nodePort":   port,
+	}
+}
+
+// serverNodeIP returns the address of the node running the release's pod, so the
+// advertised IP is the node the server actually landed on. It falls back to
+// pickNodeIP when the pod has not been scheduled yet. Configured overrides win
+// over anything discovered from node status.
+func (c *Controller) serverNodeIP(ctx context.Context, releaseName string) (string, error) {
+	return c.serverNodeAddr(ctx, releaseName, c.cfg.Networking.NodeIPFamily)
+}
+
+// serverNodeIPv6 returns the IPv6 address of the node running the release's pod,
+// advertised alongside serverNodeIP with SERVICE_DUAL_STACK. Overrides only
+// apply when they are IPv6 addresses.
+func (c *Controller) serverNodeIPv6(ctx context.Context, releaseName string) (string, error) {
+	return c.serverNodeAddr(ctx, releaseName, config.NodeIPFamilyIPv6)
+}
+
+func (c *Controller) serverNodeAddr(ctx context.Context, releaseName string, family config.NodeIPFamily) (string, error) {
+	networking := c.cfg.Networking
+	override := c.overrideFor(networking.NodeIPOverride, family)
+	if len(networking.NodeIPMap) == 0 && override != "" {
+		return override, nil
+	}
+
+	pods, err := c.clientset.CoreV1().Pods(c.cfg.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app.kubernetes.io/instance=%s", releaseName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("list pods: %w", err)
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.Spec.NodeName == "" {
+			continue
+		}
+		if addr := c.overrideFor(networking.NodeIPMap[pod.Spec.NodeName], family); addr != "" {
+			return addr, nil
+		}
+		if override != "" {
+			return override, nil
+		}
+		node, err := c.clientset.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("get node %s: %w", pod.Spec.NodeName, err)
+		}
+		if addr := c.nodeAddress(node, family); addr != "" {
+			return addr, nil
+		}
+		if addr := familyAddress(podHostIPs(pod), family); addr != "" {
+			return addr, nil
+		}
+	}
+
+	if override != "" {
+		return override, nil
 	}
-	return entry
+
+	klog.V(2).Infof("no scheduled pod found for %s, falling back to any node IP", releaseName)
+	return c.pickNodeIP(ctx, family)
 }
 
-func servicePort(name string, port, target int, protocol string) map[string]interface{} {
-	return map[string]interface{}{
-		"name":       name,
-		"port":       port,
-		"targetPort": target,
-		"protocol":   protocol,
-		"nodePort":   port,
+// podHostIPs lists a pod's host addresses, which cover both families on
+// dual-stack nodes.
+func podHostIPs(pod corev1.Pod) []string {
+	addrs := []string{pod.Status.HostIP}
+	for _, hostIP := range pod.Status.HostIPs {
+		addrs = append(addrs, hostIP.IP)
+	}
+	return addrs
+}
+
+// overrideFor returns a configured override address if it may be advertised for
+// family. Overrides are taken verbatim for the configured family; the extra
+// IPv6 lookup of a dual-stack Service only uses IPv6 overrides.
+func (c *Controller) overrideFor(addr string, family config.NodeIPFamily) string {
+	if addr == "" || family == c.cfg.Networking.NodeIPFamily {
+		return addr
+	}
+	if familyAddress([]string{addr}, family) == "" {
+		return ""
 	}
+	return addr
 }
 
-func (c *Controller) pickNodeIP(ctx context.Context) (string, error) {
+func (c *Controller) pickNodeIP(ctx context.Context, family config.NodeIPFamily) (string, error) {
 	nodes, err := c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return "", err
 	}
 	var internalCandidate string
-	for _, node := range nodes.Items {
-		for _, addr := range node.Status.Addresses {
-			if addr.Type == corev1.NodeExternalIP && isIPv4(addr.Address) && c.cfg.Networking.NodeIPPreference == config.NodeIPExternalFirst {
-				return addr.Address, nil
-			}
-			if addr.Type == corev1.NodeInternalIP && isIPv4(addr.Address) && internalCandidate == "" {
-				internalCandidate = addr.Address
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if c.cfg.Networking.NodeIPPreference == config.NodeIPExternalFirst {
+			if addr := nodeAddressOfType(node, corev1.NodeExternalIP, family); addr != "" {
+				return addr, nil
 			}
 		}
+		if addr := nodeAddressOfType(node, corev1.NodeInternalIP, family); addr != "" && internalCandidate == "" {
+			internalCandidate = addr
+		}
 	}
 	if internalCandidate != "" {
 		return internalCandidate, nil
@@ -951,13 +2433,129 @@ func (c *Controller) pickNodeIP(ctx context.Context) (string, error) {
 	return "", fmt.Errorf("no suitable node IP found")
 }
 
+// nodeAddress picks a single node's address according to the configured preference.
+func (c *Controller) nodeAddress(node *corev1.Node, family config.NodeIPFamily) string {
+	if c.cfg.Networking.NodeIPPreference == config.NodeIPExternalFirst {
+		if addr := nodeAddressOfType(node, corev1.NodeExternalIP, family); addr != "" {
+			return addr
+		}
+	}
+	return nodeAddressOfType(node, corev1.NodeInternalIP, family)
+}
+
+func nodeAddressOfType(node *corev1.Node, addrType corev1.NodeAddressType, family config.NodeIPFamily) string {
+	var candidates []string
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == addrType {
+			candidates = append(candidates, addr.Address)
+		}
+	}
+	return familyAddress(candidates, family)
+}
+
+// familyAddress returns the first candidate allowed by family. The dual family
+// prefers IPv4 and falls back to IPv6.
+func familyAddress(candidates []string, family config.NodeIPFamily) string {
+	if family == config.NodeIPFamilyIPv4 || family == config.NodeIPFamilyDual || family == "" {
+		for _, addr := range candidates {
+			if isIPv4(addr) {
+				return strings.TrimSpace(addr)
+			}
+		}
+	}
+	if family == config.NodeIPFamilyIPv6 || family == config.NodeIPFamilyDual {
+		for _, addr := range candidates {
+			if isIPv6(addr) {
+				return strings.TrimSpace(addr)
+			}
+		}
+	}
+	return ""
+}
+
 func isIPv4(addr string) bool {
 	ip := net.ParseIP(strings.TrimSpace(addr))
 	return ip != nil && ip.To4() != nil
 }
 
-func releaseName(matchID, roundID int) string {
-	return fmt.Sprintf("udl-%d-r%d", matchID, roundID)
+func isIPv6(addr string) bool {
+	ip := net.ParseIP(strings.TrimSpace(addr))
+	return ip != nil && ip.To4() == nil
+}
+
+// releaseName names the Helm release, and so the state secret and labels, of a round.
+func (c *Controller) releaseName(matchID, roundID int) string {
+	return c.cfg.Release.Name(matchID, roundID)
+}
+
+// parseReleaseName recovers the IDs from a name produced by releaseName.
+func (c *Controller) parseReleaseName(name string) (matchID, roundID int, ok bool) {
+	m := c.releasePattern.FindStringSubmatch(name)
+	if m == nil {
+		return 0, 0, false
+	}
+	matchID, err := strconv.Atoi(m[c.releasePattern.SubexpIndex("match")])
+	if err != nil {
+		return 0, 0, false
+	}
+	roundID, err = strconv.Atoi(m[c.releasePattern.SubexpIndex("round")])
+	if err != nil {
+		return 0, 0, false
+	}
+	return matchID, roundID, true
+}
+
+// serverWorkload is a server's Deployment or StatefulSet, reduced to what the
+// cleanup paths need.
+type serverWorkload struct {
+	Name      string
+	Created   time.Time
+	PodLabels map[string]string
+}
+
+// listWorkloads lists the Deployments and StatefulSets in the namespace. Both kinds
+// are listed whatever SRCDS_WORKLOAD_KIND is, so servers created before the kind
+// was changed are still found.
+func (c *Controller) listWorkloads(ctx context.Context) ([]serverWorkload, error) {
+	deployments, err := c.clientset.AppsV1().Deployments(c.cfg.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	statefulSets, err := c.clientset.AppsV1().StatefulSets(c.cfg.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list statefulsets: %w", err)
+	}
+
+	workloads := make([]serverWorkload, 0, len(deployments.Items)+len(statefulSets.Items))
+	for _, d := range deployments.Items {
+		workloads = append(workloads, serverWorkload{Name: d.Name, Created: d.CreationTimestamp.Time, PodLabels: d.Spec.Template.Labels})
+	}
+	for _, s := range statefulSets.Items {
+		workloads = append(workloads, serverWorkload{Name: s.Name, Created: s.CreationTimestamp.Time, PodLabels: s.Spec.Template.Labels})
+	}
+	return workloads, nil
+}
+
+// workloadRound identifies the round a server workload belongs to. Names
+// shortened by hash truncation cannot be parsed, so the round is then read from the
+// pod labels and accepted only if it would produce exactly this name.
+func (c *Controller) workloadRound(w serverWorkload) (ServerRef, bool) {
+	if matchID, roundID, ok := c.parseReleaseName(w.Name); ok {
+		return ServerRef{MatchID: matchID, RoundID: roundID}, true
+	}
+	labels := w.PodLabels
+	matchID, err := strconv.Atoi(labels["udl.tf/match-id"])
+	if err != nil {
+		return ServerRef{}, false
+	}
+	roundID, err := strconv.Atoi(labels["udl.tf/round-id"])
+	if err != nil {
+		return ServerRef{}, false
+	}
+	if c.releaseName(matchID, roundID) != w.Name {
+		return ServerRef{}, false
+	}
+	return ServerRef{MatchID: matchID, RoundID: roundID}, true
 }
 
 func (c *Controller) loadServerState(ctx context.Context, releaseName string) (*serverState, error) {
@@ -1014,11 +2612,22 @@ func (c *Controller) loadServerState(ctx context.Context, releaseName string) (*
 		RCON:     parse(secretKeyRCON),
 		Map:      parse(secretKeyMap),
 		Token:    parse(secretKeyToken),
+		LiveRCON: parse(secretKeyLiveRCON),
+		NextRCON: parse(secretKeyNextRCON),
+
+		TVPassword: parse(secretKeyTVPassword),
+		Chart:      parse(secretKeyChart),
+	}
+	state.RCONRotatedAt = secret.CreationTimestamp.Time
+	if raw := parse(secretKeyRotatedAt); raw != "" {
+		if rotatedAt, err := time.Parse(time.RFC3339, raw); err == nil {
+			state.RCONRotatedAt = rotatedAt
+		}
 	}
 	return state, nil
 }
 
-func (c *Controller) persistStateSecret(ctx context.Context, match database.Match, round database.MatchRound, state *serverState) error {
+func (c *Controller) persistStateSecret(ctx context.Context, match database.Match, round database.MatchRound, state *serverState) (*corev1.Secret, error) {
 	secretName := c.secretName(state.ReleaseName)
 	desired := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -1039,41 +2648,166 @@ func (c *Controller) persistStateSecret(ctx context.Context, match database.Matc
 			secretKeySteamPort:  []byte(strconv.Itoa(state.Ports.Steam)),
 			secretKeyMap:        []byte(preferValue(state.Map, c.cfg.Match.DefaultMap, "")),
 			secretKeyToken:      []byte(state.Token),
+			secretKeyTVPassword: []byte(state.TVPassword),
+			secretKeyChart:      []byte(state.Chart),
 		},
 		Type: corev1.SecretTypeOpaque,
 	}
+	if state.LiveRCON != "" {
+		desired.Data[secretKeyLiveRCON] = []byte(state.LiveRCON)
+		desired.Data[secretKeyRotatedAt] = []byte(state.RCONRotatedAt.UTC().Format(time.RFC3339))
+	}
+	if state.NextRCON != "" {
+		desired.Data[secretKeyNextRCON] = []byte(state.NextRCON)
+	}
+	for k, v := range c.cfg.CommonLabels {
+		if _, ok := desired.Labels[k]; !ok {
+			desired.Labels[k] = v
+		}
+	}
+	if len(c.cfg.CommonAnnotations) > 0 {
+		desired.Annotations = c.cfg.CommonAnnotations
+	}
 
 	secrets := c.clientset.CoreV1().Secrets(c.cfg.Namespace)
 	existing, err := secrets.Get(ctx, secretName, metav1.GetOptions{})
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
-			_, err = secrets.Create(ctx, desired, metav1.CreateOptions{})
+			return secrets.Create(ctx, desired, metav1.CreateOptions{})
 		}
-		return err
+		return nil, err
 	}
 
 	desired.ResourceVersion = existing.ResourceVersion
-	_, err = secrets.Update(ctx, desired, metav1.UpdateOptions{})
-	return err
+	return secrets.Update(ctx, desired, metav1.UpdateOptions{})
 }
 
+// stateOwnerReference makes the state secret the owner of every object in its
+// release, so deleting the secret garbage-collects the whole server.
+func stateOwnerReference(secret *corev1.Secret) *metav1.OwnerReference {
+	blockOwnerDeletion := false
+	return &metav1.OwnerReference{
+		APIVersion:         "v1",
+		Kind:               "Secret",
+		Name:               secret.Name,
+		UID:                secret.UID,
+		BlockOwnerDeletion: &blockOwnerDeletion,
+	}
+}
+
+// deleteStateSecret removes the state secret; background propagation lets the
+// garbage collector remove every object it owns.
 func (c *Controller) deleteStateSecret(ctx context.Context, releaseName string) error {
+	// A failed copy must not keep a finished server running.
+	if err := c.retainStateSecret(ctx, releaseName); err != nil {
+		klog.Warningf("failed to retain state secret for %s: %v", releaseName, err)
+	}
 	secrets := c.clientset.CoreV1().Secrets(c.cfg.Namespace)
-	if err := secrets.Delete(ctx, c.secretName(releaseName), metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+	propagation := metav1.DeletePropagationBackground
+	if err := secrets.Delete(ctx, c.secretName(releaseName), metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil && !k8serrors.IsNotFound(err) {
 		return err
 	}
 	return nil
 }
 
+// cleanupUntargetedServers tears down servers whose match status has left
+// TargetStatuses, e.g. a postponed match. Such matches are no longer fetched by
+// reconcile, so without this their servers would run until the round had an outcome.
+func (c *Controller) cleanupUntargetedServers(ctx context.Context) error {
+	workloads, err := c.listWorkloads(ctx)
+	if err != nil {
+		return err
+	}
+
+	checked := map[int]bool{}
+	for _, workload := range workloads {
+		ref, ok := c.workloadRound(workload)
+		if !ok {
+			continue
+		}
+
+		match, err := c.repo.FetchMatchByID(ctx, ref.MatchID)
+		if err != nil {
+			// Matches without a record are handled by the dangling deployment cleanup.
+			if !errors.Is(err, database.ErrNotFound) {
+				klog.Warningf("failed to fetch match %d for status check: %v", ref.MatchID, err)
+			}
+			continue
+		}
+		// Completed matches are torn down by cleanupOrphanedServers, which honors
+		// TEARDOWN_GRACE, and by cleanupDanglingDeployments when they have no details.
+		if c.isMatchStatusTargeted(match.Status) || c.isMatchStatusCompleted(match.Status) {
+			continue
+		}
+
+		if !checked[ref.MatchID] {
+			checked[ref.MatchID] = true
+			klog.Infof("match %d moved to untargeted status %d, tearing down its servers", ref.MatchID, match.Status)
+		}
+		details, err := c.repo.FetchMatchDetails(ctx, ref.MatchID, ref.RoundID)
+		if err != nil {
+			klog.Warningf("failed to fetch match details for match %d round %d, not counting down: %v", ref.MatchID, ref.RoundID, err)
+		}
+		if details != nil && c.countingDown(ctx, ref, details, c.releaseName(ref.MatchID, ref.RoundID)) {
+			continue
+		}
+		if err := c.deleteServer(ctx, ref.MatchID, ref.RoundID, false); err != nil {
+			c.tick.fail()
+			klog.Errorf("failed to tear down server for match %d round %d: %v", ref.MatchID, ref.RoundID, err)
+		} else {
+			c.tick.tearDown()
+		}
+	}
+	return nil
+}
+
 func (c *Controller) secretName(releaseName string) string {
 	return fmt.Sprintf("%s-settings", releaseName)
 }
 
-func generateSecret(length int) (string, error) {
-	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+// secretSource returns the randomness a new secret is drawn from: crypto/rand, or
+// with UNSAFE_DETERMINISTIC_SECRETS_SEED a stream derived from the seed and label,
+// so the same label always yields the same secret.
+func (c *Controller) secretSource(label string) io.Reader {
+	if c.cfg.SRCDS.UnsafeSecretSeed == "" {
+		return rand.Reader
+	}
+	return newSeededReader([]byte(c.cfg.SRCDS.UnsafeSecretSeed), label)
+}
+
+func newSeededReader(key []byte, label string) *seededReader {
+	return &seededReader{mac: hmac.New(sha256.New, key), label: label}
+}
+
+// seededReader is an endless HMAC-SHA256 keystream over label and a block counter.
+type seededReader struct {
+	mac     hash.Hash
+	label   string
+	counter uint64
+	buf     []byte
+}
+
+func (r *seededReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			r.mac.Reset()
+			r.mac.Write([]byte(r.label))
+			r.mac.Write(binary.BigEndian.AppendUint64(nil, r.counter))
+			r.buf = r.mac.Sum(nil)
+			r.counter++
+		}
+		copied := copy(p[n:], r.buf)
+		r.buf = r.buf[copied:]
+		n += copied
+	}
+	return n, nil
+}
+
+func generateSecret(source io.Reader, length int, alphabet string) (string, error) {
 	output := make([]byte, length)
 	for i := range output {
-		idxBig, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		idxBig, err := rand.Int(source, big.NewInt(int64(len(alphabet))))
 		if err != nil {
 			return "", err
 		}
@@ -1097,7 +2831,7 @@ func preferValue(primary string, fallbacks ...string) string {
 // otherwise falls back to the static token.
 func (c *Controller) generateSRCDSToken(matchID int, roundID int) (string, error) {
 	// If auto token generation is disabled or no Steam client, use static token
-	if !c.cfg.Steam.EnableAutoTokens || c.steamClient == nil {
+	if !c.cfg.Steam.EnableAutoTokens || c.tokens == nil {
 		return c.cfg.SRCDS.StaticToken, nil
 	}
 
@@ -1105,7 +2839,7 @@ func (c *Controller) generateSRCDSToken(matchID int, roundID int) (string, error
 	memo := fmt.Sprintf(c.cfg.Steam.TokenMemoTemplate, matchID, roundID)
 
 	// Create a new Steam account for this server
-	account, err := c.steamClient.CreateAccount(c.cfg.Steam.AppID, memo)
+	account, err := c.tokens.CreateAccount(c.cfg.Steam.AppID, memo)
 	if err != nil {
 		return "", fmt.Errorf("create steam account: %w", err)
 	}
@@ -1119,7 +2853,7 @@ func (c *Controller) generateSRCDSToken(matchID int, roundID int) (string, error
 // if token cleanup is enabled.
 func (c *Controller) cleanupSRCDSToken(matchID int, roundID int) error {
 	// If token cleanup is disabled or no Steam client, nothing to do
-	if !c.cfg.Steam.EnableTokenCleanup || c.steamClient == nil {
+	if !c.cfg.Steam.EnableTokenCleanup || c.tokens == nil {
 		return nil
 	}
 
@@ -1127,7 +2861,7 @@ func (c *Controller) cleanupSRCDSToken(matchID int, roundID int) error {
 	memo := fmt.Sprintf(c.cfg.Steam.TokenMemoTemplate, matchID, roundID)
 
 	// Get all Steam accounts
-	accounts, err := c.steamClient.GetAccountList()
+	accounts, err := c.tokens.GetAccountList()
 	if err != nil {
 		return fmt.Errorf("get account list: %w", err)
 	}
@@ -1135,7 +2869,7 @@ func (c *Controller) cleanupSRCDSToken(matchID int, roundID int) error {
 	// Find and delete accounts with matching memo
 	for _, account := range accounts {
 		if account.Memo == memo && !account.IsDeleted {
-			if err := c.steamClient.DeleteAccount(account.SteamID); err != nil {
+			if err := c.tokens.DeleteAccount(account.SteamID); err != nil {
 				klog.Warningf("failed to delete Steam account %s: %v", account.SteamID, err)
 			} else {
 				klog.V(2).Infof("deleted Steam account %s for match %d round %d", account.SteamID, matchID, roundID)
@@ -1153,6 +2887,71 @@ type serverState struct {
 	RCON        string
 	Map         string
 	Token       string
+
+	// LiveRCON is the password rotation last pushed to the running server. RCON
+	// stays the boot password in the pod env, so rotating never restarts the server.
+	// NextRCON is a rotation saved but not yet confirmed by the server.
+	LiveRCON      string
+	NextRCON      string
+	RCONRotatedAt time.Time
+
+	TVPassword string
+
+	// Chart is the game mode whose chart the server was created with; "" is the
+	// default chart, which servers from before GAME_MODE_CHARTS also use.
+	Chart string
+}
+
+// rconPasswords lists the passwords to try against the running server, most
+// recent first. A restarted pod is back on the boot password.
+func (s *serverState) rconPasswords() []string {
+	passwords := []string{}
+	for _, password := range []string{s.NextRCON, s.LiveRCON, s.RCON} {
+		if password != "" && !slices.Contains(passwords, password) {
+			passwords = append(passwords, password)
+		}
+	}
+	return passwords
+}
+
+// tickRate returns the tickrate of a league's servers: its SRCDS_LEAGUE_SETTINGS
+// entry, or SRCDS_TICKRATE.
+func (c *Controller) tickRate(league *database.League) int {
+	if rate := c.cfg.SRCDS.LeagueSettings[league.ID].TickRate; rate > 0 {
+		return rate
+	}
+	return c.cfg.SRCDS.TickRate
+}
+
+// maxPlayers returns the player limit of a league's servers: its
+// SRCDS_LEAGUE_SETTINGS entry, SRCDS_MAX_PLAYERS_OVERRIDE, or the league's own.
+func (c *Controller) maxPlayers(league *database.League) int {
+	if override := c.cfg.SRCDS.LeagueSettings[league.ID].MaxPlayers; override > 0 {
+		return override
+	}
+	if c.cfg.SRCDS.MaxPlayersOverride > 0 {
+		return c.cfg.SRCDS.MaxPlayersOverride
+	}
+	return league.MaxPlayers
+}
+
+// sourceTVConnect formats the console command spectators paste to join a
+// server's SourceTV, leaving out the password when it has none.
+func sourceTVConnect(ip string, port int, password string) string {
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
+	if password == "" {
+		return "connect " + addr
+	}
+	return fmt.Sprintf(`connect %s; password "%s"`, addr, password)
+}
+
+// sourceTVPassword returns the SourceTV password for a new round's server: the
+// shared SRCDS_TV_PASSWORD, or one generated like the game password.
+func (c *Controller) sourceTVPassword(ctx context.Context, matchID, roundID int) (string, error) {
+	if c.cfg.SRCDS.TVPassword != "" {
+		return c.cfg.SRCDS.TVPassword, nil
+	}
+	return generateSecret(c.passwordSource(ctx, fmt.Sprintf("tv-password/%d/%d", matchID, roundID)), c.cfg.SRCDS.PasswordLength, c.cfg.SRCDS.PasswordAlphabet)
 }
 
 const (
@@ -1164,6 +2963,11 @@ const (
 	secretKeySteamPort  = "steam_port"
 	secretKeyMap        = "map"
 	secretKeyToken      = "token"
+	secretKeyLiveRCON   = "rcon_live"
+	secretKeyNextRCON   = "rcon_pending"
+	secretKeyRotatedAt  = "rcon_rotated_at"
+	secretKeyTVPassword = "tv_password"
+	secretKeyChart      = "chart"
 )
 
 // DeleteServer deletes a tournament server and all associated resources for a specific match and round.
@@ -1173,6 +2977,12 @@ const (
 // - State secrets
 // - Steam tokens (if enabled)
 func (c *Controller) DeleteServer(ctx context.Context, matchID, roundID int) error {
+	return c.deleteServer(ctx, matchID, roundID, true)
+}
+
+// deleteServer is DeleteServer, counting down TEARDOWN_COUNTDOWN on the server
+// first only when announce is set.
+func (c *Controller) deleteServer(ctx context.Context, matchID, roundID int, announce bool) error {
 	klog.Infof("deleting server for match %d round %d", matchID, roundID)
 
 	// Fetch match data
@@ -1226,11 +3036,28 @@ func (c *Controller) DeleteServer(ctx context.Context, matchID, roundID int) err
 		klog.Warningf("failed to fetch match details for match %d round %d: %v", matchID, roundID, err)
 	}
 
-	releaseName := releaseName(matchID, roundID)
+	releaseName := c.releaseName(matchID, roundID)
 	klog.Infof("using release name: %s", releaseName)
 
+	// The countdown runs before the round's Lease is taken, so the reconcile loop
+	// is not held up waiting for it. Without the state secret the RCON password is
+	// unknown and nobody can be warned.
+	if announce && details != nil && c.cfg.Match.TeardownCountdown > 0 {
+		if state, err := c.loadServerState(ctx, releaseName); err != nil {
+			klog.Warningf("not counting down match %d round %d shutdown: %v", matchID, roundID, err)
+		} else if state != nil {
+			c.announceShutdown(ctx, ServerRef{MatchID: matchID, RoundID: roundID}, details, state)
+		}
+	}
+
+	unlock, err := c.lockRound(ctx, releaseName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Use teardownRound to perform the actual cleanup
-	if err := c.teardownRound(ctx, *match, *round, division.ID, league, homeIDs, awayIDs, mapName, releaseName, details); err != nil {
+	if err := c.teardownRound(ctx, *match, *round, division, league, homeIDs, awayIDs, mapName, releaseName, details); err != nil {
 		klog.Errorf("teardownRound failed for match %d round %d, attempting direct cleanup: %v", matchID, roundID, err)
 
 		// Fallback to direct resource cleanup
@@ -1257,3 +3084,228 @@ func (c *Controller) DeleteServer(ctx context.Context, matchID, roundID int) err
 	klog.Infof("successfully deleted server for match %d round %d", matchID, roundID)
 	return nil
 }
+
+// ServerRef identifies a managed server by its match and round.
+type ServerRef struct {
+	MatchID int `json:"matchId"`
+	RoundID int `json:"roundId"`
+}
+
+// ManagedServers lists every server known either from a Deployment following the
+// release naming convention or from a matches_server_details row.
+func (c *Controller) ManagedServers(ctx context.Context) ([]ServerRef, error) {
+	seen := map[ServerRef]bool{}
+	var refs []ServerRef
+	add := func(ref ServerRef) {
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	workloads, err := c.listWorkloads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, workload := range workloads {
+		if ref, ok := c.workloadRound(workload); ok {
+			add(ref)
+		}
+	}
+
+	allDetails, err := c.repo.FetchAllMatchDetails(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetch all match details: %w", err)
+	}
+	for _, detail := range allDetails {
+		add(ServerRef{MatchID: detail.MatchID, RoundID: detail.RoundID})
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].MatchID != refs[j].MatchID {
+			return refs[i].MatchID < refs[j].MatchID
+		}
+		return refs[i].RoundID < refs[j].RoundID
+	})
+	return refs, nil
+}
+
+// MatchServers lists the managed servers belonging to a single match.
+func (c *Controller) MatchServers(ctx context.Context, matchID int) ([]ServerRef, error) {
+	refs, err := c.ManagedServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []ServerRef
+	for _, ref := range refs {
+		if ref.MatchID == matchID {
+			out = append(out, ref)
+		}
+	}
+	return out, nil
+}
+
+// OrphanedServers lists managed servers whose match no longer exists, has left the
+// target statuses, or is completed.
+func (c *Controller) OrphanedServers(ctx context.Context) ([]ServerRef, error) {
+	refs, err := c.ManagedServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []ServerRef
+	for _, ref := range refs {
+		match, err := c.repo.FetchMatchByID(ctx, ref.MatchID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				out = append(out, ref)
+				continue
+			}
+			return nil, err
+		}
+		if !c.isMatchStatusTargeted(match.Status) || c.isMatchStatusCompleted(match.Status) {
+			out = append(out, ref)
+		}
+	}
+	return out, nil
+}
+
+// DeleteServers tears down each server in turn. Servers whose match or round is
+// gone from the database are removed with direct resource cleanup instead.
+func (c *Controller) DeleteServers(ctx context.Context, refs []ServerRef) error {
+	failed := 0
+	for _, ref := range refs {
+		if err := c.deleteServerRef(ctx, ref); err != nil {
+			klog.Errorf("failed to delete server for match %d round %d: %v", ref.MatchID, ref.RoundID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d servers failed to delete", failed, len(refs))
+	}
+	return nil
+}
+
+func (c *Controller) deleteServerRef(ctx context.Context, ref ServerRef) error {
+	_, matchErr := c.repo.FetchMatchByID(ctx, ref.MatchID)
+	_, roundErr := c.repo.FetchMatchRoundByID(ctx, ref.MatchID, ref.RoundID)
+	if !errors.Is(matchErr, database.ErrNotFound) && !errors.Is(roundErr, database.ErrNotFound) {
+		return c.DeleteServer(ctx, ref.MatchID, ref.RoundID)
+	}
+
+	relName := c.releaseName(ref.MatchID, ref.RoundID)
+	unlock, err := c.lockRound(ctx, relName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := c.directResourceCleanup(ctx, relName); err != nil {
+		return fmt.Errorf("direct cleanup: %w", err)
+	}
+	if err := c.repo.DeleteMatchDetails(ctx, ref.MatchID, ref.RoundID); err != nil {
+		return err
+	}
+	if err := c.deleteStateSecret(ctx, relName); err != nil {
+		klog.Warningf("failed to delete state secret %s: %v", relName, err)
+	}
+	if err := c.cleanupSRCDSToken(ref.MatchID, ref.RoundID); err != nil {
+		klog.Warningf("failed to cleanup SRCDS token for match %d round %d: %v", ref.MatchID, ref.RoundID, err)
+	}
+	klog.Infof("deleted server for match %d round %d with no database record", ref.MatchID, ref.RoundID)
+	return nil
+}
+
+// ReconcileMatch runs one reconcile pass for a single match, as a tick would, and
+// returns what it decided. Matches outside MATCH_STATUSES are skipped, as the
+// next tick would tear their servers down again, and so are matches another
+// controller manages through MATCH_ID_ALLOWLIST.
+func (c *Controller) ReconcileMatch(ctx context.Context, matchID int) (MatchStatus, error) {
+	c.tick = &tickSummary{start: time.Now(), matches: 1}
+	defer func() {
+		c.tick.log()
+		c.tick = nil
+	}()
+
+	match, err := c.repo.FetchMatchByID(ctx, matchID)
+	if err != nil {
+		return MatchStatus{MatchID: matchID}, fmt.Errorf("failed to fetch match %d: %w", matchID, err)
+	}
+
+	status := MatchStatus{MatchID: match.ID, Status: match.Status, ManualNotDone: match.ManualNotDone}
+	if !c.isMatchStatusTargeted(match.Status) {
+		status.Skipped = fmt.Sprintf("status %d is not in MATCH_STATUSES", match.Status)
+		return status, nil
+	}
+	if allow := c.cfg.Match.IDAllowlist; len(allow) > 0 && !slices.Contains(allow, match.ID) {
+		status.Skipped = "not in MATCH_ID_ALLOWLIST"
+		return status, nil
+	}
+
+	c.checkExpiredTokens()
+	err = c.reconcileMatch(ctx, *match, nil, &status)
+	c.recordReconcileResult(ctx, match.ID, err)
+	if err != nil {
+		status.Error = err.Error()
+		status.Reason = FailureReason(err)
+		c.tick.fail()
+		return status, err
+	}
+	return status, nil
+}
+
+// DiffServer reports what applying the current desired state for a match and round
+// would change on the live objects, without modifying anything.
+func (c *Controller) DiffServer(ctx context.Context, matchID, roundID int) (string, error) {
+	match, err := c.repo.FetchMatchByID(ctx, matchID)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch match %d: %w", matchID, err)
+	}
+
+	round, err := c.repo.FetchMatchRoundByID(ctx, matchID, roundID)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch round %d for match %d: %w", roundID, matchID, err)
+	}
+
+	division, err := c.repo.FetchDivision(ctx, match.RosterHomeID)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch division for match %d: %w", matchID, err)
+	}
+
+	league, err := c.repo.FetchLeague(ctx, division.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch league for match %d: %w", matchID, err)
+	}
+
+	homeIDs, err := c.repo.FetchTeamSteamIDs(ctx, match.RosterHomeID)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch home team steam IDs for match %d: %w", matchID, err)
+	}
+
+	awayIDs, err := c.repo.FetchTeamSteamIDs(ctx, match.RosterAwayID)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch away team steam IDs for match %d: %w", matchID, err)
+	}
+
+	releaseName := c.releaseName(matchID, roundID)
+	state, err := c.loadServerState(ctx, releaseName)
+	if err != nil {
+		return "", fmt.Errorf("load server state: %w", err)
+	}
+	if state == nil {
+		return "", fmt.Errorf("no state secret for %s, server has not been provisioned", releaseName)
+	}
+
+	if mapName, err := c.repo.FetchMapName(ctx, round.MapID); err == nil {
+		state.Map = preferValue(c.mapOverrides(ctx, matchID)[roundID], mapName, state.Map, c.cfg.Match.DefaultMap)
+	}
+
+	values, _, err := c.releaseValues(ctx, *match, *round, division, league, homeIDs, awayIDs, state)
+	if err != nil {
+		return "", err
+	}
+	renderer, err := c.rendererFor(state.Chart)
+	if err != nil {
+		return "", err
+	}
+	return renderer.Diff(ctx, releaseName, values)
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+// countdownMarks are the remaining times announced during a shutdown countdown,
+// besides its start.
+var countdownMarks = []time.Duration{5 * time.Minute, 2 * time.Minute, time.Minute, 30 * time.Second, 10 * time.Second, 5 * time.Second}
+
+// shutdownCountdown is a TEARDOWN_COUNTDOWN in progress: the server is deleted
+// on the first tick after deadline, and cancel stops the announcements.
+type shutdownCountdown struct {
+	deadline time.Time
+	cancel   context.CancelFunc
+}
+
+// countingDown reports whether a server the reconcile loop is about to tear
+// down should be left up for its TEARDOWN_COUNTDOWN. The first call announces
+// the shutdown and records its deadline; the remaining announcements are sent
+// from a goroutine, so the tick is not held up. A server that cannot be warned
+// over RCON is torn down at once: there is nobody who could be warned.
+func (c *Controller) countingDown(ctx context.Context, ref ServerRef, details *database.MatchDetails, releaseName string) bool {
+	if c.cfg.Match.TeardownCountdown <= 0 {
+		return false
+	}
+	if countdown, ok := c.countdowns[ref]; ok {
+		return time.Now().Before(countdown.deadline)
+	}
+	state, err := c.loadServerState(ctx, releaseName)
+	if err != nil || state == nil {
+		return false
+	}
+
+	addr := net.JoinHostPort(details.ServerIP, strconv.Itoa(details.Port))
+	countdown := c.cfg.Match.TeardownCountdown
+	deadline := time.Now().Add(countdown)
+	if err := sayRCON(ctx, addr, state, "Server shutting down in "+formatCountdown(countdown)); err != nil {
+		klog.Warningf("not counting down match %d round %d shutdown: %v", ref.MatchID, ref.RoundID, err)
+		return false
+	}
+	klog.Infof("counting down %v before tearing down match %d round %d", countdown, ref.MatchID, ref.RoundID)
+
+	announceCtx, cancel := context.WithDeadline(context.Background(), deadline)
+	c.countdowns[ref] = shutdownCountdown{deadline: deadline, cancel: cancel}
+	go func() {
+		defer cancel()
+		announceCountdown(announceCtx, ref, addr, state, deadline)
+	}()
+	return true
+}
+
+// cancelCountdown stops the countdown of a server that is kept after all, or
+// forgets one whose server was torn down.
+func (c *Controller) cancelCountdown(ref ServerRef) {
+	if countdown, ok := c.countdowns[ref]; ok {
+		countdown.cancel()
+		delete(c.countdowns, ref)
+	}
+}
+
+// announceShutdown counts down TEARDOWN_COUNTDOWN on a server before it is
+// deleted, blocking until the countdown is over. The delete command uses it;
+// the reconcile loop counts down without blocking through countingDown.
+func (c *Controller) announceShutdown(ctx context.Context, ref ServerRef, details *database.MatchDetails, state *serverState) {
+	addr := net.JoinHostPort(details.ServerIP, strconv.Itoa(details.Port))
+	countdown := c.cfg.Match.TeardownCountdown
+	deadline := time.Now().Add(countdown)
+
+	if err := sayRCON(ctx, addr, state, "Server shutting down in "+formatCountdown(countdown)); err != nil {
+		klog.Warningf("not counting down match %d round %d shutdown: %v", ref.MatchID, ref.RoundID, err)
+		return
+	}
+	klog.Infof("counting down %v before tearing down match %d round %d", countdown, ref.MatchID, ref.RoundID)
+	announceCountdown(ctx, ref, addr, state, deadline)
+	sleepContext(ctx, time.Until(deadline))
+}
+
+// announceCountdown says the remaining time at each of countdownMarks before
+// deadline, returning after the last one or when ctx ends.
+func announceCountdown(ctx context.Context, ref ServerRef, addr string, state *serverState, deadline time.Time) {
+	for _, mark := range countdownMarks {
+		if time.Until(deadline) <= mark {
+			continue
+		}
+		if !sleepContext(ctx, time.Until(deadline.Add(-mark))) {
+			return
+		}
+		if err := sayRCON(ctx, addr, state, "Server shutting down in "+formatCountdown(mark)); err != nil {
+			klog.V(1).Infof("shutdown announcement for match %d round %d failed: %v", ref.MatchID, ref.RoundID, err)
+		}
+	}
+}
+
+// sayRCON broadcasts message to everyone on the server.
+func sayRCON(ctx context.Context, addr string, state *serverState, message string) error {
+	client, err := dialRCON(ctx, addr, state)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if _, err := client.Execute(fmt.Sprintf("say %q", message)); err != nil {
+		return fmt.Errorf("rcon say: %w", err)
+	}
+	return nil
+}
+
+// formatCountdown renders d for players, e.g. "2 minutes" or "30 seconds".
+func formatCountdown(d time.Duration) string {
+	if d >= time.Minute && d%time.Minute == 0 {
+		if d == time.Minute {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	}
+	seconds := int(d.Round(time.Second) / time.Second)
+	if seconds == 1 {
+		return "1 second"
+	}
+	return fmt.Sprintf("%d seconds", seconds)
+}
+
+// sleepContext waits for d and reports false if ctx ended first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+// fakeRCON is a Source RCON server accepting any password and recording the
+// commands it is sent.
+type fakeRCON struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeRCON(t *testing.T) *fakeRCON {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	server := &fakeRCON{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeRCON) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var header struct{ Size, ID, Type int32 }
+		if err := binary.Read(conn, binary.LittleEndian, &header); err != nil {
+			return
+		}
+		body := make([]byte, header.Size-8)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		switch header.Type {
+		case typeAuth:
+			s.reply(conn, header.ID, typeResponseValue)
+			s.reply(conn, header.ID, typeAuthResponse)
+		case typeExecCommand:
+			s.mu.Lock()
+			s.commands = append(s.commands, string(bytes.TrimRight(body, "\x00")))
+			s.mu.Unlock()
+			s.reply(conn, header.ID, typeResponseValue)
+		default:
+			s.reply(conn, header.ID, typeResponseValue)
+		}
+	}
+}
+
+func (s *fakeRCON) reply(conn net.Conn, id, packetType int32) {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, []int32{10, id, packetType})
+	buf.Write([]byte{0, 0})
+	_, _ = conn.Write(buf.Bytes())
+}
+
+func (s *fakeRCON) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.commands...)
+}
+
+// Packet types of the Source RCON protocol, as the rcon package uses them.
+const (
+	typeResponseValue = 0
+	typeExecCommand   = 2
+	typeAuthResponse  = 2
+	typeAuth          = 3
+)
+
+func TestCountingDownDoesNotBlock(t *testing.T) {
+	tc := newTestController(t)
+	tc.cfg.Match.TeardownCountdown = time.Minute
+	tc.reconcileOK(t)
+
+	server := newFakeRCON(t)
+	_, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	gamePort, _ := strconv.Atoi(port)
+	details := &database.MatchDetails{MatchID: testMatchID, RoundID: testRoundID, ServerIP: "127.0.0.1", Port: gamePort}
+	ref := ServerRef{MatchID: testMatchID, RoundID: testRoundID}
+	releaseName := tc.releaseName(testMatchID, testRoundID)
+
+	start := time.Now()
+	if !tc.countingDown(context.Background(), ref, details, releaseName) {
+		t.Fatal("countdown did not start")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("starting the countdown blocked for %v", elapsed)
+	}
+	if sent := server.sent(); len(sent) != 1 || sent[0] != `say "Server shutting down in 1 minute"` {
+		t.Errorf("announced %q, want the countdown's start", sent)
+	}
+	if !tc.countingDown(context.Background(), ref, details, releaseName) {
+		t.Error("countdown ended before its deadline")
+	}
+
+	// Past the deadline the server is torn down, without counting down again.
+	tc.countdowns[ref] = shutdownCountdown{deadline: time.Now().Add(-time.Second), cancel: tc.countdowns[ref].cancel}
+	if tc.countingDown(context.Background(), ref, details, releaseName) {
+		t.Error("countdown still running past its deadline")
+	}
+	tc.cancelCountdown(ref)
+	if _, ok := tc.countdowns[ref]; ok {
+		t.Error("cancelled countdown was kept")
+	}
+}
+
+func TestCountingDownSkipsUnreachableServer(t *testing.T) {
+	tc := newTestController(t)
+	tc.cfg.Match.TeardownCountdown = time.Minute
+	tc.reconcileOK(t)
+
+	// Nothing listens on a closed listener's port, so the first announcement fails.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	_ = listener.Close()
+	gamePort, _ := strconv.Atoi(port)
+	details := &database.MatchDetails{MatchID: testMatchID, RoundID: testRoundID, ServerIP: "127.0.0.1", Port: gamePort}
+
+	ref := ServerRef{MatchID: testMatchID, RoundID: testRoundID}
+	if tc.countingDown(context.Background(), ref, details, tc.releaseName(testMatchID, testRoundID)) {
+		t.Error("counting down on a server nobody can be warned on")
+	}
+}
+
+func TestReconcileKeepsFinishedRoundDuringCountdown(t *testing.T) {
+	tc := newTestController(t)
+	tc.cfg.Match.TeardownGrace = 10 * time.Minute
+	tc.cfg.Match.TeardownCountdown = time.Minute
+	tc.reconcileOK(t)
+
+	// Point the advertised address at a fake server so the countdown can start.
+	server := newFakeRCON(t)
+	_, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	gamePort, _ := strconv.Atoi(port)
+	key := [2]int{testMatchID, testRoundID}
+	tc.repo.mu.Lock()
+	details := tc.repo.Details[key]
+	details.ServerIP, details.Port = "127.0.0.1", gamePort
+	tc.repo.Details[key] = details
+	tc.repo.UpdatedAt[key] = time.Now().Add(-20 * time.Minute)
+	tc.repo.mu.Unlock()
+
+	tc.setRound(func(round *database.MatchRound) { round.HasOutcome = true })
+	tc.reconcileOK(t)
+	if len(tc.renderer.deleted) > 0 {
+		t.Fatalf("server was deleted while counting down: %v", tc.renderer.deleted)
+	}
+	if _, ok := tc.details(); !ok {
+		t.Fatal("match details were deleted while counting down")
+	}
+	if sent := server.sent(); len(sent) == 0 {
+		t.Error("the shutdown was not announced")
+	}
+
+	// Once the countdown is over, the next tick tears the server down.
+	ref := ServerRef{MatchID: testMatchID, RoundID: testRoundID}
+	tc.countdowns[ref] = shutdownCountdown{deadline: time.Now().Add(-time.Second), cancel: tc.countdowns[ref].cancel}
+	tc.reconcileOK(t)
+	if _, ok := tc.details(); ok {
+		t.Error("match details were kept after the countdown")
+	}
+	if _, ok := tc.countdowns[ref]; ok {
+		t.Error("countdown was kept after teardown")
+	}
+}
+
+func TestDeleteServerCountsDownBeforeLocking(t *testing.T) {
+	tc := newTestController(t)
+	tc.cfg.Match.TeardownGrace = time.Minute
+	tc.cfg.Match.TeardownCountdown = 2 * time.Second
+	tc.reconcileOK(t)
+
+	server := newFakeRCON(t)
+	_, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	gamePort, _ := strconv.Atoi(port)
+	key := [2]int{testMatchID, testRoundID}
+	tc.repo.mu.Lock()
+	details := tc.repo.Details[key]
+	details.ServerIP, details.Port = "127.0.0.1", gamePort
+	tc.repo.Details[key] = details
+	tc.repo.mu.Unlock()
+
+	tc.clientset.ClearActions()
+	done := make(chan error, 1)
+	go func() { done <- tc.DeleteServer(context.Background(), testMatchID, testRoundID) }()
+	for len(server.sent()) == 0 {
+		select {
+		case err := <-done:
+			t.Fatalf("deleted without counting down: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	// The reconcile loop can still take the round's lock during the countdown.
+	if n := tc.leaseCreates(); n > 0 {
+		t.Errorf("took the round lock %d times before the countdown ended", n)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("delete server: %v", err)
+	}
+	if _, ok := tc.details(); ok {
+		t.Error("match details were kept after the delete")
+	}
+}
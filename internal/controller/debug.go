@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+// DebugState is the controller's view of the world after the most recent tick.
+// It deliberately carries no passwords, RCON secrets or tokens.
+type DebugState struct {
+	GeneratedAt     time.Time     `json:"generatedAt"`
+	Paused          bool          `json:"paused"`
+	Matches         []MatchStatus `json:"matches"`
+	WaitingForPorts []ServerRef   `json:"waitingForPorts,omitempty"`
+	// WaitingForCapacity lists rounds held back by MAX_CONCURRENT_SERVERS.
+	WaitingForCapacity []ServerRef `json:"waitingForCapacity,omitempty"`
+	// LastErrors lists matches whose latest reconcile failed, with how long they
+	// have been failing.
+	LastErrors []MatchError `json:"lastErrors,omitempty"`
+}
+
+// MatchStatus records what the last tick decided for one match.
+type MatchStatus struct {
+	MatchID       int           `json:"matchId"`
+	Status        int           `json:"status"`
+	ManualNotDone bool          `json:"manualNotDone"`
+	Division      string        `json:"division,omitempty"`
+	Skipped       string        `json:"skipped,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	Reason        string        `json:"reason,omitempty"` // FailureReason of Error
+	Rounds        []RoundStatus `json:"rounds,omitempty"`
+}
+
+// RoundStatus records the reconcile decision and advertised ports for one round.
+type RoundStatus struct {
+	RoundID      int    `json:"roundId"`
+	HasOutcome   bool   `json:"hasOutcome"`
+	HomeReady    bool   `json:"homeReady"`
+	AwayReady    bool   `json:"awayReady"`
+	NeedsServer  bool   `json:"needsServer"`
+	Unreachable  bool   `json:"unreachable,omitempty"`
+	DesiredMap   string `json:"desiredMap,omitempty"`
+	MapOverride  bool   `json:"mapOverride,omitempty"`
+	ActualMap    string `json:"actualMap,omitempty"`
+	ServerIP     string `json:"serverIp,omitempty"`
+	GamePort     int    `json:"gamePort,omitempty"`
+	SourceTVPort int    `json:"sourceTvPort,omitempty"`
+	ClientPort   int    `json:"clientPort,omitempty"`
+	SteamPort    int    `json:"steamPort,omitempty"`
+	Error        string `json:"error,omitempty"`
+	Reason       string `json:"reason,omitempty"` // FailureReason of Error
+}
+
+func newRoundStatus(round database.MatchRound, details *database.MatchDetails) RoundStatus {
+	status := RoundStatus{
+		RoundID:    round.ID,
+		HasOutcome: round.HasOutcome,
+		HomeReady:  round.HomeReady,
+		AwayReady:  round.AwayReady,
+	}
+	if details != nil {
+		status.ServerIP = details.ServerIP
+		status.GamePort = details.Port
+		status.SourceTVPort = details.SourceTVPort
+		status.ClientPort = details.ClientPort
+		status.SteamPort = details.SteamPort
+	}
+	return status
+}
+
+func (c *Controller) setDebugState(state DebugState) {
+	c.debugMu.Lock()
+	defer c.debugMu.Unlock()
+	c.debugState = state
+}
+
+// DebugState returns the snapshot recorded by the most recent tick.
+func (c *Controller) DebugState() DebugState {
+	c.debugMu.Lock()
+	defer c.debugMu.Unlock()
+	return c.debugState
+}
+
+// Handler serves the controller's HTTP endpoints: GET /debug/state, GET /readyz,
+// which fails while the database does not answer a health check, and, when an
+// authentication method is configured, POST /reconcile. /readyz is never gated
+// so kubelet probes keep working.
+func (c *Controller) Handler() http.Handler {
+	mux := http.NewServeMux()
+	if c.cfg.HTTP.AuthEnabled() {
+		mux.HandleFunc("POST /reconcile", c.requireAuth(c.handleReconcile))
+	}
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := c.repo.HealthCheck(r.Context()); err != nil {
+			http.Error(w, "database unavailable: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /debug/state", c.readOnly(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(c.DebugState()); err != nil {
+			klog.Errorf("encode debug state: %v", err)
+		}
+	}))
+	return mux
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// NodeServers lists the managed servers with a pod on node. The node must be
+// cordoned, otherwise drained servers could be scheduled straight back onto it.
+func (c *Controller) NodeServers(ctx context.Context, nodeName string) ([]ServerRef, error) {
+	node, err := c.clientset.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get node %s: %w", nodeName, err)
+	}
+	if !node.Spec.Unschedulable {
+		return nil, fmt.Errorf("node %s is schedulable; cordon it first (kubectl cordon %s) so its servers move elsewhere", nodeName, nodeName)
+	}
+
+	pods, err := c.clientset.CoreV1().Pods(c.cfg.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "spec.nodeName=" + nodeName,
+		LabelSelector: "app.kubernetes.io/instance",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list pods on %s: %w", nodeName, err)
+	}
+
+	seen := map[ServerRef]bool{}
+	var refs []ServerRef
+	for _, pod := range pods.Items {
+		matchID, roundID, ok := c.parseReleaseName(pod.Labels["app.kubernetes.io/instance"])
+		if !ok {
+			continue
+		}
+		ref := ServerRef{MatchID: matchID, RoundID: roundID}
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].MatchID != refs[j].MatchID {
+			return refs[i].MatchID < refs[j].MatchID
+		}
+		return refs[i].RoundID < refs[j].RoundID
+	})
+	return refs, nil
+}
+
+// DrainServer moves a server off a cordoned node before maintenance, telling the
+// teams first. By default only its pods on the node are deleted: the workload
+// starts a replacement elsewhere on the same ports and password, and since its
+// match details are cleared the next reconcile advertises the new address as it
+// would for a new server. With teardown the whole server is deleted instead and
+// recreated from scratch if the round still needs one.
+func (c *Controller) DrainServer(ctx context.Context, ref ServerRef, nodeName string, teardown bool) error {
+	if teardown {
+		c.notifyDrain(ctx, ref, "is shutting down for node maintenance")
+		return c.DeleteServer(ctx, ref.MatchID, ref.RoundID)
+	}
+
+	releaseName := c.releaseName(ref.MatchID, ref.RoundID)
+	unlock, err := c.lockRound(ctx, releaseName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	c.notifyDrain(ctx, ref, "is moving to another node for maintenance; its new address follows once it is up")
+	if err := c.repo.DeleteMatchDetails(ctx, ref.MatchID, ref.RoundID); err != nil {
+		return fmt.Errorf("clear match details: %w", err)
+	}
+
+	pods, err := c.clientset.CoreV1().Pods(c.cfg.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "spec.nodeName=" + nodeName,
+		LabelSelector: "app.kubernetes.io/instance=" + releaseName,
+	})
+	if err != nil {
+		return fmt.Errorf("list pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if err := c.clientset.CoreV1().Pods(c.cfg.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("delete pod %s: %w", pod.Name, err)
+		}
+		klog.Infof("deleted pod %s of match %d round %d from node %s", pod.Name, ref.MatchID, ref.RoundID, nodeName)
+	}
+	return nil
+}
+
+// notifyDrain tells both teams of a match what is happening to their server. A
+// failed notification is logged rather than blocking the maintenance.
+func (c *Controller) notifyDrain(ctx context.Context, ref ServerRef, what string) {
+	if !c.cfg.Notifications.Enabled {
+		return
+	}
+	match, err := c.repo.FetchMatchByID(ctx, ref.MatchID)
+	if err != nil {
+		klog.Warningf("not notifying teams of match %d about the drain: %v", ref.MatchID, err)
+		return
+	}
+	message := fmt.Sprintf("The server for Match %d Round %d %s", ref.MatchID, ref.RoundID, what)
+	link := fmt.Sprintf(c.cfg.Notifications.LinkFormat, ref.MatchID)
+	err = c.repo.WithTx(ctx, func(tx *sql.Tx) error {
+		return c.repo.SendNotificationsToTeamsTx(ctx, tx, match.RosterHomeID, match.RosterAwayID, message, link)
+	})
+	if err != nil {
+		klog.Warningf("failed to notify teams of match %d about the drain: %v", ref.MatchID, err)
+	}
+}
+
+// DrainNode drains every managed server on a cordoned node in turn.
+func (c *Controller) DrainNode(ctx context.Context, nodeName string, refs []ServerRef, teardown bool) error {
+	failed := 0
+	for _, ref := range refs {
+		if err := c.DrainServer(ctx, ref, nodeName, teardown); err != nil {
+			klog.Errorf("failed to drain match %d round %d: %v", ref.MatchID, ref.RoundID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d servers failed to drain", failed, len(refs))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/UDL-TF/TourneyController/internal/chart"
+	"github.com/UDL-TF/TourneyController/internal/database"
+	"github.com/UDL-TF/TourneyController/internal/ports"
+	"github.com/UDL-TF/TourneyController/internal/steam"
+)
+
+// Reconcile errors wrap one of these when their cause is known, so callers can
+// tell failures apart with errors.Is or FailureReason.
+var (
+	// ErrPortsExhausted means a range had no free NodePort. It clears once other
+	// servers are torn down, so the round is retried on the next tick.
+	ErrPortsExhausted = ports.ErrPortsExhausted
+	// ErrSteamUnavailable means the Steam Web API could not be reached or failed
+	// on its side.
+	ErrSteamUnavailable = steam.ErrUnavailable
+	// ErrChartRender means the server chart did not render with the round's
+	// values. Retrying does not help until the chart or the configuration changes.
+	ErrChartRender = chart.ErrRender
+	// ErrDatabaseUnavailable marks failures to query matches, which trip the poll
+	// backoff.
+	ErrDatabaseUnavailable = errors.New("database unavailable")
+	// ErrServerUnreachable marks a running server that did not answer the A2S probe.
+	ErrServerUnreachable = errors.New("server unreachable")
+	// ErrCapacityReached means MAX_CONCURRENT_SERVERS servers are already running,
+	// so a new one waits for a teardown.
+	ErrCapacityReached = errors.New("server capacity reached")
+	// ErrRoundLocked means another reconcile or delete held the round's lock for
+	// longer than the wait allows.
+	ErrRoundLocked = errors.New("round locked")
+)
+
+// FailureReason classifies err into a short, stable label for logs, the debug
+// state and metrics. Unclassified errors are "other".
+func FailureReason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrPortsExhausted):
+		return "ports_exhausted"
+	case errors.Is(err, ErrSteamUnavailable):
+		return "steam_unavailable"
+	case errors.Is(err, ErrChartRender):
+		return "chart_render"
+	case errors.Is(err, ErrDatabaseUnavailable):
+		return "database_unavailable"
+	case errors.Is(err, database.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrServerUnreachable):
+		return "server_unreachable"
+	case errors.Is(err, ErrCapacityReached):
+		return "capacity_reached"
+	case errors.Is(err, ErrRoundLocked):
+		return "locked"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// eventSource names the controller as the reporter of its Events.
+const eventSource = "tourney-controller"
+
+// recordEvent publishes a Kubernetes Event on the server namespace, so conditions
+// such as port exhaustion show up in `kubectl get events` and in alerting built on
+// events. Failures are only logged; events are best effort.
+func (c *Controller) recordEvent(ctx context.Context, eventType, reason, message string) {
+	now := metav1.NewTime(time.Now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", c.cfg.Namespace, now.UnixNano()),
+			Namespace: c.cfg.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+			Name:       c.cfg.Namespace,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: eventSource},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := c.clientset.CoreV1().Events(c.cfg.Namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		klog.V(1).Infof("failed to record %s event: %v", reason, err)
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// idledOutLabel marks the ConfigMap recording that a round was torn down for
+	// idleness, so a restarted or newly elected controller does not recreate it.
+	idledOutLabel = "udl.tf/idled-out"
+	// idledOutAnnotation records when the round was torn down, in RFC 3339.
+	idledOutAnnotation = "udl.tf/idled-out-at"
+)
+
+// idledOutName is the marker ConfigMap's name. It has no release labels, so
+// sweeping the release by label leaves it in place.
+func (c *Controller) idledOutName(ref ServerRef) string {
+	return c.releaseName(ref.MatchID, ref.RoundID) + "-idled-out"
+}
+
+// loadIdledOut fills idledOut from the marker ConfigMaps on the first call. A
+// failed list is retried on the next call.
+func (c *Controller) loadIdledOut(ctx context.Context) {
+	if c.idledOutLoaded {
+		return
+	}
+	list, err := c.clientset.CoreV1().ConfigMaps(c.cfg.Namespace).List(ctx, metav1.ListOptions{LabelSelector: idledOutLabel + "=true"})
+	if err != nil {
+		klog.Warningf("failed to load idled-out rounds, idle teardowns from before this start may be recreated: %v", err)
+		return
+	}
+	for _, marker := range list.Items {
+		matchID, matchErr := strconv.Atoi(marker.Labels["udl.tf/match-id"])
+		roundID, roundErr := strconv.Atoi(marker.Labels["udl.tf/round-id"])
+		if matchErr != nil || roundErr != nil {
+			klog.Warningf("idled-out marker %s has no match or round ID, ignoring it", marker.Name)
+			continue
+		}
+		c.idledOut[ServerRef{MatchID: matchID, RoundID: roundID}] = true
+	}
+	c.idledOutLoaded = true
+	klog.V(1).Infof("loaded %d idled-out rounds", len(c.idledOut))
+}
+
+// markIdledOut records that ref was torn down for idleness. The marker is
+// written before the in-memory entry, so a failed write is retried on the next
+// tick rather than lost at the next restart.
+func (c *Controller) markIdledOut(ctx context.Context, ref ServerRef) error {
+	marker := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.idledOutName(ref),
+			Namespace: c.cfg.Namespace,
+			Labels: map[string]string{
+				"udl.tf/match-id": strconv.Itoa(ref.MatchID),
+				"udl.tf/round-id": strconv.Itoa(ref.RoundID),
+				idledOutLabel:     "true",
+			},
+			Annotations: map[string]string{
+				idledOutAnnotation: time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+	}
+	for k, v := range c.cfg.CommonLabels {
+		if _, ok := marker.Labels[k]; !ok {
+			marker.Labels[k] = v
+		}
+	}
+	if _, err := c.clientset.CoreV1().ConfigMaps(c.cfg.Namespace).Create(ctx, marker, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("create idled-out marker %s: %w", marker.Name, err)
+	}
+	c.idledOut[ref] = true
+	return nil
+}
+
+// clearIdledOut forgets that ref was torn down for idleness once its round has
+// an outcome. A failed delete keeps the entry so it is retried next tick.
+func (c *Controller) clearIdledOut(ctx context.Context, ref ServerRef) {
+	if !c.idledOut[ref] {
+		return
+	}
+	err := c.clientset.CoreV1().ConfigMaps(c.cfg.Namespace).Delete(ctx, c.idledOutName(ref), metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		klog.Warningf("failed to delete idled-out marker for match %d round %d: %v", ref.MatchID, ref.RoundID, err)
+		return
+	}
+	delete(c.idledOut, ref)
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// MatchError is the last reconcile error of a match that has not reconciled
+// cleanly since.
+type MatchError struct {
+	MatchID int       `json:"matchId"`
+	Error   string    `json:"error"`
+	Reason  string    `json:"reason,omitempty"` // FailureReason of Error
+	Since   time.Time `json:"since"`            // First failure of the current streak
+	At      time.Time `json:"at"`               // Latest failure
+	Count   int       `json:"count"`            // Consecutive failed reconciles
+}
+
+// recordReconcileResult remembers a match's reconcile error, or forgets it after
+// a clean reconcile, and with MATCH_RECONCILE_ERRORS mirrors it into the match's
+// reconcile_error column. The column is only written when its value changes.
+func (c *Controller) recordReconcileResult(ctx context.Context, matchID int, err error) {
+	message := ""
+	if err != nil {
+		now := time.Now()
+		last, ok := c.lastErrors[matchID]
+		if !ok {
+			last = MatchError{MatchID: matchID, Since: now}
+		}
+		last.Error = err.Error()
+		last.Reason = FailureReason(err)
+		last.At = now
+		last.Count++
+		c.lastErrors[matchID] = last
+		message = last.Error
+	} else {
+		delete(c.lastErrors, matchID)
+	}
+	c.storeReconcileError(ctx, matchID, message)
+}
+
+// forgetReconcileErrors drops the errors of matches no longer fetched, e.g.
+// completed ones, so they do not linger on the debug endpoint or the site.
+func (c *Controller) forgetReconcileErrors(ctx context.Context, fetched map[int]bool) {
+	for matchID := range c.lastErrors {
+		if !fetched[matchID] {
+			delete(c.lastErrors, matchID)
+		}
+	}
+	for matchID, stored := range c.storedErrors {
+		if fetched[matchID] {
+			continue
+		}
+		if stored != "" {
+			c.storeReconcileError(ctx, matchID, "")
+		}
+		delete(c.storedErrors, matchID)
+	}
+}
+
+// storeReconcileError writes message, empty to clear it, to the match's
+// reconcile_error column unless it already holds it. A failed write is retried
+// on the next reconcile.
+func (c *Controller) storeReconcileError(ctx context.Context, matchID int, message string) {
+	if !c.cfg.Match.RecordErrors {
+		return
+	}
+	if stored, ok := c.storedErrors[matchID]; ok && stored == message {
+		return
+	}
+	if err := c.repo.SetReconcileError(ctx, matchID, message); err != nil {
+		klog.Warningf("failed to store reconcile error for match %d: %v", matchID, err)
+		delete(c.storedErrors, matchID)
+		return
+	}
+	c.storedErrors[matchID] = message
+}
+
+// reconcileErrors lists the remembered errors by match ID for the debug state.
+func (c *Controller) reconcileErrors() []MatchError {
+	errs := make([]MatchError, 0, len(c.lastErrors))
+	for _, last := range c.lastErrors {
+		errs = append(errs, last)
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].MatchID < errs[j].MatchID })
+	return errs
+}
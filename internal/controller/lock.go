@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// lockDuration is how long a round lock is honored without being renewed.
+	// Holders renew it every lockRenew, so only a crashed holder's lock expires.
+	lockDuration = 2 * time.Minute
+	// lockRenew is how often a held lock is renewed.
+	lockRenew = lockDuration / 4
+	// lockWait bounds how long a caller waits for another holder to finish.
+	lockWait = time.Minute
+	// lockPoll is the delay between attempts to take a held lock.
+	lockPoll = 2 * time.Second
+)
+
+// lockHolder identifies this process in lock leases. The pid tells apart a
+// delete command exec'd into the controller's own pod.
+var lockHolder = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}()
+
+// lockRound takes the "<release>-lock" Lease so the reconcile loop and the
+// delete command never work on a round's state secret and release at the same
+// time: whoever comes second waits up to lockWait for the first to finish. The
+// lock is renewed until the returned func releases it.
+func (c *Controller) lockRound(ctx context.Context, releaseName string) (func(), error) {
+	leases := c.clientset.CoordinationV1().Leases(c.cfg.Namespace)
+	name := releaseName + "-lock"
+	deadline := time.Now().Add(lockWait)
+	waiting := false
+
+	for {
+		now := metav1.NewMicroTime(time.Now())
+		holder, seconds := lockHolder, int32(lockDuration/time.Second)
+		spec := coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &seconds,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		}
+
+		lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case k8serrors.IsNotFound(err):
+			lease = &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   name,
+					Labels: map[string]string{"udl.tf/release": releaseName},
+				},
+				Spec: spec,
+			}
+			if lease, err = leases.Create(ctx, lease, metav1.CreateOptions{}); err == nil {
+				return c.holdRound(lease), nil
+			}
+			if !k8serrors.IsAlreadyExists(err) {
+				return nil, fmt.Errorf("create lock %s: %w", name, err)
+			}
+		case err != nil:
+			return nil, fmt.Errorf("get lock %s: %w", name, err)
+		case lockExpired(lease):
+			if lease.Spec.HolderIdentity != nil {
+				klog.Warningf("taking over expired lock %s from %s", name, *lease.Spec.HolderIdentity)
+			}
+			lease.Spec = spec
+			if lease, err = leases.Update(ctx, lease, metav1.UpdateOptions{}); err == nil {
+				return c.holdRound(lease), nil
+			}
+			if !k8serrors.IsConflict(err) {
+				return nil, fmt.Errorf("take over lock %s: %w", name, err)
+			}
+		default:
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("%w: %s is held by %s", ErrRoundLocked, name, *lease.Spec.HolderIdentity)
+			}
+			if !waiting {
+				waiting = true
+				klog.Infof("waiting for %s to release %s", *lease.Spec.HolderIdentity, name)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+// withRoundLock runs fn holding the round lock of releaseName.
+func (c *Controller) withRoundLock(ctx context.Context, releaseName string, fn func() error) error {
+	unlock, err := c.lockRound(ctx, releaseName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+// holdRound renews lease every lockRenew until the returned func is called,
+// which stops renewing and releases the lock.
+func (c *Controller) holdRound(lease *coordinationv1.Lease) func() {
+	stop := make(chan struct{})
+	released := make(chan *coordinationv1.Lease)
+	go func() {
+		ticker := time.NewTicker(lockRenew)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				released <- lease
+				return
+			case <-ticker.C:
+				renewed, err := c.renewRound(lease)
+				if err != nil {
+					klog.Warningf("failed to renew lock %s: %v", lease.Name, err)
+					continue
+				}
+				lease = renewed
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		c.unlockRound(<-released)
+	}
+}
+
+// renewRound moves lease's renew time to now. It fails with a conflict once
+// somebody else has taken the lease over.
+func (c *Controller) renewRound(lease *coordinationv1.Lease) (*coordinationv1.Lease, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	renewed := lease.DeepCopy()
+	now := metav1.NewMicroTime(time.Now())
+	renewed.Spec.RenewTime = &now
+	return c.clientset.CoordinationV1().Leases(c.cfg.Namespace).Update(ctx, renewed, metav1.UpdateOptions{})
+}
+
+// unlockRound deletes lease, provided nobody has taken it over since. It runs
+// on its own context so a cancelled reconcile still unlocks.
+func (c *Controller) unlockRound(lease *coordinationv1.Lease) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := c.clientset.CoordinationV1().Leases(c.cfg.Namespace).Delete(ctx, lease.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+	if err != nil && !k8serrors.IsNotFound(err) && !k8serrors.IsConflict(err) {
+		klog.Warningf("failed to release lock %s: %v", lease.Name, err)
+	}
+}
+
+// lockExpired reports whether a lease's holder stopped honoring it: it has no
+// holder or was not renewed within its duration.
+func lockExpired(lease *coordinationv1.Lease) bool {
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || *spec.HolderIdentity == "" || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return time.Since(spec.RenewTime.Time) > time.Duration(*spec.LeaseDurationSeconds)*time.Second
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+// leaseCreates counts the Leases created since the clientset's actions were cleared.
+func (tc *testController) leaseCreates() int {
+	n := 0
+	for _, action := range tc.clientset.Actions() {
+		if action.Matches("create", "leases") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestReconcileLocksOnlyToChangeARound(t *testing.T) {
+	tc := newTestController(t)
+	tc.reconcileOK(t)
+	if tc.leaseCreates() == 0 {
+		t.Fatal("creating the server did not take the round lock")
+	}
+
+	tc.clientset.ClearActions()
+	tc.reconcileOK(t)
+	if n := tc.leaseCreates(); n > 0 {
+		t.Errorf("a tick with nothing to change took the round lock %d times", n)
+	}
+	for _, action := range tc.clientset.Actions() {
+		if verb := action.GetVerb(); verb != "get" && verb != "list" {
+			t.Errorf("a tick with nothing to change wrote: %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+
+	tc.clientset.ClearActions()
+	tc.setRound(func(round *database.MatchRound) { round.HasOutcome = true })
+	tc.reconcileOK(t)
+	if tc.leaseCreates() == 0 {
+		t.Error("tearing down the server did not take the round lock")
+	}
+}
+
+func TestRoundLockIsRenewedAndReleased(t *testing.T) {
+	tc := newTestController(t)
+	ctx := context.Background()
+	releaseName := tc.releaseName(testMatchID, testRoundID)
+	leases := tc.clientset.CoordinationV1().Leases(tc.cfg.Namespace)
+
+	unlock, err := tc.lockRound(ctx, releaseName)
+	if err != nil {
+		t.Fatalf("lock round: %v", err)
+	}
+	lease, err := leases.Get(ctx, releaseName+"-lock", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get lease: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	renewed, err := tc.renewRound(lease)
+	if err != nil {
+		t.Fatalf("renew lease: %v", err)
+	}
+	if !renewed.Spec.RenewTime.After(lease.Spec.RenewTime.Time) {
+		t.Errorf("renew time %v did not move past %v", renewed.Spec.RenewTime, lease.Spec.RenewTime)
+	}
+
+	unlock()
+	if _, err := leases.Get(ctx, releaseName+"-lock", metav1.GetOptions{}); err == nil {
+		t.Error("lease was kept after unlocking")
+	}
+}
@@ -0,0 +1,408 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+// memoryRepository is an in-memory Repository, so reconcile decisions can be
+// exercised without Postgres. Seed it through its exported maps before use; the
+// *sql.Tx passed to the Tx methods is ignored and may be nil.
+type memoryRepository struct {
+	mu sync.Mutex
+
+	Matches     map[int]database.Match
+	Rounds      map[int][]database.MatchRound // Keyed by match ID
+	Divisions   map[int]database.Division     // Keyed by roster ID
+	Leagues     map[string]database.League    // Keyed by division ID
+	SteamIDs    map[int][]string              // Keyed by roster ID
+	Maps        map[int]string
+	MapOverride map[[2]int]string                // Keyed by match and round ID
+	Values      map[int]string                   // Raw values_override JSON, keyed by match ID
+	UpdatedAt   map[[2]int]time.Time             // Round update times, keyed by match and round ID
+	Details     map[[2]int]database.MatchDetails // Keyed by match and round ID
+	Artifacts   map[[2]int]database.MatchArtifacts
+	PlayerCount map[[2]int][2]int // Players and max players
+	ActualMaps  map[[2]int]string
+	ServerIPv6  map[[2]int]string
+	SourceTV    map[[2]int][2]string // Password and connect string
+	Status      map[[2]int]string    // Server status, keyed by match and round ID
+	Errors      map[int]string       // Reconcile error by match ID
+
+	// Notifications records every SendNotificationsToTeamsTx call in order.
+	Notifications []notification
+}
+
+// notification is a message sent to both rosters of a match.
+type notification struct {
+	HomeRosterID int
+	AwayRosterID int
+	Message      string
+	Link         string
+}
+
+// newMemoryRepository returns an empty memoryRepository.
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		Matches:     map[int]database.Match{},
+		Rounds:      map[int][]database.MatchRound{},
+		Divisions:   map[int]database.Division{},
+		Leagues:     map[string]database.League{},
+		SteamIDs:    map[int][]string{},
+		Maps:        map[int]string{},
+		MapOverride: map[[2]int]string{},
+		Values:      map[int]string{},
+		UpdatedAt:   map[[2]int]time.Time{},
+		Details:     map[[2]int]database.MatchDetails{},
+		Artifacts:   map[[2]int]database.MatchArtifacts{},
+		PlayerCount: map[[2]int][2]int{},
+		ActualMaps:  map[[2]int]string{},
+		ServerIPv6:  map[[2]int]string{},
+		SourceTV:    map[[2]int][2]string{},
+		Status:      map[[2]int]string{},
+		Errors:      map[int]string{},
+	}
+}
+
+// QueryMatches filters by status and allowlist and orders by ID. Schedule-based
+// options are ignored because memoryRepository has no scheduled_at.
+func (m *memoryRepository) QueryMatches(ctx context.Context, q database.MatchQuery) ([]database.Match, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matches []database.Match
+	for _, match := range m.Matches {
+		if !containsInt(q.Statuses, match.Status) {
+			continue
+		}
+		if len(q.Allowlist) > 0 && !containsInt(q.Allowlist, match.ID) {
+			continue
+		}
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+	if q.Limit > 0 && len(matches) > q.Limit {
+		matches = matches[:q.Limit]
+	}
+	return matches, nil
+}
+
+// FetchMatchByID returns the seeded match or database.ErrNotFound.
+func (m *memoryRepository) FetchMatchByID(ctx context.Context, matchID int) (*database.Match, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	match, ok := m.Matches[matchID]
+	if !ok {
+		return nil, fmt.Errorf("match with ID %d: %w", matchID, database.ErrNotFound)
+	}
+	return &match, nil
+}
+
+// FetchMatchRounds returns the seeded rounds of a match.
+func (m *memoryRepository) FetchMatchRounds(ctx context.Context, matchID int) ([]database.MatchRound, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]database.MatchRound(nil), m.Rounds[matchID]...), nil
+}
+
+// FetchMatchRoundByID returns the seeded round or database.ErrNotFound.
+func (m *memoryRepository) FetchMatchRoundByID(ctx context.Context, matchID, roundID int) (*database.MatchRound, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, round := range m.Rounds[matchID] {
+		if round.ID == roundID {
+			return &round, nil
+		}
+	}
+	return nil, fmt.Errorf("round %d for match %d: %w", roundID, matchID, database.ErrNotFound)
+}
+
+// FetchDivision returns the division of a roster.
+func (m *memoryRepository) FetchDivision(ctx context.Context, rosterID int) (*database.Division, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	division, ok := m.Divisions[rosterID]
+	if !ok {
+		return nil, fmt.Errorf("fetch division for roster %d: %w", rosterID, sql.ErrNoRows)
+	}
+	return &division, nil
+}
+
+// FetchDivisionsForRosters returns the divisions of the rosters that exist.
+func (m *memoryRepository) FetchDivisionsForRosters(ctx context.Context, rosterIDs []int) (map[int]*database.Division, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[int]*database.Division, len(rosterIDs))
+	for _, id := range rosterIDs {
+		if division, ok := m.Divisions[id]; ok {
+			out[id] = &division
+		}
+	}
+	return out, nil
+}
+
+// FetchLeague returns the league of a division.
+func (m *memoryRepository) FetchLeague(ctx context.Context, divisionID string) (*database.League, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	league, ok := m.Leagues[divisionID]
+	if !ok {
+		return nil, fmt.Errorf("fetch league_id for division %s: %w", divisionID, sql.ErrNoRows)
+	}
+	return &league, nil
+}
+
+// FetchTeamSteamIDs returns the Steam IDs of a roster's players.
+func (m *memoryRepository) FetchTeamSteamIDs(ctx context.Context, rosterID int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.SteamIDs[rosterID]...), nil
+}
+
+// FetchTeamSteamIDsForRosters returns Steam IDs keyed by roster ID.
+func (m *memoryRepository) FetchTeamSteamIDsForRosters(ctx context.Context, rosterIDs []int) (map[int][]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[int][]string, len(rosterIDs))
+	for _, id := range rosterIDs {
+		if ids, ok := m.SteamIDs[id]; ok {
+			out[id] = append([]string(nil), ids...)
+		}
+	}
+	return out, nil
+}
+
+// FetchMapName returns the name of a map.
+func (m *memoryRepository) FetchMapName(ctx context.Context, mapID int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, ok := m.Maps[mapID]
+	if !ok {
+		return "", fmt.Errorf("fetch map %d: %w", mapID, sql.ErrNoRows)
+	}
+	return name, nil
+}
+
+// FetchMapNames returns map names keyed by ID. Unknown IDs are absent from the result.
+func (m *memoryRepository) FetchMapNames(ctx context.Context, mapIDs []int) (map[int]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[int]string, len(mapIDs))
+	for _, id := range mapIDs {
+		if name, ok := m.Maps[id]; ok {
+			out[id] = name
+		}
+	}
+	return out, nil
+}
+
+// FetchRoundUpdatedAt returns the seeded update time of a round or database.ErrNotFound.
+func (m *memoryRepository) FetchRoundUpdatedAt(ctx context.Context, matchID, roundID int) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	updatedAt, ok := m.UpdatedAt[[2]int{matchID, roundID}]
+	if !ok {
+		return time.Time{}, fmt.Errorf("round %d for match %d: %w", roundID, matchID, database.ErrNotFound)
+	}
+	return updatedAt, nil
+}
+
+// FetchMapOverrides returns the seeded overrides of a match's rounds.
+func (m *memoryRepository) FetchMapOverrides(ctx context.Context, matchID int) (map[int]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := map[int]string{}
+	for key, name := range m.MapOverride {
+		if key[0] == matchID && name != "" {
+			out[key[1]] = name
+		}
+	}
+	return out, nil
+}
+
+// FetchValuesOverride returns the seeded values override of a match.
+func (m *memoryRepository) FetchValuesOverride(ctx context.Context, matchID int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.Matches[matchID]; !ok {
+		return "", fmt.Errorf("match %d: %w", matchID, database.ErrNotFound)
+	}
+	return m.Values[matchID], nil
+}
+
+// FetchMatchDetails returns the stored details, or nil when there are none.
+func (m *memoryRepository) FetchMatchDetails(ctx context.Context, matchID, roundID int) (*database.MatchDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	details, ok := m.Details[[2]int{matchID, roundID}]
+	if !ok {
+		return nil, nil
+	}
+	return &details, nil
+}
+
+// FetchAllMatchDetails returns every stored details row.
+func (m *memoryRepository) FetchAllMatchDetails(ctx context.Context) ([]database.MatchDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	all := make([]database.MatchDetails, 0, len(m.Details))
+	for _, details := range m.Details {
+		all = append(all, details)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].MatchID != all[j].MatchID {
+			return all[i].MatchID < all[j].MatchID
+		}
+		return all[i].RoundID < all[j].RoundID
+	})
+	return all, nil
+}
+
+// UpsertMatchDetailsTx stores details; tx is ignored.
+func (m *memoryRepository) UpsertMatchDetailsTx(ctx context.Context, tx *sql.Tx, details database.MatchDetails) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Details[[2]int{details.MatchID, details.RoundID}] = details
+	return nil
+}
+
+// DeleteMatchDetails removes stored details.
+func (m *memoryRepository) DeleteMatchDetails(ctx context.Context, matchID, roundID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Details, [2]int{matchID, roundID})
+	delete(m.Status, [2]int{matchID, roundID})
+	return nil
+}
+
+// RecordMatchArtifacts stores where a round's artifacts were archived.
+func (m *memoryRepository) RecordMatchArtifacts(ctx context.Context, artifacts database.MatchArtifacts) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Artifacts[[2]int{artifacts.MatchID, artifacts.RoundID}] = artifacts
+	return nil
+}
+
+// UpdatePlayerCounts stores a server's live player count.
+func (m *memoryRepository) UpdatePlayerCounts(ctx context.Context, matchID, roundID, players, maxPlayers int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.PlayerCount[[2]int{matchID, roundID}] = [2]int{players, maxPlayers}
+	return nil
+}
+
+// UpdateActualMap stores the map a server is really on.
+func (m *memoryRepository) UpdateActualMap(ctx context.Context, matchID, roundID int, mapName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ActualMaps[[2]int{matchID, roundID}] = mapName
+	return nil
+}
+
+// SetReconcileError stores a match's reconcile error, deleting it when empty.
+func (m *memoryRepository) SetReconcileError(ctx context.Context, matchID int, message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if message == "" {
+		delete(m.Errors, matchID)
+	} else {
+		m.Errors[matchID] = message
+	}
+	return nil
+}
+
+// UpdateServerIPv6Tx stores a dual-stack server's IPv6 address; tx is ignored.
+func (m *memoryRepository) UpdateServerIPv6Tx(ctx context.Context, tx *sql.Tx, matchID, roundID int, addr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ServerIPv6[[2]int{matchID, roundID}] = addr
+	return nil
+}
+
+// UpdateSourceTVTx stores a server's SourceTV password and connect string; tx is ignored.
+func (m *memoryRepository) UpdateSourceTVTx(ctx context.Context, tx *sql.Tx, matchID, roundID int, password, connect string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.SourceTV[[2]int{matchID, roundID}] = [2]string{password, connect}
+	return nil
+}
+
+// UpdateServerStatusTx stores a server's status; tx is ignored.
+func (m *memoryRepository) UpdateServerStatusTx(ctx context.Context, tx *sql.Tx, matchID, roundID int, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Status[[2]int{matchID, roundID}] = status
+	return nil
+}
+
+// FetchServerStatus returns a server's stored status, or "" when it has none.
+func (m *memoryRepository) FetchServerStatus(ctx context.Context, matchID, roundID int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Status[[2]int{matchID, roundID}], nil
+}
+
+// SendNotificationsToTeamsTx records the notification; tx is ignored.
+func (m *memoryRepository) SendNotificationsToTeamsTx(ctx context.Context, tx *sql.Tx, homeRosterID, awayRosterID int, message, link string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Notifications = append(m.Notifications, notification{
+		HomeRosterID: homeRosterID,
+		AwayRosterID: awayRosterID,
+		Message:      message,
+		Link:         link,
+	})
+	return nil
+}
+
+// SetNotificationCooldown is a no-op; memoryRepository records every notification.
+func (m *memoryRepository) SetNotificationCooldown(d time.Duration) {}
+
+// WithTx calls fn with a nil transaction. When fn fails, the details and
+// notifications it wrote are rolled back, as Postgres would.
+func (m *memoryRepository) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	m.mu.Lock()
+	details, ipv6, sourceTV, status := maps.Clone(m.Details), maps.Clone(m.ServerIPv6), maps.Clone(m.SourceTV), maps.Clone(m.Status)
+	notifications := len(m.Notifications)
+	m.mu.Unlock()
+
+	if err := fn(nil); err != nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.Details, m.ServerIPv6, m.SourceTV, m.Status = details, ipv6, sourceTV, status
+		m.Notifications = m.Notifications[:notifications]
+		return err
+	}
+	return nil
+}
+
+// CacheStats always reports zero; memoryRepository has no lookup cache.
+func (m *memoryRepository) CacheStats() database.CacheStats {
+	return database.CacheStats{}
+}
+
+// SlowQueries always reports zero.
+func (m *memoryRepository) SlowQueries() uint64 {
+	return 0
+}
+
+// HealthCheck always succeeds.
+func (m *memoryRepository) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+func containsInt(values []int, want int) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
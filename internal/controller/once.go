@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+)
+
+// Exit codes of a single-shot run, so CI and wrapper scripts can tell a clean
+// tick from one that needs attention without scraping logs.
+const (
+	ExitOK             = 0
+	ExitPartialFailure = 2 // Some matches or cleanups failed
+	ExitTotalFailure   = 3 // The match query or every match failed
+)
+
+// RunSummary is the machine-readable outcome of one reconcile.
+type RunSummary struct {
+	Matches   int            `json:"matches"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Skipped   int            `json:"skipped"`
+	Ensured   int            `json:"ensured"`
+	TornDown  int            `json:"tornDown"`
+	Throttled int            `json:"throttled"`       // New servers held back by MAX_CONCURRENT_SERVERS
+	Errors    int            `json:"errors"`          // Every failure in the tick, including cleanups
+	Error     string         `json:"error,omitempty"` // Why the whole tick failed
+	Failures  []MatchFailure `json:"failures,omitempty"`
+}
+
+// MatchFailure is the first error a failed match hit.
+type MatchFailure struct {
+	MatchID int    `json:"matchId"`
+	Error   string `json:"error"`
+	Reason  string `json:"reason"`
+}
+
+// ExitCode maps the summary to ExitOK, ExitPartialFailure or ExitTotalFailure.
+func (s RunSummary) ExitCode() int {
+	switch {
+	case s.Error != "":
+		return ExitTotalFailure
+	case s.Errors > 0:
+		return ExitPartialFailure
+	default:
+		return ExitOK
+	}
+}
+
+// RunOnce reconciles every match a single time and summarizes the outcome.
+func (c *Controller) RunOnce(ctx context.Context) RunSummary {
+	err := c.reconcile(ctx)
+	tick := c.lastTick
+	summary := RunSummary{
+		Matches:   tick.matches,
+		Ensured:   tick.ensured,
+		TornDown:  tick.tornDown,
+		Throttled: tick.throttled,
+		Errors:    tick.errors,
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+
+	// The snapshot is only recorded once the match query succeeded.
+	if err == nil || tick.matches > 0 {
+		for _, status := range c.DebugState().Matches {
+			switch {
+			case status.Error != "":
+				summary.Failed++
+				summary.Failures = append(summary.Failures, MatchFailure{MatchID: status.MatchID, Error: status.Error, Reason: status.Reason})
+			case status.Skipped != "":
+				summary.Skipped++
+			default:
+				summary.Succeeded++
+			}
+		}
+	}
+	return summary
+}
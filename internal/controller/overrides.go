@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/klog/v2"
+)
+
+// withValuesOverride merges a match's league_matches.values_override over values
+// with the highest precedence, so admins can tweak one match's servers (more
+// memory for a grand final, a debug flag) without a redeploy. It returns values
+// unchanged when MATCH_VALUES_OVERRIDES is off or the match has no override; an
+// override that cannot be read or is not a JSON object is logged and ignored
+// rather than failing the round.
+func (c *Controller) withValuesOverride(ctx context.Context, matchID int, values chartutil.Values) chartutil.Values {
+	if !c.cfg.Match.ValuesOverrides {
+		return values
+	}
+	raw, err := c.repo.FetchValuesOverride(ctx, matchID)
+	if err != nil {
+		klog.Warningf("match %d values override lookup failed, using configured values: %v", matchID, err)
+		return values
+	}
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		if _, ok := c.activeOverrides[matchID]; ok {
+			klog.Infof("match %d values override removed", matchID)
+			delete(c.activeOverrides, matchID)
+		}
+		return values
+	}
+
+	var override map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &override); err != nil {
+		klog.Errorf("ignoring match %d values override, it is not a JSON object: %v", matchID, err)
+		return values
+	}
+	if c.activeOverrides[matchID] != raw {
+		klog.Infof("match %d values override active: %s", matchID, raw)
+		c.activeOverrides[matchID] = raw
+	}
+	return chartutil.CoalesceTables(override, values)
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/UDL-TF/TourneyController/internal/config"
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+// prioritizeMatches orders matches by MATCH_PRIORITY while MAX_CONCURRENT_SERVERS
+// is set. Matches are reconciled in order and the cap admits new servers first
+// come, first served, so when it forces a choice the most important matches get
+// servers. The sort is stable: ties keep the waiting-first order of
+// prioritizeWaitingMatches.
+func (c *Controller) prioritizeMatches(ctx context.Context, matches []database.Match, lookups *matchLookups) {
+	if c.cfg.Match.MaxServers <= 0 || len(c.cfg.Match.Priority) == 0 || len(matches) < 2 {
+		return
+	}
+
+	divisionRank := map[int]int{}
+	if c.cfg.Match.PrioritizesBy(config.PriorityDivision) {
+		for _, match := range matches {
+			division, err := c.lookupDivision(ctx, lookups, match.RosterHomeID)
+			if err != nil {
+				klog.V(1).Infof("match %d division lookup failed, ranking it as unlisted: %v", match.ID, err)
+				continue
+			}
+			divisionRank[match.ID] = c.cfg.Match.DivisionPriority[strings.ToLower(division.Name)]
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		for _, criterion := range c.cfg.Match.Priority {
+			switch criterion {
+			case config.PriorityColumn:
+				if a.Priority != b.Priority {
+					return a.Priority > b.Priority
+				}
+			case config.PriorityDivision:
+				if divisionRank[a.ID] != divisionRank[b.ID] {
+					return divisionRank[a.ID] > divisionRank[b.ID]
+				}
+			case config.PriorityScheduled:
+				if a.ScheduledAt.Valid != b.ScheduledAt.Valid {
+					return a.ScheduledAt.Valid
+				}
+				if !a.ScheduledAt.Time.Equal(b.ScheduledAt.Time) {
+					return a.ScheduledAt.Time.Before(b.ScheduledAt.Time)
+				}
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,361 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/UDL-TF/TourneyController/internal/config"
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+var _ Repository = (*memoryRepository)(nil)
+
+// Seeded IDs are all distinct, so a match ID used where a roster ID belongs, or
+// one roster used for the other, finds nothing or the wrong team.
+const (
+	testMatchID      = 7
+	testRoundID      = 70
+	testHomeRosterID = 101
+	testAwayRosterID = 202
+	testDivisionID   = "31"
+	testMapID        = 9
+	testNodeIP       = "203.0.113.10"
+)
+
+// fakeRenderer stands in for the chart: Apply records the values and starts a
+// ready pod for the release, Delete records the release and removes the pod.
+type fakeRenderer struct {
+	clientset kubernetes.Interface
+	namespace string
+
+	mu      sync.Mutex
+	applied map[string]chartutil.Values
+	deleted []string
+}
+
+func (r *fakeRenderer) SetCommonMetadata(labels, annotations map[string]string) {}
+
+func (r *fakeRenderer) SetFieldManager(name string, force bool) {}
+
+func (r *fakeRenderer) Apply(ctx context.Context, releaseName string, overrides chartutil.Values, owner *metav1.OwnerReference) error {
+	r.mu.Lock()
+	r.applied[releaseName] = overrides
+	r.mu.Unlock()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      releaseName + "-0",
+			Namespace: r.namespace,
+			Labels:    map[string]string{"app.kubernetes.io/instance": releaseName},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-a"},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "srcds", Ready: true}},
+		},
+	}
+	if _, err := r.clientset.CoreV1().Pods(r.namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *fakeRenderer) Delete(ctx context.Context, releaseName string, overrides chartutil.Values) error {
+	r.mu.Lock()
+	r.deleted = append(r.deleted, releaseName)
+	r.mu.Unlock()
+
+	err := r.clientset.CoreV1().Pods(r.namespace).Delete(ctx, releaseName+"-0", metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *fakeRenderer) Diff(ctx context.Context, releaseName string, overrides chartutil.Values) (string, error) {
+	return "", nil
+}
+
+func (r *fakeRenderer) Validate(releaseName string, sample chartutil.Values, requiredKinds ...string) error {
+	return nil
+}
+
+// testController is a Controller over an in-memory repository, a fake clientset
+// and a fakeRenderer, configured from the defaults with one match seeded: both
+// teams ready for round testRoundID.
+type testController struct {
+	*Controller
+	repo      *memoryRepository
+	renderer  *fakeRenderer
+	clientset *fake.Clientset
+}
+
+func newTestController(t *testing.T) *testController {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load default config: %v", err)
+	}
+	cfg.Networking.NodeIPOverride = testNodeIP
+	cfg.Notifications.Enabled = true
+
+	repo := newMemoryRepository()
+	repo.Matches[testMatchID] = database.Match{
+		ID:           testMatchID,
+		RosterHomeID: testHomeRosterID,
+		RosterAwayID: testAwayRosterID,
+		WinLimit:     2,
+		Status:       cfg.Match.TargetStatuses[0],
+	}
+	repo.Rounds[testMatchID] = []database.MatchRound{{
+		ID:        testRoundID,
+		MatchID:   testMatchID,
+		MapID:     testMapID,
+		HomeReady: true,
+		AwayReady: true,
+	}}
+	repo.Divisions[testHomeRosterID] = database.Division{ID: testDivisionID, Name: "Premier"}
+	repo.Divisions[testAwayRosterID] = database.Division{ID: testDivisionID, Name: "Premier"}
+	repo.Leagues[testDivisionID] = database.League{ID: 4, Name: "Highlander", MinPlayers: 9, MaxPlayers: 18}
+	repo.SteamIDs[testHomeRosterID] = []string{"STEAM_0:1:1001", "STEAM_0:1:1002"}
+	repo.SteamIDs[testAwayRosterID] = []string{"STEAM_0:1:2001"}
+	repo.Maps[testMapID] = "cp_process_final"
+
+	clientset := fake.NewClientset()
+	renderer := &fakeRenderer{clientset: clientset, namespace: cfg.Namespace, applied: map[string]chartutil.Values{}}
+	ctrl := New(cfg, repo, clientset, map[string]Renderer{"": renderer}, nil)
+	return &testController{Controller: ctrl, repo: repo, renderer: renderer, clientset: clientset}
+}
+
+// setRound replaces the seeded round after applying change to it.
+func (tc *testController) setRound(change func(round *database.MatchRound)) {
+	tc.repo.mu.Lock()
+	defer tc.repo.mu.Unlock()
+	round := tc.repo.Rounds[testMatchID][0]
+	change(&round)
+	tc.repo.Rounds[testMatchID] = []database.MatchRound{round}
+}
+
+func (tc *testController) reconcileOK(t *testing.T) {
+	t.Helper()
+	if err := tc.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+}
+
+func (tc *testController) details() (database.MatchDetails, bool) {
+	tc.repo.mu.Lock()
+	defer tc.repo.mu.Unlock()
+	details, ok := tc.repo.Details[[2]int{testMatchID, testRoundID}]
+	return details, ok
+}
+
+func (tc *testController) stateSecret(t *testing.T) *corev1.Secret {
+	t.Helper()
+	name := tc.secretName(tc.releaseName(testMatchID, testRoundID))
+	secret, err := tc.clientset.CoreV1().Secrets(tc.cfg.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("get state secret: %v", err)
+	}
+	return secret
+}
+
+// envValue returns the value of the named entry in a release's app.env list.
+func envValue(t *testing.T, values chartutil.Values, name string) string {
+	t.Helper()
+	app, _ := values["app"].(map[string]interface{})
+	env, ok := app["env"].([]map[string]interface{})
+	if !ok {
+		t.Fatalf("values have no app.env list: %T", app["env"])
+	}
+	for _, entry := range env {
+		if entry["name"] == name {
+			return fmt.Sprint(entry["value"])
+		}
+	}
+	t.Fatalf("env %s not set", name)
+	return ""
+}
+
+func TestReconcileEnsuresReadyRound(t *testing.T) {
+	tc := newTestController(t)
+	tc.reconcileOK(t)
+
+	releaseName := tc.releaseName(testMatchID, testRoundID)
+	values, ok := tc.renderer.applied[releaseName]
+	if !ok {
+		t.Fatalf("release %s was not applied", releaseName)
+	}
+	if got := envValue(t, values, "SRCDS_STARTMAP"); got != "cp_process_final" {
+		t.Errorf("SRCDS_STARTMAP = %q, want the round's map", got)
+	}
+
+	secret := tc.stateSecret(t)
+	if secret == nil {
+		t.Fatal("state secret was not created")
+	}
+	details, ok := tc.details()
+	if !ok {
+		t.Fatal("match details were not stored")
+	}
+	if details.ServerIP != testNodeIP {
+		t.Errorf("details server IP = %q, want %q", details.ServerIP, testNodeIP)
+	}
+	if details.Password != string(secret.Data[secretKeyPassword]) {
+		t.Errorf("details password %q differs from the state secret's %q", details.Password, secret.Data[secretKeyPassword])
+	}
+	if got := envValue(t, values, "SRCDS_PORT"); got != fmt.Sprint(details.Port) {
+		t.Errorf("SRCDS_PORT = %s, but details advertise port %d", got, details.Port)
+	}
+	if len(tc.repo.Notifications) != 1 {
+		t.Fatalf("sent %d notifications, want 1", len(tc.repo.Notifications))
+	}
+
+	// A second tick keeps the server and does not notify the teams again.
+	tc.reconcileOK(t)
+	if len(tc.renderer.deleted) > 0 {
+		t.Errorf("running server was deleted: %v", tc.renderer.deleted)
+	}
+	if len(tc.repo.Notifications) != 1 {
+		t.Errorf("sent %d notifications after a second tick, want 1", len(tc.repo.Notifications))
+	}
+}
+
+func TestReconcileWaitsForBothTeams(t *testing.T) {
+	tc := newTestController(t)
+	tc.setRound(func(round *database.MatchRound) { round.AwayReady = false })
+	tc.reconcileOK(t)
+
+	if len(tc.renderer.applied) > 0 {
+		t.Errorf("applied %d releases before both teams were ready", len(tc.renderer.applied))
+	}
+	if _, ok := tc.details(); ok {
+		t.Error("match details were stored before both teams were ready")
+	}
+}
+
+func TestReconcileTearsDownFinishedRound(t *testing.T) {
+	tc := newTestController(t)
+	tc.reconcileOK(t)
+	if _, ok := tc.details(); !ok {
+		t.Fatal("match details were not stored")
+	}
+
+	tc.setRound(func(round *database.MatchRound) { round.HasOutcome = true })
+	tc.reconcileOK(t)
+
+	releaseName := tc.releaseName(testMatchID, testRoundID)
+	if len(tc.renderer.deleted) == 0 || tc.renderer.deleted[0] != releaseName {
+		t.Errorf("deleted releases %v, want %s", tc.renderer.deleted, releaseName)
+	}
+	if _, ok := tc.details(); ok {
+		t.Error("match details were kept after teardown")
+	}
+	if tc.stateSecret(t) != nil {
+		t.Error("state secret was kept after teardown")
+	}
+}
+
+func TestReconcileHoldsFinishedRoundForGrace(t *testing.T) {
+	ref := [2]int{testMatchID, testRoundID}
+	for _, tc := range []struct {
+		name     string
+		finished time.Duration // how long ago the outcome was recorded; 0 leaves it unknown
+		kept     bool
+	}{
+		{name: "within grace", finished: time.Minute, kept: true},
+		{name: "past grace", finished: 20 * time.Minute, kept: false},
+		{name: "outcome time unknown", kept: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := newTestController(t)
+			ctrl.cfg.Match.TeardownGrace = 10 * time.Minute
+			ctrl.reconcileOK(t)
+
+			if tc.finished > 0 {
+				ctrl.repo.UpdatedAt[ref] = time.Now().Add(-tc.finished)
+			}
+			ctrl.setRound(func(round *database.MatchRound) { round.HasOutcome = true })
+			ctrl.reconcileOK(t)
+			if _, ok := ctrl.details(); ok != tc.kept {
+				t.Fatalf("server kept = %v, want %v", ok, tc.kept)
+			}
+
+			// Editing the round later does not restart the grace period.
+			ctrl.repo.UpdatedAt[ref] = time.Now()
+			ctrl.reconcileOK(t)
+			if _, ok := ctrl.details(); ok != tc.kept {
+				t.Errorf("after the round was edited, server kept = %v, want %v", ok, tc.kept)
+			}
+		})
+	}
+}
+
+func TestReconcileKeepsManualRoundWithOutcome(t *testing.T) {
+	tc := newTestController(t)
+	tc.reconcileOK(t)
+
+	tc.repo.mu.Lock()
+	match := tc.repo.Matches[testMatchID]
+	match.ManualNotDone = true
+	tc.repo.Matches[testMatchID] = match
+	tc.repo.mu.Unlock()
+	tc.setRound(func(round *database.MatchRound) { round.HasOutcome = true })
+	tc.reconcileOK(t)
+
+	if len(tc.renderer.deleted) > 0 {
+		t.Errorf("server of a manually held match was deleted: %v", tc.renderer.deleted)
+	}
+	if _, ok := tc.details(); !ok {
+		t.Error("match details of a manually held match were deleted")
+	}
+}
+
+func TestMemoryRepositoryWithTxRollsBack(t *testing.T) {
+	repo := newMemoryRepository()
+	ctx := context.Background()
+	err := repo.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := repo.UpsertMatchDetailsTx(ctx, tx, database.MatchDetails{MatchID: 1, RoundID: 2}); err != nil {
+			return err
+		}
+		if err := repo.SendNotificationsToTeamsTx(ctx, tx, 3, 4, "message", "/matches/1"); err != nil {
+			return err
+		}
+		return errors.New("notify failed")
+	})
+	if err == nil {
+		t.Fatal("WithTx swallowed the error")
+	}
+	if len(repo.Details) != 0 || len(repo.Notifications) != 0 {
+		t.Errorf("failed transaction left %d details and %d notifications", len(repo.Details), len(repo.Notifications))
+	}
+}
+
+// TestNewWithoutRepository covers validate, which checks the chart even when the
+// database could not be reached.
+func TestNewWithoutRepository(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load default config: %v", err)
+	}
+	renderer := &fakeRenderer{clientset: fake.NewClientset(), namespace: cfg.Namespace, applied: map[string]chartutil.Values{}}
+	ctrl := New(cfg, nil, fake.NewClientset(), map[string]Renderer{"": renderer}, nil)
+	if err := ctrl.ValidateChart(); err != nil {
+		t.Errorf("validate chart: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// retiredLabel marks a torn-down server's retained state secret. The port
+	// allocator skips such secrets, so their ports are free for new servers.
+	retiredLabel = "udl.tf/retired"
+	// tornDownAnnotation and retainUntilAnnotation record when the server was
+	// torn down and when pruneRetainedSecrets deletes the copy, in RFC 3339.
+	tornDownAnnotation    = "udl.tf/torn-down-at"
+	retainUntilAnnotation = "udl.tf/retain-until"
+)
+
+// retainStateSecret keeps a copy of a release's state secret for
+// RETAIN_SECRETS_AFTER_TEARDOWN before it is deleted, so disputes about a
+// match's ports and passwords can still be investigated. The copy is renamed and
+// loses the release labels: the live secret's name would be mistaken for a
+// running server's state, and the release labels would get it swept with the
+// release. With RETAIN_SECRETS_STRIP_PASSWORDS only ports, map, chart and
+// timestamps are kept.
+func (c *Controller) retainStateSecret(ctx context.Context, releaseName string) error {
+	if c.cfg.Match.RetainSecrets <= 0 {
+		return nil
+	}
+	secrets := c.clientset.CoreV1().Secrets(c.cfg.Namespace)
+	live, err := secrets.Get(ctx, c.secretName(releaseName), metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	now := time.Now().UTC()
+	retained := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-torndown-%d", live.Name, now.Unix()),
+			Namespace: c.cfg.Namespace,
+			Labels: map[string]string{
+				"udl.tf/match-id": live.Labels["udl.tf/match-id"],
+				"udl.tf/round-id": live.Labels["udl.tf/round-id"],
+				retiredLabel:      "true",
+			},
+			Annotations: map[string]string{
+				tornDownAnnotation:    now.Format(time.RFC3339),
+				retainUntilAnnotation: now.Add(c.cfg.Match.RetainSecrets).Format(time.RFC3339),
+			},
+		},
+		Data: map[string][]byte{},
+		Type: corev1.SecretTypeOpaque,
+	}
+	for k, v := range c.cfg.CommonLabels {
+		if _, ok := retained.Labels[k]; !ok {
+			retained.Labels[k] = v
+		}
+	}
+	for key, value := range live.Data {
+		retained.Data[key] = value
+	}
+	if c.cfg.Match.StripRetained {
+		for _, key := range []string{secretKeyPassword, secretKeyRCON, secretKeyLiveRCON, secretKeyNextRCON, secretKeyToken, secretKeyTVPassword} {
+			delete(retained.Data, key)
+		}
+	}
+	if _, err := secrets.Create(ctx, retained, metav1.CreateOptions{}); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("retain state secret %s: %w", live.Name, err)
+	}
+	klog.V(1).Infof("retained state secret of %s as %s until %s", releaseName, retained.Name, retained.Annotations[retainUntilAnnotation])
+	return nil
+}
+
+// pruneRetainedSecrets deletes retained state secrets whose window has passed.
+// Copies without a readable deadline are left for an operator.
+func (c *Controller) pruneRetainedSecrets(ctx context.Context) error {
+	secrets := c.clientset.CoreV1().Secrets(c.cfg.Namespace)
+	list, err := secrets.List(ctx, metav1.ListOptions{LabelSelector: retiredLabel + "=true"})
+	if err != nil {
+		return fmt.Errorf("list retained secrets: %w", err)
+	}
+	now := time.Now()
+	for _, secret := range list.Items {
+		until, err := time.Parse(time.RFC3339, secret.Annotations[retainUntilAnnotation])
+		if err != nil || now.Before(until) {
+			continue
+		}
+		if err := secrets.Delete(ctx, secret.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			klog.Warningf("failed to delete retained secret %s: %v", secret.Name, err)
+			continue
+		}
+		klog.V(1).Infof("deleted retained secret %s, retention ended %s", secret.Name, until.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+func TestRetainedSecretsPrunedAfterRetentionIsOff(t *testing.T) {
+	tc := newTestController(t)
+	tc.cfg.Match.RetainSecrets = time.Hour
+	tc.cfg.Match.StripRetained = false
+	tc.reconcileOK(t)
+	tc.setRound(func(round *database.MatchRound) { round.HasOutcome = true })
+	tc.reconcileOK(t)
+
+	secrets := tc.clientset.CoreV1().Secrets(tc.cfg.Namespace)
+	list, err := secrets.List(context.Background(), metav1.ListOptions{LabelSelector: retiredLabel + "=true"})
+	if err != nil {
+		t.Fatalf("list retained secrets: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("retained %d secrets, want 1", len(list.Items))
+	}
+
+	// Turning retention off still deletes the copy once its window has passed.
+	tc.cfg.Match.RetainSecrets = 0
+	retained := list.Items[0]
+	retained.Annotations[retainUntilAnnotation] = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	if _, err := secrets.Update(context.Background(), &retained, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("backdate retained secret: %v", err)
+	}
+	tc.reconcileOK(t)
+	list, err = secrets.List(context.Background(), metav1.ListOptions{LabelSelector: retiredLabel + "=true"})
+	if err != nil {
+		t.Fatalf("list retained secrets: %v", err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("kept %d expired retained secrets with retention off", len(list.Items))
+	}
+}
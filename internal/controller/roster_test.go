@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+// TestReconcileUsesRosterIDs checks that a match's home_team_id and
+// away_team_id are used as league_rosters IDs everywhere: the away roster is
+// seeded into a different division and league, so looking the division up by
+// the wrong roster, or the teams up by the match ID, shows in the values.
+func TestReconcileUsesRosterIDs(t *testing.T) {
+	tc := newTestController(t)
+	tc.repo.Divisions[testAwayRosterID] = database.Division{ID: "32", Name: "Open"}
+	tc.repo.Leagues["32"] = database.League{ID: 5, Name: "Ultiduo", MinPlayers: 2, MaxPlayers: 4}
+	tc.reconcileOK(t)
+
+	values, ok := tc.renderer.applied[tc.releaseName(testMatchID, testRoundID)]
+	if !ok {
+		t.Fatal("release was not applied")
+	}
+	for name, want := range map[string]string{
+		"MIN_PLAYERS":  "9",
+		"MAX_PLAYERS":  "18",
+		"HOME_TEAM":    "STEAM_0:1:1001,STEAM_0:1:1002",
+		"HOME_TEAM_ID": "101",
+		"AWAY_TEAM":    "STEAM_0:1:2001",
+		"AWAY_TEAM_ID": "202",
+		"MATCH_ID":     "7",
+	} {
+		if got := envValue(t, values, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	if len(tc.repo.Notifications) != 1 {
+		t.Fatalf("sent %d notifications, want 1", len(tc.repo.Notifications))
+	}
+	sent := tc.repo.Notifications[0]
+	if sent.HomeRosterID != testHomeRosterID || sent.AwayRosterID != testAwayRosterID {
+		t.Errorf("notified rosters %d and %d, want %d and %d", sent.HomeRosterID, sent.AwayRosterID, testHomeRosterID, testAwayRosterID)
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+// rotateRCON replaces a running server's RCON password once it is older than
+// RCON_ROTATION_INTERVAL. The new password is saved in the state secret as
+// pending before it is pushed, so a server that accepted it can always be reached
+// again; an unconfirmed push is retried with the same password.
+func (c *Controller) rotateRCON(ctx context.Context, match database.Match, round database.MatchRound, details *database.MatchDetails, releaseName string) error {
+	state, err := c.loadServerState(ctx, releaseName)
+	if err != nil {
+		return fmt.Errorf("load server state: %w", err)
+	}
+	if !c.rotationDue(state) {
+		return nil
+	}
+	unlock, err := c.lockRound(ctx, releaseName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// The server may have been deleted or rotated while this waited for the lock.
+	if state, err = c.loadServerState(ctx, releaseName); err != nil {
+		return fmt.Errorf("load server state: %w", err)
+	}
+	if !c.rotationDue(state) {
+		return nil
+	}
+
+	// A fresh password cannot be on the server yet, so it is not tried when dialing.
+	dialState := *state
+	next := state.NextRCON
+	if next == "" {
+		// The current password is part of the label so deterministic secrets still
+		// rotate to a new value.
+		source := c.secretSource(fmt.Sprintf("rcon-rotation/%d/%d/%s", match.ID, round.ID, state.rconPasswords()[0]))
+		if next, err = generateSecret(source, c.cfg.SRCDS.RCONLength, c.cfg.SRCDS.RCONAlphabet); err != nil {
+			return fmt.Errorf("generate rcon: %w", err)
+		}
+		state.NextRCON = next
+		if _, err := c.persistStateSecret(ctx, match, round, state); err != nil {
+			return fmt.Errorf("persist pending rcon: %w", err)
+		}
+	}
+
+	addr := net.JoinHostPort(details.ServerIP, strconv.Itoa(details.Port))
+	client, err := dialRCON(ctx, addr, &dialState)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if _, err := client.Execute(fmt.Sprintf("rcon_password %q", next)); err != nil {
+		return fmt.Errorf("push rcon password: %w", err)
+	}
+
+	state.LiveRCON = next
+	state.NextRCON = ""
+	state.RCONRotatedAt = time.Now()
+	if _, err := c.persistStateSecret(ctx, match, round, state); err != nil {
+		return fmt.Errorf("persist rotated rcon: %w", err)
+	}
+	klog.Infof("rotated rcon password for match %d round %d", match.ID, round.ID)
+	return nil
+}
+
+// rotationDue reports whether state's RCON password is older than RCON_ROTATION_INTERVAL.
+func (c *Controller) rotationDue(state *serverState) bool {
+	return state != nil && state.RCON != "" && time.Since(state.RCONRotatedAt) >= c.cfg.SRCDS.RCONRotation
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strconv"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+)
+
+// serverConfigKey is the ConfigMap key holding the rendered file.
+const serverConfigKey = "server.cfg"
+
+// ServerConfigData is what SRCDS_SERVER_CONFIG_TEMPLATE_FILE is executed with.
+type ServerConfigData struct {
+	MatchID    int
+	RoundID    int
+	Division   string
+	League     string
+	Map        string
+	Hostname   string
+	WinLimit   int
+	MaxRounds  int
+	MinPlayers int
+	MaxPlayers int
+	TickRate   int
+}
+
+func (c *Controller) serverConfigMapName(releaseName string) string {
+	return fmt.Sprintf("%s-config", releaseName)
+}
+
+// renderServerConfig executes the server config template for a round. It returns
+// "" when no template is configured.
+func (c *Controller) renderServerConfig(match database.Match, round database.MatchRound, division *database.Division, league *database.League, state *serverState) (string, error) {
+	if c.serverConfig == nil {
+		return "", nil
+	}
+	winLimit, maxRounds := c.roundLimits(match, league)
+	var buf bytes.Buffer
+	err := c.serverConfig.Execute(&buf, ServerConfigData{
+		MatchID:    match.ID,
+		RoundID:    round.ID,
+		Division:   division.Name,
+		League:     league.Name,
+		Map:        preferValue(state.Map, c.cfg.Match.DefaultMap, ""),
+		Hostname:   c.serverHostname(match, round, division, league, state),
+		WinLimit:   winLimit,
+		MaxRounds:  maxRounds,
+		MinPlayers: league.MinPlayers,
+		MaxPlayers: c.maxPlayers(league),
+		TickRate:   c.tickRate(league),
+	})
+	if err != nil {
+		return "", fmt.Errorf("render server config: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// applyServerConfig stores the rendered file in the release's ConfigMap. The
+// ConfigMap carries the release label, so deleting the release sweeps it, and is
+// owned by the state secret like the chart objects.
+func (c *Controller) applyServerConfig(ctx context.Context, match database.Match, round database.MatchRound, releaseName, content string, owner *metav1.OwnerReference) error {
+	desired := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.serverConfigMapName(releaseName),
+			Namespace: c.cfg.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": releaseName,
+				"udl.tf/release":             releaseName,
+				"udl.tf/match-id":            strconv.Itoa(match.ID),
+				"udl.tf/round-id":            strconv.Itoa(round.ID),
+			},
+		},
+		Data: map[string]string{serverConfigKey: content},
+	}
+	for k, v := range c.cfg.CommonLabels {
+		if _, ok := desired.Labels[k]; !ok {
+			desired.Labels[k] = v
+		}
+	}
+	if len(c.cfg.CommonAnnotations) > 0 {
+		desired.Annotations = c.cfg.CommonAnnotations
+	}
+	if owner != nil {
+		desired.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+
+	configMaps := c.clientset.CoreV1().ConfigMaps(c.cfg.Namespace)
+	existing, err := configMaps.Get(ctx, desired.Name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			_, err = configMaps.Create(ctx, desired, metav1.CreateOptions{})
+		}
+		return err
+	}
+	desired.ResourceVersion = existing.ResourceVersion
+	_, err = configMaps.Update(ctx, desired, metav1.UpdateOptions{})
+	return err
+}
+
+// withServerConfig mounts the release's ConfigMap at SRCDS_SERVER_CONFIG_PATH
+// through the chart's extraVolumes and app.extraVolumeMounts values. The content
+// hash is added as a pod annotation so a changed file restarts the server, as
+// subPath mounts are not updated in place.
+func (c *Controller) withServerConfig(values chartutil.Values, releaseName, content string) {
+	sum := sha256.Sum256([]byte(content))
+	values["extraVolumes"] = []map[string]interface{}{{
+		"name": "server-config",
+		"configMap": map[string]interface{}{
+			"name": c.serverConfigMapName(releaseName),
+		},
+	}}
+	app := values["app"].(map[string]interface{})
+	app["extraVolumeMounts"] = []map[string]interface{}{{
+		"name":      "server-config",
+		"mountPath": c.cfg.SRCDS.ServerConfigPath,
+		"subPath":   serverConfigKey,
+		"readOnly":  true,
+	}}
+	annotations, _ := values["podAnnotations"].(map[string]interface{})
+	if annotations == nil {
+		annotations = map[string]interface{}{}
+	}
+	annotations["udl.tf/server-config"] = path.Base(c.cfg.SRCDS.ServerConfigPath) + "@" + hex.EncodeToString(sum[:8])
+	values["podAnnotations"] = annotations
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/UDL-TF/TourneyController/internal/config"
+)
+
+func TestServerConfigMatchesLimitEnv(t *testing.T) {
+	tc := newTestController(t)
+	tc.cfg.Match.LimitStrategy = config.LimitBestOf
+	tmpl, err := config.ParseServerConfigTemplate("mp_winlimit {{.WinLimit}}; mp_maxrounds {{.MaxRounds}}")
+	if err != nil {
+		t.Fatalf("parse template: %v", err)
+	}
+	tc.serverConfig = tmpl
+	tc.repo.mu.Lock()
+	match := tc.repo.Matches[testMatchID]
+	match.WinLimit = 5
+	tc.repo.Matches[testMatchID] = match
+	tc.repo.mu.Unlock()
+	tc.reconcileOK(t)
+
+	releaseName := tc.releaseName(testMatchID, testRoundID)
+	values := tc.renderer.applied[releaseName]
+	if got := envValue(t, values, "WIN_LIMIT"); got != "3" {
+		t.Errorf("WIN_LIMIT = %s, want 3 for a best of 5", got)
+	}
+	if got := envValue(t, values, "MAX_ROUNDS"); got != "5" {
+		t.Errorf("MAX_ROUNDS = %s, want 5 for a best of 5", got)
+	}
+	configMap, err := tc.clientset.CoreV1().ConfigMaps(tc.cfg.Namespace).Get(context.Background(), tc.serverConfigMapName(releaseName), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get server config: %v", err)
+	}
+	if got, want := configMap.Data[serverConfigKey], "mp_winlimit 3; mp_maxrounds 5"; got != want {
+		t.Errorf("server.cfg = %q, want %q", got, want)
+	}
+}
+
+func TestServerConfigMatchesLeagueSettings(t *testing.T) {
+	tc := newTestController(t)
+	tc.cfg.SRCDS.LeagueSettings = map[int]config.LeagueSettings{4: {TickRate: 128, MaxPlayers: 20}}
+	tmpl, err := config.ParseServerConfigTemplate("maxplayers {{.MaxPlayers}}; tickrate {{.TickRate}}")
+	if err != nil {
+		t.Fatalf("parse template: %v", err)
+	}
+	tc.serverConfig = tmpl
+	tc.reconcileOK(t)
+
+	releaseName := tc.releaseName(testMatchID, testRoundID)
+	if got := envValue(t, tc.renderer.applied[releaseName], "MAX_PLAYERS"); got != "20" {
+		t.Errorf("MAX_PLAYERS = %s, want the league setting's 20", got)
+	}
+	configMap, err := tc.clientset.CoreV1().ConfigMaps(tc.cfg.Namespace).Get(context.Background(), tc.serverConfigMapName(releaseName), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get server config: %v", err)
+	}
+	if got, want := configMap.Data[serverConfigKey], "maxplayers 20; tickrate 128"; got != want {
+		t.Errorf("server.cfg = %q, want %q", got, want)
+	}
+}
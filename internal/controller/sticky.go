@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// passwordKeyLength is the size of the key sticky passwords are derived from.
+const passwordKeyLength = 32
+
+const passwordKeyData = "key"
+
+func (c *Controller) passwordKeyName() string {
+	return preferValue(c.cfg.Release.Prefix, "udl") + "-password-key"
+}
+
+// passwordSource returns the randomness a round's server password is drawn from.
+// With SRCDS_STICKY_PASSWORDS it is derived from a namespace-wide key secret, so
+// a round whose state secret and match details were both lost gets the same
+// password back instead of one the players were never told. Without the key it
+// falls back to secretSource.
+func (c *Controller) passwordSource(ctx context.Context, label string) io.Reader {
+	if !c.cfg.SRCDS.StickyPasswords || c.cfg.SRCDS.UnsafeSecretSeed != "" {
+		return c.secretSource(label)
+	}
+	key, err := c.passwordKey(ctx)
+	if err != nil {
+		klog.Warningf("sticky password key unavailable, generating a random password: %v", err)
+		return c.secretSource(label)
+	}
+	return newSeededReader(key, label)
+}
+
+// passwordKey loads the sticky password key, creating it on first use. The key
+// secret has no owner and outlives every server; deleting it changes the
+// password of every round that has to be recreated afterwards.
+func (c *Controller) passwordKey(ctx context.Context) ([]byte, error) {
+	if c.stickyKey != nil {
+		return c.stickyKey, nil
+	}
+
+	secrets := c.clientset.CoreV1().Secrets(c.cfg.Namespace)
+	secret, err := secrets.Get(ctx, c.passwordKeyName(), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		key := make([]byte, passwordKeyLength)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, fmt.Errorf("generate password key: %w", err)
+		}
+		desired := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        c.passwordKeyName(),
+				Namespace:   c.cfg.Namespace,
+				Labels:      c.cfg.CommonLabels,
+				Annotations: c.cfg.CommonAnnotations,
+			},
+			Data: map[string][]byte{passwordKeyData: key},
+			Type: corev1.SecretTypeOpaque,
+		}
+		secret, err = secrets.Create(ctx, desired, metav1.CreateOptions{})
+		if k8serrors.IsAlreadyExists(err) {
+			// Another replica or command created it first; use theirs.
+			secret, err = secrets.Get(ctx, c.passwordKeyName(), metav1.GetOptions{})
+		}
+		if err == nil {
+			klog.Infof("created sticky password key secret %s", c.passwordKeyName())
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load password key secret %s: %w", c.passwordKeyName(), err)
+	}
+
+	key := secret.Data[passwordKeyData]
+	if len(key) < passwordKeyLength {
+		return nil, fmt.Errorf("password key secret %s has no %d byte %q entry", c.passwordKeyName(), passwordKeyLength, passwordKeyData)
+	}
+	c.stickyKey = key
+	return key, nil
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/UDL-TF/TourneyController/internal/database"
+	"github.com/UDL-TF/TourneyController/internal/steam"
+)
+
+// checkExpiredTokens refreshes expiredAccounts from the Steam account list at most
+// once per STEAM_TOKEN_CHECK_INTERVAL. Between checks expiredAccounts is empty, so
+// each expired token is reset once.
+func (c *Controller) checkExpiredTokens() {
+	c.expiredAccounts = nil
+	if c.tokens == nil || !c.cfg.Steam.EnableAutoTokens || c.cfg.Steam.TokenCheckInterval <= 0 {
+		return
+	}
+	if time.Since(c.lastTokenCheck) < c.cfg.Steam.TokenCheckInterval {
+		return
+	}
+	c.lastTokenCheck = time.Now()
+
+	accounts, err := c.tokens.GetAccountList()
+	if err != nil {
+		klog.Warningf("failed to list Steam accounts for expiry check: %v", err)
+		return
+	}
+	c.expiredAccounts = map[string]steam.Account{}
+	for _, account := range accounts {
+		if account.IsExpired && !account.IsDeleted {
+			c.expiredAccounts[account.Memo] = account
+		}
+	}
+}
+
+// refreshExpiredToken resets a running server's login token when Steam reports it
+// expired, which drops the server from the master list. The new token is recorded
+// in the state secret and reaches the server when the next apply restarts its pod.
+func (c *Controller) refreshExpiredToken(ctx context.Context, match database.Match, round database.MatchRound, releaseName string) error {
+	account, ok := c.expiredAccounts[fmt.Sprintf(c.cfg.Steam.TokenMemoTemplate, match.ID, round.ID)]
+	if !ok {
+		return nil
+	}
+	unlock, err := c.lockRound(ctx, releaseName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	state, err := c.loadServerState(ctx, releaseName)
+	if err != nil {
+		return fmt.Errorf("load server state: %w", err)
+	}
+	if state == nil {
+		return nil
+	}
+
+	reset, err := c.tokens.ResetLoginToken(account.SteamID)
+	if err != nil {
+		return fmt.Errorf("reset login token for %s: %w", account.SteamID, err)
+	}
+	state.Token = reset.LoginToken
+	if _, err := c.persistStateSecret(ctx, match, round, state); err != nil {
+		return fmt.Errorf("persist reset token: %w", err)
+	}
+	klog.Infof("reset expired login token %s for match %d round %d; the server restarts with it", account.SteamID, match.ID, round.ID)
+	return nil
+}
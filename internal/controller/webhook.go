@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"k8s.io/klog/v2"
+)
+
+// triggerQueue bounds the reconciles requested through POST /reconcile that are
+// waiting for the Run loop.
+const triggerQueue = 64
+
+// TriggerReconcile queues an immediate reconcile of matchID. The Run loop picks it
+// up between ticks, so reconciles never run concurrently. It reports false when
+// the queue is full; the regular poll still covers the match then.
+func (c *Controller) TriggerReconcile(matchID int) bool {
+	select {
+	case c.triggers <- matchID:
+		return true
+	default:
+		return false
+	}
+}
+
+// reconcileTriggered runs a reconcile requested through TriggerReconcile.
+func (c *Controller) reconcileTriggered(ctx context.Context, matchID int) {
+	klog.Infof("reconciling match %d on request", matchID)
+	status, err := c.ReconcileMatch(ctx, matchID)
+	switch {
+	case err != nil:
+		klog.Errorf("requested reconcile of match %d failed (%s): %v", matchID, FailureReason(err), err)
+	case status.Skipped != "":
+		klog.V(1).Infof("requested reconcile of match %d skipped: %s", matchID, status.Skipped)
+	}
+}
+
+// handleReconcile serves POST /reconcile?match_id=N for the league site to call
+// when a match's status changes, so its servers appear without waiting for the
+// next poll. The reconcile is queued and the request answered with 202 at once.
+// It is registered behind requireAuth.
+func (c *Controller) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	matchID, err := strconv.Atoi(r.FormValue("match_id"))
+	if err != nil || matchID <= 0 {
+		http.Error(w, "match_id must be a positive integer", http.StatusBadRequest)
+		return
+	}
+	if !c.TriggerReconcile(matchID) {
+		http.Error(w, "reconcile queue is full, the next poll will pick the match up", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"matchId": matchID, "queued": true})
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats reports how often cached lookups were served without a query.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// lookupCache keeps rarely changing rows (divisions, leagues, maps) in memory for
+// a fixed TTL. A zero TTL disables caching entirely.
+type lookupCache struct {
+	ttl time.Duration
+
+	divisions *ttlMap[int, *Division]  // keyed by roster ID
+	leagues   *ttlMap[string, *League] // keyed by division ID
+	maps      *ttlMap[int, string]
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func newLookupCache(ttl time.Duration) *lookupCache {
+	return &lookupCache{
+		ttl:       ttl,
+		divisions: newTTLMap[int, *Division](),
+		leagues:   newTTLMap[string, *League](),
+		maps:      newTTLMap[int, string](),
+	}
+}
+
+func (c *lookupCache) enabled() bool {
+	return c != nil && c.ttl > 0
+}
+
+func (c *lookupCache) record(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+func (c *lookupCache) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+type ttlMap[K comparable, V any] struct {
+	mu      sync.Mutex
+	entries map[K]ttlEntry[V]
+}
+
+func newTTLMap[K comparable, V any]() *ttlMap[K, V] {
+	return &ttlMap[K, V]{entries: map[K]ttlEntry[V]{}}
+}
+
+func (m *ttlMap[K, V]) get(key K) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(m.entries, key)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (m *ttlMap[K, V]) set(key K, value V, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = ttlEntry[V]{value: value, expires: time.Now().Add(ttl)}
+}
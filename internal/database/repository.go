@@ -7,21 +7,168 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lib/pq"
+	"k8s.io/klog/v2"
 
 	"github.com/UDL-TF/TourneyController/internal/config"
 )
 
+// ErrNotFound is wrapped by lookups whose target row does not exist.
+var ErrNotFound = errors.New("not found")
+
 // Repository centralizes all database access for the controller.
+// Reads of site-owned tables go to read, which is the replica when one is
+// configured. matches_server_details is written by the controller and read back
+// within the same tick, so it always uses the primary.
 type Repository struct {
-	db *sql.DB
+	db    *sql.DB
+	read  *sql.DB
+	stmts statements
+	cache *lookupCache
+	names *strings.Replacer
+
+	slowThreshold time.Duration
+	slowQueries   atomic.Uint64
+
+	// notifyCooldown merges notifications sent to a user within this window into
+	// one; zero inserts every notification.
+	notifyCooldown time.Duration
+	// notified holds the last notification inserted for each user, the only row
+	// a later notification may be merged into, so rows written by the site or
+	// by another sender are never changed.
+	notifyMu sync.Mutex
+	notified map[int]sentNotification
+}
+
+// sentNotification is a user_notifications row the controller inserted.
+type sentNotification struct {
+	id   int64
+	link string
+}
+
+// statements holds the queries run for every match and round on each tick,
+// prepared once in New so Postgres does not re-parse them.
+type statements struct {
+	division      *sql.Stmt
+	leagueID      *sql.Stmt
+	league        *sql.Stmt
+	mapName       *sql.Stmt
+	matchDetails  *sql.Stmt
+	upsertDetails *sql.Stmt
 }
 
-// New opens a PostgreSQL connection using the provided settings.
+const (
+	divisionQuery = `
+        SELECT lr.{league_rosters.division_id}, ld.{league_divisions.name}
+        FROM {league_rosters} lr
+        JOIN {league_divisions} ld ON ld.{league_divisions.id} = lr.{league_rosters.division_id}
+        WHERE lr.{league_rosters.id} = $1
+    `
+	leagueIDQuery = `
+        SELECT {league_divisions.league_id} FROM {league_divisions} WHERE {league_divisions.id} = $1
+    `
+	leagueQuery = `
+        SELECT {leagues.name}, {leagues.min_players}, {leagues.max_players_in_game},
+               {leagues.points_per_round_win}, {leagues.points_per_round_draw}, {leagues.points_per_round_loss},
+               {leagues.points_per_match_win}, {leagues.points_per_match_loss}, {leagues.points_per_match_draw},
+               {leagues.points_per_forfeit_win}, {leagues.points_per_forfeit_loss}, {leagues.points_per_forfeit_draw}
+        FROM {leagues}
+        WHERE {leagues.id} = $1
+    `
+	mapNameQuery      = `SELECT {maps.name} FROM {maps} WHERE {maps.id} = $1`
+	matchDetailsQuery = `
+        SELECT ` + matchDetailsColumns + `
+        FROM {matches_server_details}
+        WHERE {matches_server_details.match_id} = $1 AND {matches_server_details.round_id} = $2
+    `
+	upsertDetailsQuery = `
+        INSERT INTO {matches_server_details} ({matches_server_details.match_id}, {matches_server_details.server_ip},
+               {matches_server_details.port}, {matches_server_details.sourcetvport}, {matches_server_details.client_port},
+               {matches_server_details.steam_port}, {matches_server_details.password}, {matches_server_details.map},
+               {matches_server_details.round_id}, {matches_server_details.created_at}, {matches_server_details.updated_at})
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+        ON CONFLICT ({matches_server_details.match_id}, {matches_server_details.round_id})
+        DO UPDATE SET {matches_server_details.server_ip} = EXCLUDED.{matches_server_details.server_ip},
+                      {matches_server_details.port} = EXCLUDED.{matches_server_details.port},
+                      {matches_server_details.sourcetvport} = EXCLUDED.{matches_server_details.sourcetvport},
+                      {matches_server_details.client_port} = EXCLUDED.{matches_server_details.client_port},
+                      {matches_server_details.steam_port} = EXCLUDED.{matches_server_details.steam_port},
+                      {matches_server_details.password} = EXCLUDED.{matches_server_details.password},
+                      {matches_server_details.map} = EXCLUDED.{matches_server_details.map},
+                      {matches_server_details.updated_at} = NOW()
+    `
+	matchDetailsColumns = `
+        {matches_server_details.match_id}, {matches_server_details.round_id}, {matches_server_details.server_ip},
+        {matches_server_details.port}, {matches_server_details.sourcetvport},
+        COALESCE({matches_server_details.client_port}, 0), COALESCE({matches_server_details.steam_port}, 0),
+        {matches_server_details.password}, {matches_server_details.map}
+    `
+	matchColumns = `
+        {league_matches.id}, {league_matches.home_team_id}, {league_matches.away_team_id},
+        {league_matches.win_limit}, {league_matches.status}, {league_matches.manual_not_done}
+    `
+)
+
+// New opens a PostgreSQL connection using the provided settings, plus a second
+// pool for reads when a replica DSN is configured.
 func New(cfg config.DatabaseConfig) (*Repository, error) {
-	db, err := sql.Open("postgres", cfg.DSN())
+	db, err := open(cfg, cfg.DSN())
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &Repository{
+		db:    db,
+		read:  db,
+		cache: newLookupCache(cfg.LookupCacheTTL),
+		names: nameReplacer(cfg.Schema),
+
+		slowThreshold: cfg.SlowQueryThreshold,
+	}
+	if cfg.ReplicaDSN != "" {
+		replica, err := open(cfg, cfg.ReplicaDSN)
+		if err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("replica: %w", err)
+		}
+		repo.read = replica
+	}
+	if err := repo.prepare(); err != nil {
+		_ = repo.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// healthCheckTimeout bounds HealthCheck, so an unreachable database is reported
+// promptly instead of waiting out the connect timeout.
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck runs SELECT 1 on the primary and, when configured, the read replica.
+// It is independent of the reconcile queries, so it tells "database unreachable"
+// apart from "reconcile queries slow".
+func (r *Repository) HealthCheck(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	var one int
+	if err := r.queryRow(ctx, r.db, "HealthCheck", `SELECT 1`).Scan(&one); err != nil {
+		return fmt.Errorf("primary: %w", err)
+	}
+	if r.read != r.db {
+		if err := r.queryRow(ctx, r.read, "HealthCheck", `SELECT 1`).Scan(&one); err != nil {
+			return fmt.Errorf("replica: %w", err)
+		}
+	}
+	return nil
+}
+
+func open(cfg config.DatabaseConfig, dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open postgres connection: %w", err)
 	}
@@ -38,26 +185,83 @@ func New(cfg config.DatabaseConfig) (*Repository, error) {
 		_ = db.Close()
 		return nil, fmt.Errorf("ping database: %w", err)
 	}
+	return db, nil
+}
+
+func (r *Repository) prepare() error {
+	for _, s := range []struct {
+		name  string
+		db    *sql.DB
+		query string
+		dest  **sql.Stmt
+	}{
+		{"division", r.read, divisionQuery, &r.stmts.division},
+		{"league id", r.read, leagueIDQuery, &r.stmts.leagueID},
+		{"league", r.read, leagueQuery, &r.stmts.league},
+		{"map name", r.read, mapNameQuery, &r.stmts.mapName},
+		{"match details", r.db, matchDetailsQuery, &r.stmts.matchDetails},
+		{"upsert match details", r.db, upsertDetailsQuery, &r.stmts.upsertDetails},
+	} {
+		stmt, err := s.db.Prepare(r.sql(s.query))
+		if err != nil {
+			return fmt.Errorf("prepare %s query: %w", s.name, err)
+		}
+		*s.dest = stmt
+	}
+	return nil
+}
 
-	return &Repository{db: db}, nil
+// sql expands the {table} and {table.column} placeholders in query to the
+// configured schema names.
+func (r *Repository) sql(query string) string {
+	return r.names.Replace(query)
 }
 
-// Close closes the underlying sql.DB.
+// CacheStats returns hit and miss counts for the division, league and map cache.
+func (r *Repository) CacheStats() CacheStats {
+	return r.cache.stats()
+}
+
+// Close closes the prepared statements and the underlying connection pools.
 func (r *Repository) Close() error {
 	if r.db == nil {
 		return nil
 	}
+	for _, stmt := range []*sql.Stmt{
+		r.stmts.division,
+		r.stmts.leagueID,
+		r.stmts.league,
+		r.stmts.mapName,
+		r.stmts.matchDetails,
+		r.stmts.upsertDetails,
+	} {
+		if stmt != nil {
+			_ = stmt.Close()
+		}
+	}
+	if r.read != nil && r.read != r.db {
+		_ = r.read.Close()
+	}
 	return r.db.Close()
 }
 
 // Match mirrors a row in league_matches relevant to scheduling.
+//
+// Despite their column names, home_team_id and away_team_id hold league_rosters
+// IDs: a roster is a team's entry in one division, and it is what
+// league_rosters.division_id and league_roster_players.roster_id key on. Every
+// query here that takes a roster ID (FetchDivision, FetchTeamSteamIDs,
+// SendNotificationsToTeams and their batch variants) expects these values.
 type Match struct {
 	ID            int
-	RosterAwayID  int
-	RosterHomeID  int
+	RosterAwayID  int // league_matches.away_team_id, a league_rosters.id
+	RosterHomeID  int // league_matches.home_team_id, a league_rosters.id
 	WinLimit      int
 	Status        int
 	ManualNotDone bool
+	Prewarm       bool         // Starts within MatchQuery.PrewarmLead; only set by QueryMatches
+	Priority      int          // league_matches.priority; only set by QueryMatches with MatchQuery.Priority
+	ScheduledAt   sql.NullTime // Only set by QueryMatches with MatchQuery.Schedule
 }
 
 // MatchRound mirrors league_match_rounds rows we care about.
@@ -67,8 +271,8 @@ type MatchRound struct {
 	MapID           int
 	HomeTeamScore   int
 	AwayTeamScore   int
-	LoserID         sql.NullInt64
-	WinnerID        sql.NullInt64
+	LoserID         sql.NullInt64 // A league_rosters.id, like Match.RosterHomeID
+	WinnerID        sql.NullInt64 // A league_rosters.id, like Match.RosterHomeID
 	HasOutcome      bool
 	ScoreDifference float64
 	HomeReady       bool
@@ -76,18 +280,24 @@ type MatchRound struct {
 }
 
 // MatchDetails mirrors matches_server_details rows tracked by the site.
+// ClientPort and SteamPort map to the nullable integer columns client_port and
+// steam_port, and are zero for rows written before those columns existed.
 type MatchDetails struct {
 	MatchID      int
 	RoundID      int
 	ServerIP     string
 	Port         int
 	SourceTVPort int
+	ClientPort   int
+	SteamPort    int
 	Password     string
 	Map          string
 }
 
 // League contains per-division gameplay metadata.
 type League struct {
+	ID                   int
+	Name                 string
 	MinPlayers           int
 	MaxPlayers           int
 	PointsPerRoundWin    float32
@@ -107,13 +317,69 @@ type Division struct {
 	Name string
 }
 
-// FetchMatches returns all matches whose status is in the provided set.
-func (r *Repository) FetchMatches(ctx context.Context, statuses []int) ([]Match, error) {
-	rows, err := r.db.QueryContext(ctx, `
-        SELECT id, home_team_id, away_team_id, win_limit, status, manual_not_done
-        FROM league_matches
-        WHERE status = ANY($1) AND home_team_id IS NOT NULL AND away_team_id IS NOT NULL
-    `, pq.Array(statuses))
+// MatchQuery narrows and orders the matches returned by QueryMatches.
+type MatchQuery struct {
+	Statuses    []int
+	Allowlist   []int // When non-empty, only these match IDs are returned
+	Order       config.MatchOrder
+	Limit       int           // Zero returns every matching row
+	Lookahead   time.Duration // When positive, skip matches scheduled later than now plus this; unscheduled matches are kept
+	PrewarmLead time.Duration // When positive, mark matches starting within this as Prewarm
+	Priority    bool          // Fill Match.Priority from league_matches.priority
+	Schedule    bool          // Fill Match.ScheduledAt from league_matches.scheduled_at
+}
+
+// FetchMatches returns all matches whose status is in the provided set. A non-empty
+// allowlist further restricts the result to those match IDs.
+func (r *Repository) FetchMatches(ctx context.Context, statuses []int, allowlist []int) ([]Match, error) {
+	return r.QueryMatches(ctx, MatchQuery{Statuses: statuses, Allowlist: allowlist, Order: config.MatchOrderNone})
+}
+
+// QueryMatches returns matches filtered by status and allowlist, ordered and
+// truncated as requested so the most urgent matches are reconciled first.
+func (r *Repository) QueryMatches(ctx context.Context, q MatchQuery) ([]Match, error) {
+	args := []interface{}{pq.Array(q.Statuses), pq.Array(q.Allowlist)}
+	prewarm := "FALSE"
+	if q.PrewarmLead > 0 {
+		args = append(args, q.PrewarmLead.Seconds())
+		prewarm = fmt.Sprintf(
+			"({league_matches.scheduled_at} > NOW() AND {league_matches.scheduled_at} <= NOW() + make_interval(secs => $%d))",
+			len(args),
+		)
+	}
+	priority, scheduledAt := "0", "NULL::timestamptz"
+	if q.Priority {
+		priority = "COALESCE({league_matches.priority}, 0)"
+	}
+	if q.Schedule {
+		scheduledAt = "{league_matches.scheduled_at}"
+	}
+	query := `
+        SELECT ` + matchColumns + `, ` + prewarm + `, ` + priority + `, ` + scheduledAt + `
+        FROM {league_matches}
+        WHERE {league_matches.status} = ANY($1)
+          AND {league_matches.home_team_id} IS NOT NULL AND {league_matches.away_team_id} IS NOT NULL
+          AND (COALESCE(cardinality($2::int[]), 0) = 0 OR {league_matches.id} = ANY($2))
+    `
+	if q.Lookahead > 0 {
+		args = append(args, q.Lookahead.Seconds())
+		query += fmt.Sprintf(
+			" AND ({league_matches.scheduled_at} IS NULL OR {league_matches.scheduled_at} <= NOW() + make_interval(secs => $%d))",
+			len(args),
+		)
+	}
+	switch q.Order {
+	case config.MatchOrderID:
+		query += " ORDER BY {league_matches.id}"
+	case config.MatchOrderScheduled:
+		query += " ORDER BY {league_matches.scheduled_at} ASC NULLS LAST, {league_matches.id}"
+	}
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.query(ctx, r.read, "QueryMatches", query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query league_matches: %w", err)
 	}
@@ -122,7 +388,7 @@ func (r *Repository) FetchMatches(ctx context.Context, statuses []int) ([]Match,
 	var matches []Match
 	for rows.Next() {
 		var m Match
-		if err := rows.Scan(&m.ID, &m.RosterHomeID, &m.RosterAwayID, &m.WinLimit, &m.Status, &m.ManualNotDone); err != nil {
+		if err := rows.Scan(&m.ID, &m.RosterHomeID, &m.RosterAwayID, &m.WinLimit, &m.Status, &m.ManualNotDone, &m.Prewarm, &m.Priority, &m.ScheduledAt); err != nil {
 			return nil, fmt.Errorf("scan league_match: %w", err)
 		}
 		matches = append(matches, m)
@@ -135,35 +401,91 @@ func (r *Repository) FetchMatches(ctx context.Context, statuses []int) ([]Match,
 
 // FetchDivision returns the division metadata for a roster.
 func (r *Repository) FetchDivision(ctx context.Context, rosterID int) (*Division, error) {
+	if r.cache.enabled() {
+		division, ok := r.cache.divisions.get(rosterID)
+		r.cache.record(ok)
+		if ok {
+			return division, nil
+		}
+	}
+
 	var division Division
-	if err := r.db.QueryRowContext(ctx, `
-	        SELECT lr.division_id, ld.name
-	        FROM league_rosters lr
-	        JOIN league_divisions ld ON ld.id = lr.division_id
-	        WHERE lr.id = $1
-	    `, rosterID).Scan(&division.ID, &division.Name); err != nil {
+	if err := r.stmtQueryRow(ctx, "FetchDivision", r.stmts.division, rosterID).Scan(&division.ID, &division.Name); err != nil {
 		return nil, fmt.Errorf("fetch division for roster %d: %w", rosterID, err)
 	}
+	if r.cache.enabled() {
+		r.cache.divisions.set(rosterID, &division, r.cache.ttl)
+	}
 	return &division, nil
 }
 
+// FetchDivisionsForRosters returns division metadata keyed by roster ID for every
+// roster that exists, in a single query.
+func (r *Repository) FetchDivisionsForRosters(ctx context.Context, rosterIDs []int) (map[int]*Division, error) {
+	divisions := make(map[int]*Division, len(rosterIDs))
+	missing := rosterIDs
+	if r.cache.enabled() {
+		missing = nil
+		for _, rosterID := range rosterIDs {
+			division, ok := r.cache.divisions.get(rosterID)
+			r.cache.record(ok)
+			if ok {
+				divisions[rosterID] = division
+			} else {
+				missing = append(missing, rosterID)
+			}
+		}
+		if len(missing) == 0 {
+			return divisions, nil
+		}
+	}
+
+	rows, err := r.query(ctx, r.read, "FetchDivisionsForRosters", `
+        SELECT lr.{league_rosters.id}, lr.{league_rosters.division_id}, ld.{league_divisions.name}
+        FROM {league_rosters} lr
+        JOIN {league_divisions} ld ON ld.{league_divisions.id} = lr.{league_rosters.division_id}
+        WHERE lr.{league_rosters.id} = ANY($1)
+    `, pq.Array(missing))
+	if err != nil {
+		return nil, fmt.Errorf("fetch divisions for rosters: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rosterID int
+		var division Division
+		if err := rows.Scan(&rosterID, &division.ID, &division.Name); err != nil {
+			return nil, fmt.Errorf("scan division: %w", err)
+		}
+		divisions[rosterID] = &division
+		if r.cache.enabled() {
+			r.cache.divisions.set(rosterID, &division, r.cache.ttl)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate divisions: %w", err)
+	}
+	return divisions, nil
+}
+
 // FetchLeague loads the League metadata by division ID.
 func (r *Repository) FetchLeague(ctx context.Context, divisionID string) (*League, error) {
+	if r.cache.enabled() {
+		league, ok := r.cache.leagues.get(divisionID)
+		r.cache.record(ok)
+		if ok {
+			return league, nil
+		}
+	}
+
 	var leagueID int
-	if err := r.db.QueryRowContext(ctx, `
-        SELECT league_id FROM league_divisions WHERE id = $1
-    `, divisionID).Scan(&leagueID); err != nil {
+	if err := r.stmtQueryRow(ctx, "FetchLeague", r.stmts.leagueID, divisionID).Scan(&leagueID); err != nil {
 		return nil, fmt.Errorf("fetch league_id for division %s: %w", divisionID, err)
 	}
 
-	league := &League{}
-	if err := r.db.QueryRowContext(ctx, `
-        SELECT min_players, max_players_in_game, points_per_round_win, points_per_round_draw, points_per_round_loss,
-               points_per_match_win, points_per_match_loss, points_per_match_draw,
-               points_per_forfeit_win, points_per_forfeit_loss, points_per_forfeit_draw
-        FROM leagues
-        WHERE id = $1
-    `, leagueID).Scan(
+	league := &League{ID: leagueID}
+	if err := r.stmtQueryRow(ctx, "FetchLeague", r.stmts.league, leagueID).Scan(
+		&league.Name,
 		&league.MinPlayers,
 		&league.MaxPlayers,
 		&league.PointsPerRoundWin,
@@ -179,16 +501,19 @@ func (r *Repository) FetchLeague(ctx context.Context, divisionID string) (*Leagu
 		return nil, fmt.Errorf("fetch league metadata %d: %w", leagueID, err)
 	}
 
+	if r.cache.enabled() {
+		r.cache.leagues.set(divisionID, league, r.cache.ttl)
+	}
 	return league, nil
 }
 
 // FetchTeamSteamIDs returns every SteamID on the roster as strings.
 func (r *Repository) FetchTeamSteamIDs(ctx context.Context, rosterID int) ([]string, error) {
-	rows, err := r.db.QueryContext(ctx, `
-        SELECT DISTINCT users.steam_id::text
-        FROM league_roster_players lrp
-        JOIN users ON users.id = lrp.user_id
-        WHERE lrp.roster_id = $1
+	rows, err := r.query(ctx, r.read, "FetchTeamSteamIDs", `
+        SELECT DISTINCT u.{users.steam_id}::text
+        FROM {league_roster_players} lrp
+        JOIN {users} u ON u.{users.id} = lrp.{league_roster_players.user_id}
+        WHERE lrp.{league_roster_players.roster_id} = $1
     `, rosterID)
 	if err != nil {
 		return nil, fmt.Errorf("fetch steam ids for roster %d: %w", rosterID, err)
@@ -211,13 +536,76 @@ func (r *Repository) FetchTeamSteamIDs(ctx context.Context, rosterID int) ([]str
 	return ids, nil
 }
 
+// FetchTeamSteamIDsForRosters returns the SteamIDs of every requested roster in a
+// single query. Each requested roster has an entry, empty if it has no players.
+func (r *Repository) FetchTeamSteamIDsForRosters(ctx context.Context, rosterIDs []int) (map[int][]string, error) {
+	rows, err := r.query(ctx, r.read, "FetchTeamSteamIDsForRosters", `
+        SELECT DISTINCT lrp.{league_roster_players.roster_id}, u.{users.steam_id}::text
+        FROM {league_roster_players} lrp
+        JOIN {users} u ON u.{users.id} = lrp.{league_roster_players.user_id}
+        WHERE lrp.{league_roster_players.roster_id} = ANY($1)
+    `, pq.Array(rosterIDs))
+	if err != nil {
+		return nil, fmt.Errorf("fetch steam ids for rosters: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[int][]string, len(rosterIDs))
+	for _, rosterID := range rosterIDs {
+		ids[rosterID] = nil
+	}
+	for rows.Next() {
+		var rosterID int
+		var id sql.NullString
+		if err := rows.Scan(&rosterID, &id); err != nil {
+			return nil, fmt.Errorf("scan steam id: %w", err)
+		}
+		if id.Valid && strings.TrimSpace(id.String) != "" {
+			ids[rosterID] = append(ids[rosterID], strings.TrimSpace(id.String))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate steam ids: %w", err)
+	}
+	return ids, nil
+}
+
+// matchRoundColumns is the select list shared by every league_match_rounds query,
+// matching the field order of scanMatchRound. Outcome comes from the site's own
+// has_outcome column, which also covers draws that set neither winner nor loser.
+const matchRoundColumns = `
+        {league_match_rounds.id}, {league_match_rounds.match_id}, {league_match_rounds.map_id},
+        {league_match_rounds.home_team_score}, {league_match_rounds.away_team_score},
+        {league_match_rounds.loser_id}, {league_match_rounds.winner_id}, {league_match_rounds.has_outcome},
+        {league_match_rounds.score_difference}, {league_match_rounds.home_ready}, {league_match_rounds.away_ready}
+    `
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMatchRound(row rowScanner, round *MatchRound) error {
+	return row.Scan(
+		&round.ID,
+		&round.MatchID,
+		&round.MapID,
+		&round.HomeTeamScore,
+		&round.AwayTeamScore,
+		&round.LoserID,
+		&round.WinnerID,
+		&round.HasOutcome,
+		&round.ScoreDifference,
+		&round.HomeReady,
+		&round.AwayReady,
+	)
+}
+
 // FetchMatchRounds returns every round for a given match.
 func (r *Repository) FetchMatchRounds(ctx context.Context, matchID int) ([]MatchRound, error) {
-	rows, err := r.db.QueryContext(ctx, `
-        SELECT id, match_id, map_id, home_team_score, away_team_score, loser_id, winner_id,
-               has_outcome, score_difference, home_ready, away_ready
-        FROM league_match_rounds
-        WHERE match_id = $1
+	rows, err := r.query(ctx, r.read, "FetchMatchRounds", `
+        SELECT `+matchRoundColumns+`
+        FROM {league_match_rounds}
+        WHERE {league_match_rounds.match_id} = $1
     `, matchID)
 	if err != nil {
 		return nil, fmt.Errorf("fetch match rounds for %d: %w", matchID, err)
@@ -227,19 +615,7 @@ func (r *Repository) FetchMatchRounds(ctx context.Context, matchID int) ([]Match
 	var rounds []MatchRound
 	for rows.Next() {
 		var round MatchRound
-		if err := rows.Scan(
-			&round.ID,
-			&round.MatchID,
-			&round.MapID,
-			&round.HomeTeamScore,
-			&round.AwayTeamScore,
-			&round.LoserID,
-			&round.WinnerID,
-			&round.HasOutcome,
-			&round.ScoreDifference,
-			&round.HomeReady,
-			&round.AwayReady,
-		); err != nil {
+		if err := scanMatchRound(rows, &round); err != nil {
 			return nil, fmt.Errorf("scan match round: %w", err)
 		}
 		rounds = append(rounds, round)
@@ -250,29 +626,162 @@ func (r *Repository) FetchMatchRounds(ctx context.Context, matchID int) ([]Match
 	return rounds, nil
 }
 
+// FetchRoundUpdatedAt returns when a round was last updated, which for a round with
+// an outcome is when the outcome was recorded unless the row changed afterwards.
+// Sites with a dedicated outcome timestamp can map updated_at to it with
+// DB_SCHEMA_MAPPING_FILE.
+func (r *Repository) FetchRoundUpdatedAt(ctx context.Context, matchID, roundID int) (time.Time, error) {
+	var updatedAt time.Time
+	err := r.queryRow(ctx, r.read, "FetchRoundUpdatedAt", `
+        SELECT {league_match_rounds.updated_at}
+        FROM {league_match_rounds}
+        WHERE {league_match_rounds.match_id} = $1 AND {league_match_rounds.id} = $2
+    `, matchID, roundID).Scan(&updatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, fmt.Errorf("round %d for match %d: %w", roundID, matchID, ErrNotFound)
+		}
+		return time.Time{}, fmt.Errorf("fetch updated_at of round %d for match %d: %w", roundID, matchID, err)
+	}
+	return updatedAt, nil
+}
+
+// FetchMapOverrides returns the map names staff forced for a match's rounds through
+// league_match_rounds.map_override, keyed by round ID. Rounds without an override
+// are absent from the result.
+func (r *Repository) FetchMapOverrides(ctx context.Context, matchID int) (map[int]string, error) {
+	rows, err := r.query(ctx, r.read, "FetchMapOverrides", `
+        SELECT {league_match_rounds.id}, {league_match_rounds.map_override}
+        FROM {league_match_rounds}
+        WHERE {league_match_rounds.match_id} = $1
+          AND COALESCE({league_match_rounds.map_override}, '') <> ''
+    `, matchID)
+	if err != nil {
+		return nil, fmt.Errorf("fetch map overrides for %d: %w", matchID, err)
+	}
+	defer rows.Close()
+
+	overrides := map[int]string{}
+	for rows.Next() {
+		var roundID int
+		var mapName string
+		if err := rows.Scan(&roundID, &mapName); err != nil {
+			return nil, fmt.Errorf("scan map override: %w", err)
+		}
+		if !config.ValidMapName(mapName) {
+			klog.Warningf("ignoring match %d round %d map override, invalid name %q", matchID, roundID, mapName)
+			continue
+		}
+		overrides[roundID] = mapName
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate map overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// FetchValuesOverride returns the raw league_matches.values_override of a match,
+// or "" when it has none. The column may be json, jsonb or text.
+func (r *Repository) FetchValuesOverride(ctx context.Context, matchID int) (string, error) {
+	var raw string
+	err := r.queryRow(ctx, r.read, "FetchValuesOverride", `
+        SELECT COALESCE({league_matches.values_override}::text, '')
+        FROM {league_matches}
+        WHERE {league_matches.id} = $1
+    `, matchID).Scan(&raw)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("match %d: %w", matchID, ErrNotFound)
+		}
+		return "", fmt.Errorf("fetch values override for %d: %w", matchID, err)
+	}
+	return raw, nil
+}
+
 // FetchMapName returns the map name for the provided ID.
 func (r *Repository) FetchMapName(ctx context.Context, mapID int) (string, error) {
+	if r.cache.enabled() {
+		name, ok := r.cache.maps.get(mapID)
+		r.cache.record(ok)
+		if ok {
+			return name, nil
+		}
+	}
+
 	var mapName string
-	if err := r.db.QueryRowContext(ctx, `SELECT name FROM maps WHERE id = $1`, mapID).Scan(&mapName); err != nil {
+	if err := r.stmtQueryRow(ctx, "FetchMapName", r.stmts.mapName, mapID).Scan(&mapName); err != nil {
 		return "", fmt.Errorf("fetch map %d: %w", mapID, err)
 	}
+	if !config.ValidMapName(mapName) {
+		return "", fmt.Errorf("map %d has invalid name %q", mapID, mapName)
+	}
+	if r.cache.enabled() {
+		r.cache.maps.set(mapID, mapName, r.cache.ttl)
+	}
 	return mapName, nil
 }
 
+// FetchMapNames returns map names keyed by ID. Unknown IDs are absent from the result.
+func (r *Repository) FetchMapNames(ctx context.Context, mapIDs []int) (map[int]string, error) {
+	names := make(map[int]string, len(mapIDs))
+	missing := mapIDs
+	if r.cache.enabled() {
+		missing = nil
+		for _, id := range mapIDs {
+			name, ok := r.cache.maps.get(id)
+			r.cache.record(ok)
+			if ok {
+				names[id] = name
+			} else {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) == 0 {
+			return names, nil
+		}
+	}
+
+	rows, err := r.query(ctx, r.read, "FetchMapNames", `SELECT {maps.id}, {maps.name} FROM {maps} WHERE {maps.id} = ANY($1)`, pq.Array(missing))
+	if err != nil {
+		return nil, fmt.Errorf("fetch maps: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("scan map: %w", err)
+		}
+		// Map names reach SRCDS_STARTMAP and RCON changelevel, so an invalid one is
+		// left out and its rounds fall back to DEFAULT_MAP.
+		if !config.ValidMapName(name) {
+			klog.Warningf("ignoring map %d, invalid name %q", id, name)
+			continue
+		}
+		names[id] = name
+		if r.cache.enabled() {
+			r.cache.maps.set(id, name, r.cache.ttl)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate maps: %w", err)
+	}
+	return names, nil
+}
+
 // FetchMatchDetails retrieves the saved connection details, if any.
 func (r *Repository) FetchMatchDetails(ctx context.Context, matchID, roundID int) (*MatchDetails, error) {
 	var details MatchDetails
 	var portStr, sourceTVStr string
-	err := r.db.QueryRowContext(ctx, `
-        SELECT match_id, round_id, server_ip, port, sourcetvport, password, map
-        FROM matches_server_details
-        WHERE match_id = $1 AND round_id = $2
-    `, matchID, roundID).Scan(
+	err := r.stmtQueryRow(ctx, "FetchMatchDetails", r.stmts.matchDetails, matchID, roundID).Scan(
 		&details.MatchID,
 		&details.RoundID,
 		&details.ServerIP,
 		&portStr,
 		&sourceTVStr,
+		&details.ClientPort,
+		&details.SteamPort,
 		&details.Password,
 		&details.Map,
 	)
@@ -296,9 +805,9 @@ func (r *Repository) FetchMatchDetails(ctx context.Context, matchID, roundID int
 
 // FetchAllMatchDetails retrieves all match server details (all active servers).
 func (r *Repository) FetchAllMatchDetails(ctx context.Context) ([]MatchDetails, error) {
-	rows, err := r.db.QueryContext(ctx, `
-        SELECT match_id, round_id, server_ip, port, sourcetvport, password, map
-        FROM matches_server_details
+	rows, err := r.query(ctx, r.db, "FetchAllMatchDetails", `
+        SELECT `+matchDetailsColumns+`
+        FROM {matches_server_details}
     `)
 	if err != nil {
 		return nil, fmt.Errorf("query all match details: %w", err)
@@ -315,6 +824,8 @@ func (r *Repository) FetchAllMatchDetails(ctx context.Context) ([]MatchDetails,
 			&details.ServerIP,
 			&portStr,
 			&sourceTVStr,
+			&details.ClientPort,
+			&details.SteamPort,
 			&details.Password,
 			&details.Map,
 		); err != nil {
@@ -342,35 +853,180 @@ func (r *Repository) FetchAllMatchDetails(ctx context.Context) ([]MatchDetails,
 
 // UpsertMatchDetails inserts or updates the matches_server_details row.
 func (r *Repository) UpsertMatchDetails(ctx context.Context, details MatchDetails) error {
-	_, err := r.db.ExecContext(ctx, `
-        INSERT INTO matches_server_details (match_id, server_ip, port, sourcetvport, password, map, round_id, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
-        ON CONFLICT (match_id, round_id)
-        DO UPDATE SET server_ip = EXCLUDED.server_ip,
-                      port = EXCLUDED.port,
-                      sourcetvport = EXCLUDED.sourcetvport,
-                      password = EXCLUDED.password,
-                      map = EXCLUDED.map,
-                      updated_at = NOW()
-    `, details.MatchID, details.ServerIP, details.Port, details.SourceTVPort, details.Password, details.Map, details.RoundID)
+	return r.upsertMatchDetails(ctx, r.stmts.upsertDetails, details)
+}
+
+// UpsertMatchDetailsTx is UpsertMatchDetails within tx.
+func (r *Repository) UpsertMatchDetailsTx(ctx context.Context, tx *sql.Tx, details MatchDetails) error {
+	return r.upsertMatchDetails(ctx, tx.StmtContext(ctx, r.stmts.upsertDetails), details)
+}
+
+func (r *Repository) upsertMatchDetails(ctx context.Context, stmt *sql.Stmt, details MatchDetails) error {
+	_, err := r.stmtExec(ctx, "UpsertMatchDetails", stmt, details.MatchID, details.ServerIP, details.Port, details.SourceTVPort, details.ClientPort, details.SteamPort, secret(details.Password), details.Map, details.RoundID)
 	if err != nil {
 		return fmt.Errorf("upsert match details: %w", err)
 	}
 	return nil
 }
 
+// MatchArtifacts records where a torn-down server left its demos and logs.
+type MatchArtifacts struct {
+	MatchID  int
+	RoundID  int
+	NodeName string
+	HostPath string
+}
+
+// RecordMatchArtifacts upserts the matches_server_artifacts row for a round so
+// admins can find demos and logs after the server is gone.
+func (r *Repository) RecordMatchArtifacts(ctx context.Context, artifacts MatchArtifacts) error {
+	if _, err := r.exec(ctx, r.db, "RecordMatchArtifacts", `
+        INSERT INTO {matches_server_artifacts} ({matches_server_artifacts.match_id}, {matches_server_artifacts.round_id},
+               {matches_server_artifacts.node_name}, {matches_server_artifacts.host_path},
+               {matches_server_artifacts.created_at}, {matches_server_artifacts.updated_at})
+        VALUES ($1, $2, $3, $4, NOW(), NOW())
+        ON CONFLICT ({matches_server_artifacts.match_id}, {matches_server_artifacts.round_id})
+        DO UPDATE SET {matches_server_artifacts.node_name} = EXCLUDED.{matches_server_artifacts.node_name},
+                      {matches_server_artifacts.host_path} = EXCLUDED.{matches_server_artifacts.host_path},
+                      {matches_server_artifacts.updated_at} = NOW()
+    `, artifacts.MatchID, artifacts.RoundID, artifacts.NodeName, artifacts.HostPath); err != nil {
+		return fmt.Errorf("record match artifacts (%d,%d): %w", artifacts.MatchID, artifacts.RoundID, err)
+	}
+	return nil
+}
+
+// UpdatePlayerCounts stores a running server's live player count for the site
+// to display.
+func (r *Repository) UpdatePlayerCounts(ctx context.Context, matchID, roundID, players, maxPlayers int) error {
+	if _, err := r.exec(ctx, r.db, "UpdatePlayerCounts", `
+        UPDATE {matches_server_details}
+           SET {matches_server_details.player_count} = $3, {matches_server_details.max_players} = $4,
+               {matches_server_details.updated_at} = NOW()
+         WHERE {matches_server_details.match_id} = $1 AND {matches_server_details.round_id} = $2
+    `, matchID, roundID, players, maxPlayers); err != nil {
+		return fmt.Errorf("update player counts (%d,%d): %w", matchID, roundID, err)
+	}
+	return nil
+}
+
+// UpdateActualMap records the map a running server is really on, which can drift
+// from the desired map in the map column after a manual changelevel.
+func (r *Repository) UpdateActualMap(ctx context.Context, matchID, roundID int, mapName string) error {
+	if _, err := r.exec(ctx, r.db, "UpdateActualMap", `
+        UPDATE {matches_server_details}
+           SET {matches_server_details.actual_map} = $3, {matches_server_details.updated_at} = NOW()
+         WHERE {matches_server_details.match_id} = $1 AND {matches_server_details.round_id} = $2
+    `, matchID, roundID, mapName); err != nil {
+		return fmt.Errorf("update actual map (%d,%d): %w", matchID, roundID, err)
+	}
+	return nil
+}
+
+// SetReconcileError records why a match's servers cannot be provisioned, for the
+// site to show; an empty message clears it.
+func (r *Repository) SetReconcileError(ctx context.Context, matchID int, message string) error {
+	if _, err := r.exec(ctx, r.db, "SetReconcileError", `
+        UPDATE {league_matches}
+           SET {league_matches.reconcile_error} = NULLIF($2, '')
+         WHERE {league_matches.id} = $1
+    `, matchID, message); err != nil {
+		return fmt.Errorf("set reconcile error (%d): %w", matchID, err)
+	}
+	return nil
+}
+
+// UpdateServerIPv6Tx records the IPv6 address a dual-stack server is also
+// reachable on, next to the server_ip written by UpsertMatchDetailsTx.
+func (r *Repository) UpdateServerIPv6Tx(ctx context.Context, tx *sql.Tx, matchID, roundID int, addr string) error {
+	if _, err := r.exec(ctx, tx, "UpdateServerIPv6", `
+        UPDATE {matches_server_details}
+           SET {matches_server_details.server_ipv6} = NULLIF($3, ''), {matches_server_details.updated_at} = NOW()
+         WHERE {matches_server_details.match_id} = $1 AND {matches_server_details.round_id} = $2
+    `, matchID, roundID, addr); err != nil {
+		return fmt.Errorf("update server ipv6 (%d,%d): %w", matchID, roundID, err)
+	}
+	return nil
+}
+
+// Server statuses stored in matches_server_details.server_status.
+const (
+	ServerStatusRunning     = "running"
+	ServerStatusUnreachable = "unreachable"
+)
+
+// UpdateServerStatusTx records whether a server answered the reachability probe,
+// as ServerStatusRunning or ServerStatusUnreachable.
+func (r *Repository) UpdateServerStatusTx(ctx context.Context, tx *sql.Tx, matchID, roundID int, status string) error {
+	if _, err := r.exec(ctx, tx, "UpdateServerStatus", `
+        UPDATE {matches_server_details}
+           SET {matches_server_details.server_status} = $3, {matches_server_details.updated_at} = NOW()
+         WHERE {matches_server_details.match_id} = $1 AND {matches_server_details.round_id} = $2
+    `, matchID, roundID, status); err != nil {
+		return fmt.Errorf("update server status (%d,%d): %w", matchID, roundID, err)
+	}
+	return nil
+}
+
+// FetchServerStatus returns the status stored for a round's server, or "" when
+// it has no details or none was recorded.
+func (r *Repository) FetchServerStatus(ctx context.Context, matchID, roundID int) (string, error) {
+	var status sql.NullString
+	err := r.queryRow(ctx, r.db, "FetchServerStatus", `
+        SELECT {matches_server_details.server_status}
+        FROM {matches_server_details}
+        WHERE {matches_server_details.match_id} = $1 AND {matches_server_details.round_id} = $2
+    `, matchID, roundID).Scan(&status)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("fetch server status (%d,%d): %w", matchID, roundID, err)
+	}
+	return status.String, nil
+}
+
+// UpdateSourceTVTx records how spectators reach a server's SourceTV: its password,
+// empty when it has none, and a console connect string for the site to show.
+func (r *Repository) UpdateSourceTVTx(ctx context.Context, tx *sql.Tx, matchID, roundID int, password, connect string) error {
+	if _, err := r.exec(ctx, tx, "UpdateSourceTV", `
+        UPDATE {matches_server_details}
+           SET {matches_server_details.sourcetv_password} = NULLIF($3, ''),
+               {matches_server_details.sourcetv_connect} = $4,
+               {matches_server_details.updated_at} = NOW()
+         WHERE {matches_server_details.match_id} = $1 AND {matches_server_details.round_id} = $2
+    `, matchID, roundID, secret(password), secret(connect)); err != nil {
+		return fmt.Errorf("update sourcetv (%d,%d): %w", matchID, roundID, err)
+	}
+	return nil
+}
+
 // DeleteMatchDetails removes the stored record once a server is torn down.
 func (r *Repository) DeleteMatchDetails(ctx context.Context, matchID, roundID int) error {
-	if _, err := r.db.ExecContext(ctx, `
-        DELETE FROM matches_server_details WHERE match_id = $1 AND round_id = $2
+	if _, err := r.exec(ctx, r.db, "DeleteMatchDetails", `
+        DELETE FROM {matches_server_details}
+         WHERE {matches_server_details.match_id} = $1 AND {matches_server_details.round_id} = $2
     `, matchID, roundID); err != nil {
 		return fmt.Errorf("delete match details (%d,%d): %w", matchID, roundID, err)
 	}
 	return nil
 }
 
+// SetNotificationCooldown makes notifications sent to a user within d of their
+// last one extend that notification instead of adding another. Zero disables it.
+func (r *Repository) SetNotificationCooldown(d time.Duration) {
+	r.notifyMu.Lock()
+	defer r.notifyMu.Unlock()
+	r.notifyCooldown = d
+	r.notified = map[int]sentNotification{}
+}
+
 // SendNotificationsToTeams fans messages out to both rosters.
 func (r *Repository) SendNotificationsToTeams(ctx context.Context, homeRosterID, awayRosterID int, message, link string) error {
+	return r.WithTx(ctx, func(tx *sql.Tx) error {
+		return r.SendNotificationsToTeamsTx(ctx, tx, homeRosterID, awayRosterID, message, link)
+	})
+}
+
+// SendNotificationsToTeamsTx is SendNotificationsToTeams within tx, so the
+// notifications commit or roll back together with the caller's other writes.
+func (r *Repository) SendNotificationsToTeamsTx(ctx context.Context, tx *sql.Tx, homeRosterID, awayRosterID int, message, link string) error {
 	homeUsers, err := r.fetchTeamUserIDs(ctx, homeRosterID)
 	if err != nil {
 		return fmt.Errorf("fetch home user ids: %w", err)
@@ -380,18 +1036,38 @@ func (r *Repository) SendNotificationsToTeams(ctx context.Context, homeRosterID,
 		return fmt.Errorf("fetch away user ids: %w", err)
 	}
 
-	recipients := append(homeUsers, awayUsers...)
-	for _, userID := range recipients {
-		if err := r.createUserNotification(ctx, userID, message, link); err != nil {
+	// All rows go through one transaction, so a failure part-way leaves nobody
+	// notified and a retry cannot notify the first recipients twice.
+	for _, userID := range append(homeUsers, awayUsers...) {
+		if err := r.createUserNotification(ctx, tx, userID, message, link); err != nil {
 			return fmt.Errorf("create notification for user %d: %w", userID, err)
 		}
 	}
 	return nil
 }
 
+// WithTx runs fn in a transaction on the primary, committing if it returns nil
+// and rolling back otherwise.
+func (r *Repository) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback: %w", rbErr))
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
 func (r *Repository) fetchTeamUserIDs(ctx context.Context, rosterID int) ([]int, error) {
-	rows, err := r.db.QueryContext(ctx, `
-        SELECT user_id FROM league_roster_players WHERE roster_id = $1
+	rows, err := r.query(ctx, r.read, "fetchTeamUserIDs", `
+        SELECT {league_roster_players.user_id} FROM {league_roster_players} WHERE {league_roster_players.roster_id} = $1
     `, rosterID)
 	if err != nil {
 		return nil, err
@@ -412,29 +1088,75 @@ func (r *Repository) fetchTeamUserIDs(ctx context.Context, rosterID int) ([]int,
 	return ids, nil
 }
 
-func (r *Repository) createUserNotification(ctx context.Context, userID int, message, link string) error {
-	_, err := r.db.ExecContext(ctx, `
-        INSERT INTO user_notifications (user_id, read, message, link, created_at, updated_at)
+func (r *Repository) createUserNotification(ctx context.Context, tx *sql.Tx, userID int, message, link string) error {
+	if r.notifyCooldown <= 0 {
+		_, err := r.exec(ctx, tx, "createUserNotification", `
+        INSERT INTO {user_notifications} ({user_notifications.user_id}, {user_notifications.read},
+               {user_notifications.message}, {user_notifications.link},
+               {user_notifications.created_at}, {user_notifications.updated_at})
+        VALUES ($1, FALSE, $2, $3, NOW(), NOW())
+    `, userID, secret(message), link)
+		if err != nil {
+			return fmt.Errorf("insert user_notification for %d: %w", userID, err)
+		}
+		return nil
+	}
+
+	r.notifyMu.Lock()
+	last, ok := r.notified[userID]
+	r.notifyMu.Unlock()
+	if ok && last.link == link {
+		// Append to the notification this controller sent the user for the same
+		// link within the cooldown, if it is still there, and mark it unread
+		// again so the batch shows up as one new notification.
+		res, err := r.exec(ctx, tx, "createUserNotification", `
+        UPDATE {user_notifications}
+           SET {user_notifications.message} = {user_notifications.message} || E'\n' || $3,
+               {user_notifications.read} = FALSE,
+               {user_notifications.updated_at} = NOW()
+         WHERE {user_notifications.id} = $1
+           AND {user_notifications.user_id} = $2
+           AND {user_notifications.created_at} > NOW() - make_interval(secs => $4)
+    `, last.id, userID, secret(message), r.notifyCooldown.Seconds())
+		if err != nil {
+			return fmt.Errorf("batch user_notification for %d: %w", userID, err)
+		}
+		if merged, err := res.RowsAffected(); err == nil && merged > 0 {
+			return nil
+		}
+	}
+
+	var id int64
+	err := r.queryRow(ctx, tx, "createUserNotification", `
+        INSERT INTO {user_notifications} ({user_notifications.user_id}, {user_notifications.read},
+               {user_notifications.message}, {user_notifications.link},
+               {user_notifications.created_at}, {user_notifications.updated_at})
         VALUES ($1, FALSE, $2, $3, NOW(), NOW())
-    `, userID, message, link)
+        RETURNING {user_notifications.id}
+    `, userID, secret(message), link).Scan(&id)
 	if err != nil {
 		return fmt.Errorf("insert user_notification for %d: %w", userID, err)
 	}
+	// A rolled-back insert leaves an ID no row has, so the next merge updates
+	// nothing and inserts instead.
+	r.notifyMu.Lock()
+	r.notified[userID] = sentNotification{id: id, link: link}
+	r.notifyMu.Unlock()
 	return nil
 }
 
 // FetchMatchByID fetches a match by its ID
 func (r *Repository) FetchMatchByID(ctx context.Context, matchID int) (*Match, error) {
 	var match Match
-	err := r.db.QueryRowContext(ctx, `
-		SELECT id, home_team_id, away_team_id, win_limit, status, manual_not_done
-		FROM league_matches
-		WHERE id = $1
+	err := r.queryRow(ctx, r.read, "FetchMatchByID", `
+		SELECT `+matchColumns+`
+		FROM {league_matches}
+		WHERE {league_matches.id} = $1
 	`, matchID).Scan(&match.ID, &match.RosterHomeID, &match.RosterAwayID, &match.WinLimit, &match.Status, &match.ManualNotDone)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("match with ID %d not found", matchID)
+			return nil, fmt.Errorf("match with ID %d: %w", matchID, ErrNotFound)
 		}
 		return nil, fmt.Errorf("fetch match %d: %w", matchID, err)
 	}
@@ -445,21 +1167,15 @@ func (r *Repository) FetchMatchByID(ctx context.Context, matchID int) (*Match, e
 // FetchMatchRoundByID fetches a specific round for a match
 func (r *Repository) FetchMatchRoundByID(ctx context.Context, matchID, roundID int) (*MatchRound, error) {
 	var round MatchRound
-	err := r.db.QueryRowContext(ctx, `
-		SELECT id, match_id, map_id, home_team_score, away_team_score, 
-		       loser_id, winner_id, 
-		       CASE WHEN loser_id IS NOT NULL OR winner_id IS NOT NULL THEN true ELSE false END,
-		       COALESCE(home_team_score, 0) - COALESCE(away_team_score, 0),
-		       home_ready, away_ready
-		FROM league_match_rounds
-		WHERE match_id = $1 AND id = $2
-	`, matchID, roundID).Scan(&round.ID, &round.MatchID, &round.MapID, &round.HomeTeamScore,
-		&round.AwayTeamScore, &round.LoserID, &round.WinnerID, &round.HasOutcome,
-		&round.ScoreDifference, &round.HomeReady, &round.AwayReady)
+	err := scanMatchRound(r.queryRow(ctx, r.read, "FetchMatchRoundByID", `
+		SELECT `+matchRoundColumns+`
+		FROM {league_match_rounds}
+		WHERE {league_match_rounds.match_id} = $1 AND {league_match_rounds.id} = $2
+	`, matchID, roundID), &round)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("round %d for match %d not found", roundID, matchID)
+			return nil, fmt.Errorf("round %d for match %d: %w", roundID, matchID, ErrNotFound)
 		}
 		return nil, fmt.Errorf("fetch round %d for match %d: %w", roundID, matchID, err)
 	}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/UDL-TF/TourneyController/internal/config"
+)
+
+// baseSchema lists the tables and columns the repository queries on every tick.
+var baseSchema = map[string][]string{
+	"league_matches":         {"id", "home_team_id", "away_team_id", "win_limit", "status", "manual_not_done"},
+	"league_match_rounds":    {"id", "match_id", "map_id", "home_team_score", "away_team_score", "loser_id", "winner_id", "has_outcome", "score_difference", "home_ready", "away_ready"},
+	"league_rosters":         {"id", "division_id"},
+	"league_divisions":       {"id", "name", "league_id"},
+	"leagues":                {"id", "name", "min_players", "max_players_in_game", "points_per_round_win", "points_per_round_draw", "points_per_round_loss", "points_per_match_win", "points_per_match_loss", "points_per_match_draw", "points_per_forfeit_win", "points_per_forfeit_loss", "points_per_forfeit_draw"},
+	"league_roster_players":  {"roster_id", "user_id"},
+	"users":                  {"id", "steam_id"},
+	"maps":                   {"id", "name"},
+	"matches_server_details": {"match_id", "round_id", "server_ip", "port", "sourcetvport", "client_port", "steam_port", "password", "map", "created_at", "updated_at"},
+	"user_notifications":     {"user_id", "read", "message", "link", "created_at", "updated_at"},
+}
+
+// optionalSchema lists the columns and tables only queried by optional features.
+var optionalSchema = map[string][]string{
+	"league_matches":           {"scheduled_at", "values_override", "priority", "reconcile_error"},
+	"league_match_rounds":      {"map_override", "updated_at"},
+	"matches_server_details":   {"player_count", "max_players", "actual_map", "server_ipv6", "sourcetv_password", "sourcetv_connect", "server_status"},
+	"matches_server_artifacts": {"match_id", "round_id", "node_name", "host_path", "created_at", "updated_at"},
+	"user_notifications":       {"id"},
+}
+
+// nameReplacer expands the {table} and {table.column} placeholders in the
+// repository's SQL to the names configured in mapping.
+func nameReplacer(mapping config.SchemaMapping) *strings.Replacer {
+	var pairs []string
+	for _, schema := range []map[string][]string{baseSchema, optionalSchema} {
+		for table, columns := range schema {
+			pairs = append(pairs, "{"+table+"}", pq.QuoteIdentifier(mapping.Table(table)))
+			for _, column := range columns {
+				pairs = append(pairs, "{"+table+"."+column+"}", pq.QuoteIdentifier(mapping.Column(table, column)))
+			}
+		}
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// requiredSchema adds the columns only queried when the corresponding feature is enabled.
+func requiredSchema(cfg *config.Config) map[string][]string {
+	schema := map[string][]string{}
+	for table, columns := range baseSchema {
+		schema[table] = append([]string{}, columns...)
+	}
+	if cfg.Match.PrioritizesBy(config.PriorityColumn) {
+		schema["league_matches"] = append(schema["league_matches"], "priority")
+	}
+	if cfg.Match.Order == config.MatchOrderScheduled || cfg.Match.Lookahead > 0 || cfg.Match.PrewarmLead > 0 || cfg.Match.PrioritizesBy(config.PriorityScheduled) {
+		schema["league_matches"] = append(schema["league_matches"], "scheduled_at")
+	}
+	if cfg.Match.ValuesOverrides {
+		schema["league_matches"] = append(schema["league_matches"], "values_override")
+	}
+	if cfg.Match.RecordErrors {
+		schema["league_matches"] = append(schema["league_matches"], "reconcile_error")
+	}
+	if cfg.Match.MapOverrides {
+		schema["league_match_rounds"] = append(schema["league_match_rounds"], "map_override")
+	}
+	if cfg.Match.TeardownGrace > 0 {
+		schema["league_match_rounds"] = append(schema["league_match_rounds"], "updated_at")
+	}
+	if cfg.Match.PlayerCounts {
+		schema["matches_server_details"] = append(schema["matches_server_details"], "player_count", "max_players")
+	}
+	if cfg.Match.MapDrift != config.MapDriftOff {
+		schema["matches_server_details"] = append(schema["matches_server_details"], "actual_map")
+	}
+	if cfg.Networking.DualStack {
+		schema["matches_server_details"] = append(schema["matches_server_details"], "server_ipv6")
+	}
+	if cfg.Match.SourceTVDetails {
+		schema["matches_server_details"] = append(schema["matches_server_details"], "sourcetv_password", "sourcetv_connect")
+	}
+	if cfg.Match.ServerStatus {
+		schema["matches_server_details"] = append(schema["matches_server_details"], "server_status")
+	}
+	if cfg.Notifications.Enabled && cfg.Notifications.Cooldown > 0 {
+		schema["user_notifications"] = append(schema["user_notifications"], "id")
+	}
+	if cfg.Artifacts.HostPathTemplate != "" {
+		schema["matches_server_artifacts"] = optionalSchema["matches_server_artifacts"]
+	}
+	return schema
+}
+
+// CheckSchema verifies that every table and column the controller queries with
+// cfg exists, so a site schema change fails at startup rather than as scan
+// errors mid-event. Names are checked after DB_SCHEMA_MAPPING_FILE renames, and
+// all missing columns are reported together.
+func (r *Repository) CheckSchema(ctx context.Context, cfg *config.Config) error {
+	schema := requiredSchema(cfg)
+	mapping := cfg.Database.Schema
+	tables := make([]string, 0, len(schema))
+	deployed := make([]string, 0, len(schema))
+	for table := range schema {
+		tables = append(tables, table)
+		deployed = append(deployed, mapping.Table(table))
+	}
+	sort.Strings(tables)
+
+	rows, err := r.query(ctx, r.db, "CheckSchema", `
+        SELECT table_name, column_name
+        FROM information_schema.columns
+        WHERE table_schema = ANY(current_schemas(false)) AND table_name = ANY($1)
+    `, pq.Array(deployed))
+	if err != nil {
+		return fmt.Errorf("query information_schema: %w", err)
+	}
+	defer rows.Close()
+
+	present := map[string]bool{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return fmt.Errorf("scan information_schema: %w", err)
+		}
+		present[table+"."+column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate information_schema: %w", err)
+	}
+
+	var missing []string
+	for _, table := range tables {
+		name := mapping.Table(table)
+		for _, column := range schema[table] {
+			if col := mapping.Column(table, column); !present[name+"."+col] {
+				missing = append(missing, fmt.Sprintf("expected column %s on table %s", col, name))
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("database schema mismatch: %s", strings.Join(missing, "; "))
+	}
+	return nil
+}
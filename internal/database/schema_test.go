@@ -0,0 +1,124 @@
+package database
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/UDL-TF/TourneyController/internal/config"
+)
+
+var (
+	createTablePattern = regexp.MustCompile(`(?s)CREATE TABLE (\w+) \((.*?)\n\);`)
+	placeholderPattern = regexp.MustCompile(`\{(\w+)(?:\.(\w+))?\}`)
+	rawStringPattern   = regexp.MustCompile("`[^`]*`")
+)
+
+// loadFixture parses testdata/schema.sql into its tables and columns.
+func loadFixture(t *testing.T) map[string]map[string]bool {
+	t.Helper()
+	data, err := os.ReadFile("testdata/schema.sql")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	tables := map[string]map[string]bool{}
+	for _, match := range createTablePattern.FindAllStringSubmatch(string(data), -1) {
+		columns := map[string]bool{}
+		for _, line := range strings.Split(match[2], "\n") {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				columns[fields[0]] = true
+			}
+		}
+		tables[match[1]] = columns
+	}
+	if len(tables) == 0 {
+		t.Fatal("fixture declares no tables")
+	}
+	return tables
+}
+
+func TestSchemaMatchesFixture(t *testing.T) {
+	fixture := loadFixture(t)
+	for _, schema := range []map[string][]string{baseSchema, optionalSchema} {
+		for table, columns := range schema {
+			for _, column := range columns {
+				if !fixture[table][column] {
+					t.Errorf("schema lists %s.%s, which the fixture does not have", table, column)
+				}
+			}
+		}
+	}
+}
+
+func TestQueryPlaceholdersAreDeclared(t *testing.T) {
+	declared := map[string]bool{}
+	for _, schema := range []map[string][]string{baseSchema, optionalSchema} {
+		for table, columns := range schema {
+			declared[table] = true
+			for _, column := range columns {
+				declared[table+"."+column] = true
+			}
+		}
+	}
+	source, err := os.ReadFile("repository.go")
+	if err != nil {
+		t.Fatalf("read repository.go: %v", err)
+	}
+	for _, query := range rawStringPattern.FindAllString(string(source), -1) {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(query, -1) {
+			name := match[1]
+			if match[2] != "" {
+				name += "." + match[2]
+			}
+			if !declared[name] {
+				t.Errorf("query placeholder {%s} is not declared in baseSchema or optionalSchema", name)
+			}
+		}
+	}
+}
+
+// countingScanner records how many destinations a scan function passes.
+type countingScanner struct{ dest int }
+
+func (s *countingScanner) Scan(dest ...interface{}) error {
+	s.dest = len(dest)
+	return nil
+}
+
+func TestMatchRoundColumnsMatchScan(t *testing.T) {
+	fixture := loadFixture(t)
+	columns := placeholderPattern.FindAllStringSubmatch(matchRoundColumns, -1)
+	for _, column := range columns {
+		if column[1] != "league_match_rounds" || !fixture[column[1]][column[2]] {
+			t.Errorf("matchRoundColumns selects {%s.%s}, which the fixture's league_match_rounds does not have", column[1], column[2])
+		}
+	}
+
+	var scanner countingScanner
+	if err := scanMatchRound(&scanner, &MatchRound{}); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if scanner.dest != len(columns) {
+		t.Errorf("matchRoundColumns selects %d columns but scanMatchRound scans %d", len(columns), scanner.dest)
+	}
+}
+
+// TestTeardownGraceRequiresUpdatedAt checks that startup fails without the
+// column TEARDOWN_GRACE times finished rounds from, rather than every tick
+// failing to read it.
+func TestTeardownGraceRequiresUpdatedAt(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load default config: %v", err)
+	}
+	if slices.Contains(requiredSchema(cfg)["league_match_rounds"], "updated_at") {
+		t.Error("updated_at is required without TEARDOWN_GRACE")
+	}
+	cfg.Match.TeardownGrace = time.Minute
+	if !slices.Contains(requiredSchema(cfg)["league_match_rounds"], "updated_at") {
+		t.Error("updated_at is not required with TEARDOWN_GRACE")
+	}
+}
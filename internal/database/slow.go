@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// secret is a query argument kept out of slow-query logs, such as a server
+// password or a notification message that contains one.
+type secret string
+
+func (s secret) Value() (driver.Value, error) { return string(s), nil }
+
+func (s secret) String() string { return "****" }
+
+// query expands the placeholders in query and runs it on db, logging it as op
+// when it is slow.
+func (r *Repository) query(ctx context.Context, db queryer, op, query string, args ...any) (*sql.Rows, error) {
+	defer r.observe(op, time.Now(), args)
+	return db.QueryContext(ctx, r.sql(query), args...)
+}
+
+// queryRow is query for a single row.
+func (r *Repository) queryRow(ctx context.Context, db queryer, op, query string, args ...any) *sql.Row {
+	defer r.observe(op, time.Now(), args)
+	return db.QueryRowContext(ctx, r.sql(query), args...)
+}
+
+// exec is query for statements that return no rows.
+func (r *Repository) exec(ctx context.Context, db execer, op, query string, args ...any) (sql.Result, error) {
+	defer r.observe(op, time.Now(), args)
+	return db.ExecContext(ctx, r.sql(query), args...)
+}
+
+// stmtQueryRow runs a prepared statement for a single row, logging it as op
+// when it is slow.
+func (r *Repository) stmtQueryRow(ctx context.Context, op string, stmt *sql.Stmt, args ...any) *sql.Row {
+	defer r.observe(op, time.Now(), args)
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+// stmtExec is stmtQueryRow for statements that return no rows.
+func (r *Repository) stmtExec(ctx context.Context, op string, stmt *sql.Stmt, args ...any) (sql.Result, error) {
+	defer r.observe(op, time.Now(), args)
+	return stmt.ExecContext(ctx, args...)
+}
+
+// observe warns about and counts queries that took longer than the configured
+// slow-query threshold.
+func (r *Repository) observe(op string, start time.Time, args []any) {
+	elapsed := time.Since(start)
+	if r.slowThreshold <= 0 || elapsed < r.slowThreshold {
+		return
+	}
+	r.slowQueries.Add(1)
+	klog.Warningf("slow query %s took %s (threshold %s), params %v", op, elapsed.Round(time.Millisecond), r.slowThreshold, args)
+}
+
+// SlowQueries returns how many queries have exceeded the slow-query threshold.
+func (r *Repository) SlowQueries() uint64 {
+	return r.slowQueries.Load()
+}
@@ -0,0 +1,113 @@
+-- Site schema the controller reads and writes, trimmed to the columns it uses.
+-- schema_test.go checks every query placeholder against these tables.
+
+CREATE TABLE leagues (
+    id serial PRIMARY KEY,
+    name text NOT NULL,
+    min_players integer NOT NULL,
+    max_players_in_game integer NOT NULL,
+    points_per_round_win integer NOT NULL,
+    points_per_round_draw integer NOT NULL,
+    points_per_round_loss integer NOT NULL,
+    points_per_match_win integer NOT NULL,
+    points_per_match_loss integer NOT NULL,
+    points_per_match_draw integer NOT NULL,
+    points_per_forfeit_win integer NOT NULL,
+    points_per_forfeit_loss integer NOT NULL,
+    points_per_forfeit_draw integer NOT NULL
+);
+
+CREATE TABLE league_divisions (
+    id serial PRIMARY KEY,
+    name text NOT NULL,
+    league_id integer NOT NULL
+);
+
+CREATE TABLE league_rosters (
+    id serial PRIMARY KEY,
+    division_id integer NOT NULL
+);
+
+CREATE TABLE league_roster_players (
+    roster_id integer NOT NULL,
+    user_id integer NOT NULL
+);
+
+CREATE TABLE users (
+    id serial PRIMARY KEY,
+    steam_id text
+);
+
+CREATE TABLE maps (
+    id serial PRIMARY KEY,
+    name text NOT NULL
+);
+
+CREATE TABLE league_matches (
+    id serial PRIMARY KEY,
+    home_team_id integer NOT NULL,
+    away_team_id integer,
+    win_limit integer,
+    status integer NOT NULL,
+    manual_not_done boolean NOT NULL DEFAULT false,
+    scheduled_at timestamp,
+    values_override text,
+    priority integer,
+    reconcile_error text
+);
+
+CREATE TABLE league_match_rounds (
+    id serial PRIMARY KEY,
+    match_id integer NOT NULL,
+    map_id integer NOT NULL,
+    home_team_score numeric NOT NULL DEFAULT 0,
+    away_team_score numeric NOT NULL DEFAULT 0,
+    loser_id integer,
+    winner_id integer,
+    has_outcome boolean NOT NULL DEFAULT false,
+    score_difference numeric NOT NULL DEFAULT 0,
+    home_ready boolean NOT NULL DEFAULT false,
+    away_ready boolean NOT NULL DEFAULT false,
+    map_override text,
+    updated_at timestamp
+);
+
+CREATE TABLE matches_server_details (
+    match_id integer NOT NULL,
+    round_id integer NOT NULL,
+    server_ip text NOT NULL,
+    port integer NOT NULL,
+    sourcetvport integer NOT NULL,
+    client_port integer NOT NULL,
+    steam_port integer NOT NULL,
+    password text NOT NULL,
+    map text NOT NULL,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    player_count integer,
+    max_players integer,
+    actual_map text,
+    server_ipv6 text,
+    sourcetv_password text,
+    sourcetv_connect text,
+    server_status text
+);
+
+CREATE TABLE matches_server_artifacts (
+    match_id integer NOT NULL,
+    round_id integer NOT NULL,
+    node_name text NOT NULL,
+    host_path text NOT NULL,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
+
+CREATE TABLE user_notifications (
+    id serial PRIMARY KEY,
+    user_id integer NOT NULL,
+    read boolean NOT NULL DEFAULT false,
+    message text NOT NULL,
+    link text,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
@@ -2,21 +2,78 @@ package ports
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	"github.com/UDL-TF/TourneyController/internal/config"
 )
 
-// Assignment represents a concrete set of NodePorts for a server.
+// ErrPortsExhausted is returned when a range has no free port left. It is a
+// transient condition that clears once servers are torn down.
+var ErrPortsExhausted = errors.New("no free ports available")
+
+// Assignment represents a concrete set of NodePorts for a server. Game carries
+// both UDP game traffic and TCP RCON: srcds binds both protocols to its -port, so
+// the two cannot be split onto different numbers. SourceTV, Client and Steam are
+// UDP only.
 type Assignment struct {
-	Game     int
-	SourceTV int
-	Client   int
-	Steam    int
+	Game     int // UDP and TCP
+	SourceTV int // UDP
+	Client   int // UDP
+	Steam    int // UDP
+}
+
+// ServerPort is one port and protocol a server listens on.
+type ServerPort struct {
+	Name     string
+	Port     int
+	Protocol corev1.Protocol
+}
+
+// gameProtocols are the protocols srcds serves on the game port.
+var gameProtocols = []corev1.Protocol{corev1.ProtocolUDP, corev1.ProtocolTCP}
+
+// Ports lists every port and protocol of the assignment, one entry per
+// protocol, in the order they are exposed on the container and service.
+func (a Assignment) Ports() []ServerPort {
+	return []ServerPort{
+		{Name: "game-udp", Port: a.Game, Protocol: corev1.ProtocolUDP},
+		{Name: "game-tcp", Port: a.Game, Protocol: corev1.ProtocolTCP},
+		{Name: "sourcetv", Port: a.SourceTV, Protocol: corev1.ProtocolUDP},
+		{Name: "client", Port: a.Client, Protocol: corev1.ProtocolUDP},
+		{Name: "steam", Port: a.Steam, Protocol: corev1.ProtocolUDP},
+	}
+}
+
+// portKey identifies a NodePort. Kubernetes allocates NodePorts per protocol, so
+// the same number may be held over UDP by one service and TCP by another.
+type portKey struct {
+	port     int
+	protocol corev1.Protocol
+}
+
+// usedPorts is the set of taken NodePorts.
+type usedPorts map[portKey]struct{}
+
+func (u usedPorts) taken(port int, protocols ...corev1.Protocol) bool {
+	for _, protocol := range protocols {
+		if _, ok := u[portKey{port, protocol}]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (u usedPorts) reserve(port int, protocols ...corev1.Protocol) {
+	for _, protocol := range protocols {
+		u[portKey{port, protocol}] = struct{}{}
+	}
 }
 
 // Allocator tracks which ranges are reserved for each port type.
@@ -31,104 +88,249 @@ func NewAllocator(ranges config.PortsConfig) *Allocator {
 
 // AllocateWithSecrets returns the next free port in each configured range, checking both services and secrets.
 func (a *Allocator) AllocateWithSecrets(ctx context.Context, svcClient corev1client.ServiceInterface, secretClient corev1client.SecretInterface) (Assignment, error) {
-	used := map[int]struct{}{}
+	owners, err := a.scanUsed(ctx, svcClient, secretClient)
+	if err != nil {
+		return Assignment{}, err
+	}
+	return a.assign(usedSet(owners))
+}
+
+// Allocate returns the next free port in each configured range.
+func (a *Allocator) Allocate(ctx context.Context, svcClient corev1client.ServiceInterface) (Assignment, error) {
+	owners, err := a.scanUsed(ctx, svcClient, nil)
+	if err != nil {
+		return Assignment{}, err
+	}
+	return a.assign(usedSet(owners))
+}
+
+// PortUsage records who holds a port and over which protocols.
+type PortUsage struct {
+	Port      int
+	Protocols []corev1.Protocol
+	Owner     string
+}
+
+// ProtocolList joins the protocols for display, e.g. "UDP/TCP".
+func (p PortUsage) ProtocolList() string {
+	names := make([]string, len(p.Protocols))
+	for i, protocol := range p.Protocols {
+		names[i] = string(protocol)
+	}
+	return strings.Join(names, "/")
+}
+
+// RangeUsage summarizes allocation within one configured range.
+type RangeUsage struct {
+	Name  string
+	Range config.PortRange
+	Used  []PortUsage
+}
+
+// Free returns how many ports in the range are still available.
+func (r RangeUsage) Free() int {
+	return r.Range.Size() - len(r.Used)
+}
+
+// Report scans services and secrets the same way AllocateWithSecrets does and groups
+// the ports in use by configured range.
+func (a *Allocator) Report(ctx context.Context, svcClient corev1client.ServiceInterface, secretClient corev1client.SecretInterface) ([]RangeUsage, error) {
+	owners, err := a.scanUsed(ctx, svcClient, secretClient)
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := a.ranges.Ranges()
+	if a.ranges.Mode == config.PortAllocationContiguous {
+		ranges = ranges[:1]
+	}
+
+	report := make([]RangeUsage, 0, len(ranges))
+	for _, named := range ranges {
+		usage := RangeUsage{Name: named.Name, Range: named.Range}
+		for port := named.Range.Start; port <= named.Range.End; port++ {
+			var used *PortUsage
+			for _, protocol := range gameProtocols {
+				owner, ok := owners[portKey{port, protocol}]
+				if !ok {
+					continue
+				}
+				if used == nil {
+					used = &PortUsage{Port: port, Owner: owner}
+				}
+				used.Protocols = append(used.Protocols, protocol)
+			}
+			if used != nil {
+				usage.Used = append(usage.Used, *used)
+			}
+		}
+		report = append(report, usage)
+	}
+	return report, nil
+}
+
+// scanUsed maps every port and protocol held by a NodePort service or a tournament
+// server secret to a description of its owner. A nil secretClient skips the secret
+// scan.
+func (a *Allocator) scanUsed(ctx context.Context, svcClient corev1client.ServiceInterface, secretClient corev1client.SecretInterface) (map[portKey]string, error) {
+	owners := map[portKey]string{}
 
 	// Check existing services for NodePort usage
 	svcList, err := svcClient.List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return Assignment{}, fmt.Errorf("list services: %w", err)
+		return nil, fmt.Errorf("list services: %w", err)
 	}
 
 	for _, svc := range svcList.Items {
 		for _, port := range svc.Spec.Ports {
 			if port.NodePort > 0 {
-				used[int(port.NodePort)] = struct{}{}
+				protocol := port.Protocol
+				if protocol == "" {
+					protocol = corev1.ProtocolTCP
+				}
+				owners[portKey{int(port.NodePort), protocol}] = ownerOf(svc.Labels, "service "+svc.Name)
 			}
 		}
 	}
 
+	if secretClient == nil {
+		return owners, nil
+	}
+
 	// Also check existing tournament server secrets for port allocations
 	secretList, err := secretClient.List(ctx, metav1.ListOptions{
-		LabelSelector: "udl.tf/match-id", // Only check tournament server secrets
+		// Only check tournament server secrets, not copies retained after teardown
+		LabelSelector: "udl.tf/match-id,!udl.tf/retired",
 	})
 	if err == nil { // Don't fail if secret listing fails
 		for _, secret := range secretList.Items {
 			// Parse ports from the secret data
-			a.parsePortsFromSecret(secret.Data, used)
+			a.parsePortsFromSecret(secret.Data, owners, ownerOf(secret.Labels, "secret "+secret.Name))
 		}
 	}
 
-	assign := Assignment{}
-	if assign.Game, err = a.nextFree(a.ranges.Game, used); err != nil {
-		return Assignment{}, err
-	}
-	if assign.SourceTV, err = a.nextFree(a.ranges.SourceTV, used); err != nil {
-		return Assignment{}, err
-	}
-	if assign.Client, err = a.nextFree(a.ranges.Client, used); err != nil {
-		return Assignment{}, err
-	}
-	if assign.Steam, err = a.nextFree(a.ranges.Steam, used); err != nil {
-		return Assignment{}, err
-	}
-
-	return assign, nil
+	return owners, nil
 }
 
-// parsePortsFromSecret extracts port numbers from secret data and adds them to the used map
-func (a *Allocator) parsePortsFromSecret(data map[string][]byte, used map[int]struct{}) {
-	portKeys := []string{"game_port", "sourcetv_port", "client_port", "steam_port"}
-	for _, key := range portKeys {
+// parsePortsFromSecret extracts port numbers from secret data and records their owner
+// under the protocols the server uses them for.
+func (a *Allocator) parsePortsFromSecret(data map[string][]byte, owners map[portKey]string, owner string) {
+	portKeys := map[string][]corev1.Protocol{
+		"game_port":     gameProtocols,
+		"sourcetv_port": {corev1.ProtocolUDP},
+		"client_port":   {corev1.ProtocolUDP},
+		"steam_port":    {corev1.ProtocolUDP},
+	}
+	for key, protocols := range portKeys {
 		if portBytes, exists := data[key]; exists {
 			if port, err := strconv.Atoi(string(portBytes)); err == nil && port > 0 {
-				used[port] = struct{}{}
+				for _, protocol := range protocols {
+					if _, taken := owners[portKey{port, protocol}]; !taken {
+						owners[portKey{port, protocol}] = owner
+					}
+				}
 			}
 		}
 	}
 }
 
-// Allocate returns the next free port in each configured range.
-func (a *Allocator) Allocate(ctx context.Context, svcClient corev1client.ServiceInterface) (Assignment, error) {
-	used := map[int]struct{}{}
+// ownerOf prefers the match/round labels and falls back to the object description.
+func ownerOf(labels map[string]string, fallback string) string {
+	matchID, roundID := labels["udl.tf/match-id"], labels["udl.tf/round-id"]
+	if matchID != "" && roundID != "" {
+		return fmt.Sprintf("match %s round %s", matchID, roundID)
+	}
+	return fallback
+}
 
-	// Check existing services for NodePort usage
-	svcList, err := svcClient.List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return Assignment{}, fmt.Errorf("list services: %w", err)
+func usedSet(owners map[portKey]string) usedPorts {
+	used := make(usedPorts, len(owners))
+	for key := range owners {
+		used[key] = struct{}{}
 	}
+	return used
+}
 
-	for _, svc := range svcList.Items {
-		for _, port := range svc.Spec.Ports {
-			if port.NodePort > 0 {
-				used[int(port.NodePort)] = struct{}{}
-			}
-		}
+// assign picks a full Assignment according to the configured allocation mode. The
+// game port must be free over both UDP and TCP, the others over UDP.
+func (a *Allocator) assign(used usedPorts) (Assignment, error) {
+	if a.ranges.Mode == config.PortAllocationContiguous {
+		return a.nextFreeBlock(used)
 	}
 
+	var err error
 	assign := Assignment{}
-	if assign.Game, err = a.nextFree(a.ranges.Game, used); err != nil {
+	if assign.Game, err = a.nextFree(a.ranges.Game, used, gameProtocols...); err != nil {
 		return Assignment{}, err
 	}
-	if assign.SourceTV, err = a.nextFree(a.ranges.SourceTV, used); err != nil {
+	if assign.SourceTV, err = a.nextFree(a.ranges.SourceTV, used, corev1.ProtocolUDP); err != nil {
 		return Assignment{}, err
 	}
-	if assign.Client, err = a.nextFree(a.ranges.Client, used); err != nil {
+	if assign.Client, err = a.nextFree(a.ranges.Client, used, corev1.ProtocolUDP); err != nil {
 		return Assignment{}, err
 	}
-	if assign.Steam, err = a.nextFree(a.ranges.Steam, used); err != nil {
+	if assign.Steam, err = a.nextFree(a.ranges.Steam, used, corev1.ProtocolUDP); err != nil {
 		return Assignment{}, err
 	}
 
 	return assign, nil
 }
 
-func (a *Allocator) nextFree(pr config.PortRange, used map[int]struct{}) (int, error) {
+// Sample returns the first assignment the configured ranges could produce, for
+// rendering placeholders without touching the cluster.
+func (a *Allocator) Sample() Assignment {
+	if a.ranges.Mode == config.PortAllocationContiguous {
+		return ContiguousAssignment(a.ranges.Game.Start)
+	}
+	return Assignment{
+		Game:     a.ranges.Game.Start,
+		SourceTV: a.ranges.SourceTV.Start,
+		Client:   a.ranges.Client.Start,
+		Steam:    a.ranges.Steam.Start,
+	}
+}
+
+// ContiguousAssignment lays out a contiguous block starting at the game port.
+func ContiguousAssignment(game int) Assignment {
+	return Assignment{
+		Game:     game,
+		Client:   game + 1,
+		Steam:    game + 2,
+		SourceTV: game + 3,
+	}
+}
+
+// nextFreeBlock reserves the first fully free, block-aligned run of ports in the
+// game range. Every port of the block must be free over both protocols, so the
+// block stays contiguous whatever protocol a neighbour holds it with.
+func (a *Allocator) nextFreeBlock(used usedPorts) (Assignment, error) {
+	pr := a.ranges.Game
+	for start := pr.Start; start+config.ContiguousBlockSize-1 <= pr.End; start += config.ContiguousBlockSize {
+		free := true
+		for port := start; port < start+config.ContiguousBlockSize; port++ {
+			if used.taken(port, gameProtocols...) {
+				free = false
+				break
+			}
+		}
+		if !free {
+			continue
+		}
+		for _, sp := range ContiguousAssignment(start).Ports() {
+			used.reserve(sp.Port, sp.Protocol)
+		}
+		return ContiguousAssignment(start), nil
+	}
+	return Assignment{}, fmt.Errorf("%w: need a block of %d in range %d-%d", ErrPortsExhausted, config.ContiguousBlockSize, pr.Start, pr.End)
+}
+
+func (a *Allocator) nextFree(pr config.PortRange, used usedPorts, protocols ...corev1.Protocol) (int, error) {
 	for port := pr.Start; port <= pr.End; port++ {
-		if _, exists := used[port]; exists {
+		if used.taken(port, protocols...) {
 			continue
 		}
-		used[port] = struct{}{}
+		used.reserve(port, protocols...)
 		return port, nil
 	}
-	return 0, fmt.Errorf("no free ports available in range %d-%d", pr.Start, pr.End)
+	return 0, fmt.Errorf("%w in range %d-%d", ErrPortsExhausted, pr.Start, pr.End)
 }
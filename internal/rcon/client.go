@@ -0,0 +1,168 @@
+package rcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Packet types from the Source RCON protocol.
+const (
+	typeResponseValue = 0
+	typeExecCommand   = 2
+	typeAuthResponse  = 2
+	typeAuth          = 3
+)
+
+// maxPacketSize bounds a single response packet; the protocol caps bodies at 4096 bytes.
+const maxPacketSize = 4096 + 10
+
+// ErrAuthFailed is returned when the server rejects the RCON password.
+var ErrAuthFailed = errors.New("rcon authentication failed")
+
+// Client is a minimal Source RCON client holding a single authenticated connection.
+type Client struct {
+	conn    net.Conn
+	timeout time.Duration
+	nextID  int32
+}
+
+// Dial connects to addr and authenticates with password. The timeout applies to
+// every read and write on the connection.
+func Dial(ctx context.Context, addr, password string, timeout time.Duration) (*Client, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+
+	c := &Client{conn: conn, timeout: timeout, nextID: 1}
+	if err := c.auth(password); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Execute runs a console command and returns its output.
+func (c *Client) Execute(command string) (string, error) {
+	id := c.id()
+	if err := c.write(id, typeExecCommand, command); err != nil {
+		return "", err
+	}
+	// An empty response-value packet sent after the command marks the end of a
+	// multi-packet reply, since the server echoes it back once the output is done.
+	terminator := c.id()
+	if err := c.write(terminator, typeResponseValue, ""); err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	for {
+		respID, respType, body, err := c.read()
+		if err != nil {
+			return "", err
+		}
+		if respType != typeResponseValue {
+			continue
+		}
+		if respID == terminator {
+			return out.String(), nil
+		}
+		if respID == id {
+			out.WriteString(body)
+		}
+	}
+}
+
+func (c *Client) auth(password string) error {
+	id := c.id()
+	if err := c.write(id, typeAuth, password); err != nil {
+		return err
+	}
+	for {
+		respID, respType, _, err := c.read()
+		if err != nil {
+			return err
+		}
+		if respType != typeAuthResponse {
+			continue // servers send an empty response value before the auth response
+		}
+		if respID == -1 {
+			return ErrAuthFailed
+		}
+		if respID == id {
+			return nil
+		}
+	}
+}
+
+func (c *Client) id() int32 {
+	id := c.nextID
+	c.nextID++
+	return id
+}
+
+func (c *Client) write(id, packetType int32, body string) error {
+	var buf bytes.Buffer
+	size := int32(4 + 4 + len(body) + 2)
+	_ = binary.Write(&buf, binary.LittleEndian, size)
+	_ = binary.Write(&buf, binary.LittleEndian, id)
+	_ = binary.Write(&buf, binary.LittleEndian, packetType)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("write rcon packet: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) read() (int32, int32, string, error) {
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, 0, "", err
+	}
+	var size int32
+	if err := binary.Read(c.conn, binary.LittleEndian, &size); err != nil {
+		return 0, 0, "", fmt.Errorf("read rcon packet size: %w", err)
+	}
+	if size < 10 || size > maxPacketSize {
+		return 0, 0, "", fmt.Errorf("invalid rcon packet size %d", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		return 0, 0, "", fmt.Errorf("read rcon packet: %w", err)
+	}
+	id := int32(binary.LittleEndian.Uint32(payload[0:4]))
+	packetType := int32(binary.LittleEndian.Uint32(payload[4:8]))
+	body := string(bytes.TrimRight(payload[8:], "\x00"))
+	return id, packetType, body, nil
+}
+
+// playersPattern matches both "players : 3 humans, 0 bots (24 max)" and the older
+// "players : 3 (24 max)" line of the status command.
+var playersPattern = regexp.MustCompile(`(?m)^players\s*:\s*(\d+)`)
+
+// HumanPlayers extracts the number of connected human players from status output.
+func HumanPlayers(status string) (int, error) {
+	m := playersPattern.FindStringSubmatch(status)
+	if m == nil {
+		return 0, errors.New("players line not found in status output")
+	}
+	return strconv.Atoi(m[1])
+}
@@ -8,21 +8,30 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 )
 
+// DefaultBaseURL is the public Steam Web API.
+const DefaultBaseURL = "https://api.steampowered.com"
+
 // baseURL/interface/method/version?parameters
-const location = "https://api.steampowered.com/IGameServersService/"
+const service = "/IGameServersService/"
 const version = "v1"
 
 // SteamClient is a struct that holds the API key and provides methods to
 // interact with the Steam API.
 type SteamClient struct {
-	apiKey string
+	apiKey  string
+	baseURL string
 }
 
-// NewSteamClient creates a new SteamClient with the provided API key.
-func NewSteamClient(apiKey string) *SteamClient {
-	return &SteamClient{apiKey: apiKey}
+// NewSteamClient creates a new SteamClient with the provided API key. baseURL
+// points it at a proxy, mirror or test server; empty uses DefaultBaseURL.
+func NewSteamClient(apiKey, baseURL string) *SteamClient {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &SteamClient{apiKey: apiKey, baseURL: strings.TrimRight(baseURL, "/")}
 }
 
 // Steam returns a JSON { response: } object, which wraps all return values.
@@ -30,6 +39,31 @@ type steamResponse struct {
 	Response json.RawMessage `json:"response"`
 }
 
+// steamError holds the error fields Steam sometimes puts in a 200 response body,
+// either at the top level or inside the response wrapper.
+type steamError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e steamError) text() string {
+	if e.Error != "" {
+		return e.Error
+	}
+	return e.Message
+}
+
+// maxErrorBody caps how much of an unexpected response body ends up in an error.
+const maxErrorBody = 256
+
+func bodySnippet(body []byte) string {
+	text := strings.TrimSpace(string(body))
+	if len(text) > maxErrorBody {
+		text = text[:maxErrorBody] + "..."
+	}
+	return text
+}
+
 // FML
 type serversResponse struct {
 	Servers []Account `json:"servers"`
@@ -47,11 +81,40 @@ type Account struct {
 	LastLogon  int    `json:"rt_last_logon,omitempty"`
 }
 
+// ErrUnavailable is wrapped by errors from failing to reach Steam or from Steam
+// failing on its side (5xx, rate limiting, non-JSON pages), which are worth
+// retrying later, unlike rejected requests.
+var ErrUnavailable = errors.New("steam API unavailable")
+
+// ErrIncompleteAccount is returned when Steam answers without the fields a
+// usable account needs, so an empty token is never handed to a server.
+var ErrIncompleteAccount = errors.New("steam API returned an incomplete account")
+
+func (a Account) validate() error {
+	if a.SteamID == "" || a.LoginToken == "" {
+		return fmt.Errorf("%w (steamid %q, token set: %t)", ErrIncompleteAccount, a.SteamID, a.LoginToken != "")
+	}
+	return nil
+}
+
 // Remove the { response: data } wrapper, and return inner json as byte array.
+// Bodies that are not JSON, lack the wrapper or carry an error field are
+// rejected, as Steam reports some failures (e.g. rate limits) with status 200.
 func unwrapResponse(response *[]byte) error {
 	resp := steamResponse{}
 	if err := json.Unmarshal(*response, &resp); err != nil {
-		return err
+		return fmt.Errorf("%w: non-JSON body: %s", ErrUnavailable, bodySnippet(*response))
+	}
+	var outer steamError
+	if err := json.Unmarshal(*response, &outer); err == nil && outer.text() != "" {
+		return fmt.Errorf("steam API error: %s", outer.text())
+	}
+	if len(resp.Response) == 0 || string(resp.Response) == "null" {
+		return fmt.Errorf("steam API returned no response: %s", bodySnippet(*response))
+	}
+	var inner steamError
+	if err := json.Unmarshal(resp.Response, &inner); err == nil && inner.text() != "" {
+		return fmt.Errorf("steam API error: %s", inner.text())
 	}
 	*response = ([]byte)(resp.Response)
 	return nil
@@ -61,7 +124,7 @@ func unwrapResponse(response *[]byte) error {
 // and handling of Response Header.
 func (client *SteamClient) querySteam(command string, method string, params map[string]string) (data []byte, err error) {
 	// Prep request
-	req, err := http.NewRequest(method, location+command+"/"+version, nil)
+	req, err := http.NewRequest(method, client.baseURL+service+command+"/"+version, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +143,7 @@ func (client *SteamClient) querySteam(command string, method string, params map[
 	httpClient := &http.Client{}
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
 	}
 	defer resp.Body.Close()
 
@@ -89,17 +152,20 @@ func (client *SteamClient) querySteam(command string, method string, params map[
 		return nil, errors.New(respErrState)
 	}
 
-	// Check for non-200 status codes
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("steam API request failed with status %d", resp.StatusCode)
-	}
-
 	// Read response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
+	// Check for non-200 status codes
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: status %d: %s", ErrUnavailable, resp.StatusCode, bodySnippet(body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("steam API request failed with status %d: %s", resp.StatusCode, bodySnippet(body))
+	}
+
 	// Remove wrapper
 	if err = unwrapResponse(&body); err != nil {
 		return nil, err
@@ -126,7 +192,7 @@ func (client *SteamClient) CreateAccount(appID int, memo string) (account Accoun
 		return account, err
 	}
 
-	return account, nil
+	return account, account.validate()
 }
 
 // GetAccountList returns a list of all accounts.
@@ -167,6 +233,9 @@ func (client *SteamClient) ResetLoginToken(steamID string) (account Account, err
 	if err := json.Unmarshal(data, &account); err != nil {
 		return account, err
 	}
+	if account.SteamID == "" {
+		account.SteamID = steamID
+	}
 
-	return account, nil
+	return account, account.validate()
 }